@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"yap/util"
 	// "log"
+	"math"
 	"sort"
 	"strings"
 	"sync"
@@ -317,24 +318,69 @@ func (v *AvgSparse) Serialize(generation int) interface{} {
 }
 
 func (v *AvgSparse) Deserialize(serialized interface{}, generation int) {
-	data, ok := serialized.(map[interface{}]map[int]int64)
-	if !ok {
+	v.DeserializeScaled(serialized, generation, 0)
+}
+
+// DeserializeScaled deserializes weights quantized to int16 or int32,
+// multiplying them by scale as they are read so that no dequantized copy of
+// them is made; a scale of 0 reads unquantized (int64) weights as they are
+func (v *AvgSparse) DeserializeScaled(serialized interface{}, generation int, scale float64) {
+	var (
+		keys    []interface{}
+		weights func(feature interface{}) (int, func(f func(transition int, weight int64)))
+	)
+	switch data := serialized.(type) {
+	case map[interface{}]map[int]int64:
+		for k := range data {
+			keys = append(keys, k)
+		}
+		weights = func(feature interface{}) (int, func(f func(int, int64))) {
+			return len(data[feature]), func(f func(int, int64)) {
+				for i, value := range data[feature] {
+					f(i, value)
+				}
+			}
+		}
+	case map[interface{}]map[int]int32:
+		for k := range data {
+			keys = append(keys, k)
+		}
+		weights = func(feature interface{}) (int, func(f func(int, int64))) {
+			return len(data[feature]), func(f func(int, int64)) {
+				for i, value := range data[feature] {
+					f(i, int64(value))
+				}
+			}
+		}
+	case map[interface{}]map[int]int16:
+		for k := range data {
+			keys = append(keys, k)
+		}
+		weights = func(feature interface{}) (int, func(f func(int, int64))) {
+			return len(data[feature]), func(f func(int, int64)) {
+				for i, value := range data[feature] {
+					f(i, int64(value))
+				}
+			}
+		}
+	default:
 		panic("Can't deserialize unknown serialization")
 	}
-	v.Vals = make(map[Feature]TransitionScoreStore, len(data))
-	allKeys := make(util.ByGeneric, 0, len(data))
-	for k, _ := range data {
+	v.Vals = make(map[Feature]TransitionScoreStore, len(keys))
+	allKeys := make(util.ByGeneric, 0, len(keys))
+	for _, k := range keys {
 		allKeys = append(allKeys, util.Generic{fmt.Sprintf("%v", k), k})
 	}
 	sort.Sort(allKeys)
 	for _, k := range allKeys {
-		datav := data[k.Value]
-		// log.Println("\t\tKey", k.Key, "transitions", len(datav))
-		// log.Println("\t\t\tValues", datav)
-		scoreStore := v.newTransitionScoreStore(len(datav))
-		for i, value := range datav {
+		size, each := weights(k.Value)
+		scoreStore := v.newTransitionScoreStore(size)
+		each(func(i int, value int64) {
+			if scale != 0 {
+				value = int64(math.Floor(float64(value)*scale + 0.5))
+			}
 			scoreStore.SetValue(i, NewHistoryValue(generation, value))
-		}
+		})
 		v.Vals[k.Value] = scoreStore
 	}
 }
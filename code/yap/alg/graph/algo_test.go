@@ -9,20 +9,25 @@ import (
 func TestYieldAllPaths(t *testing.T) {
 	vertices := []BasicVertex{1, 2, 3, 4, 5, 6}
 	edges := []BasicDirectedEdge{
-		{1, 2},
-		{1, 3},
-		{2, 4},
-		{4, 5},
-		{4, 6},
-		{5, 6},
-		{3, 6},
-		{1, 6},
+		{0, 1, 2},
+		{1, 1, 3},
+		{2, 2, 4},
+		{3, 4, 5},
+		{4, 4, 6},
+		{5, 5, 6},
+		{6, 3, 6},
+		{7, 1, 6},
 	}
 	graph := &BasicGraph{vertices, edges}
 	paths := make([][]int, 0, 1)
 	for path := range YieldAllPaths(graph, 1, 6) {
 		// log.Println("Got path:", path)
-		paths = append(paths, path)
+		// compare the vertices along each path of edges
+		vertices := []int{path[0].From()}
+		for _, edge := range path {
+			vertices = append(vertices, edge.To())
+		}
+		paths = append(paths, vertices)
 	}
 	shouldEq := [][]int{
 		{1, 6}, {1, 3, 6}, {1, 2, 4, 6}, {1, 2, 4, 5, 6},
@@ -0,0 +1,339 @@
+package graph
+
+import (
+	"fmt"
+	"math"
+)
+
+// The maximum spanning tree decoders find the best scoring dependency tree of
+// a sentence given the scores of its arcs (mcdonald et al. hlt/emnlp '05):
+// scores[h][m] is the score of an arc from head h to modifier m, where node 0
+// is the root and the words are 1..n. They return the heads of the nodes,
+// heads[0] being -1 for the root, and attach exactly one word to the root.
+
+// ChuLiuEdmonds returns the maximum spanning tree, projective or not, in
+// O(n^3) (chu & liu '65, edmonds '67)
+func ChuLiuEdmonds(scores [][]float64) []int {
+	n := len(scores)
+	verifySquare(scores)
+	// subtracting a penalty larger than any difference of tree scores from
+	// the arcs of the root leaves the best tree among those with one root arc
+	penalized := make([][]float64, n)
+	for h := range scores {
+		penalized[h] = make([]float64, n)
+		copy(penalized[h], scores[h])
+	}
+	if n > 2 {
+		low, high := math.Inf(1), math.Inf(-1)
+		for h := range scores {
+			for m := 1; m < n; m++ {
+				if h != m && !math.IsInf(scores[h][m], -1) {
+					low, high = math.Min(low, scores[h][m]), math.Max(high, scores[h][m])
+				}
+			}
+		}
+		penalty := (high-low)*float64(n) + 1
+		for m := 1; m < n; m++ {
+			penalized[0][m] -= penalty
+		}
+	}
+	heads := chuLiuEdmonds(penalized)
+	heads[0] = -1
+	return heads
+}
+
+func chuLiuEdmonds(scores [][]float64) []int {
+	n := len(scores)
+	heads := make([]int, n)
+	heads[0] = -1
+	for m := 1; m < n; m++ {
+		heads[m] = bestHead(scores, m)
+	}
+	cycle := findCycle(heads)
+	if cycle == nil {
+		return heads
+	}
+	inCycle := make([]bool, n)
+	for _, node := range cycle {
+		inCycle[node] = true
+	}
+	// contract the cycle to a new node, the last of the contracted graph
+	var (
+		outside  []int            // contracted index -> node
+		index    = make([]int, n) // node -> contracted index
+		enterTo  = make([]int, n) // node -> cycle node its best arc enters
+		leaveFrm = make([]int, n) // node -> cycle node its best arc leaves
+	)
+	for node := 0; node < n; node++ {
+		if !inCycle[node] {
+			index[node] = len(outside)
+			outside = append(outside, node)
+		}
+	}
+	c := len(outside)
+	contracted := make([][]float64, c+1)
+	for i := range contracted {
+		contracted[i] = make([]float64, c+1)
+		for j := range contracted[i] {
+			contracted[i][j] = math.Inf(-1)
+		}
+	}
+	for _, h := range outside {
+		for _, m := range outside {
+			if h != m {
+				contracted[index[h]][index[m]] = scores[h][m]
+			}
+		}
+		best, bestTo := math.Inf(-1), -1
+		for _, m := range cycle {
+			// entering the cycle at m breaks the arc to m
+			if score := scores[h][m] - scores[heads[m]][m]; bestTo < 0 || score > best {
+				best, bestTo = score, m
+			}
+		}
+		contracted[index[h]][c], enterTo[h] = best, bestTo
+		if h == 0 {
+			continue
+		}
+		best, bestFrom := math.Inf(-1), -1
+		for _, m := range cycle {
+			if bestFrom < 0 || scores[m][h] > best {
+				best, bestFrom = scores[m][h], m
+			}
+		}
+		contracted[c][index[h]], leaveFrm[h] = best, bestFrom
+	}
+	contractedHeads := chuLiuEdmonds(contracted)
+	// expand the cycle
+	for _, m := range outside[1:] {
+		if h := contractedHeads[index[m]]; h == c {
+			heads[m] = leaveFrm[m]
+		} else {
+			heads[m] = outside[h]
+		}
+	}
+	enteredFrom := outside[contractedHeads[c]]
+	heads[enterTo[enteredFrom]] = enteredFrom
+	return heads
+}
+
+func bestHead(scores [][]float64, m int) int {
+	best, bestHead := math.Inf(-1), -1
+	for h := range scores {
+		if h != m && (bestHead < 0 || scores[h][m] > best) {
+			best, bestHead = scores[h][m], h
+		}
+	}
+	return bestHead
+}
+
+// findCycle returns the nodes of a cycle of the heads, or nil if they form
+// a tree
+func findCycle(heads []int) []int {
+	visited := make([]int, len(heads)) // 0 unvisited, else the start + 1
+	for start := 1; start < len(heads); start++ {
+		node := start
+		for node > 0 && visited[node] == 0 {
+			visited[node] = start + 1
+			node = heads[node]
+		}
+		if node > 0 && visited[node] == start+1 {
+			cycle := []int{node}
+			for next := heads[node]; next != node; next = heads[next] {
+				cycle = append(cycle, next)
+			}
+			return cycle
+		}
+	}
+	return nil
+}
+
+// Eisner returns the maximum projective spanning tree in O(n^3) (eisner
+// coling '96)
+func Eisner(scores [][]float64) []int {
+	return EisnerSecondOrder(scores, nil)
+}
+
+// EisnerSecondOrder returns the maximum projective spanning tree scoring
+// also adjacent siblings, the modifiers of a head on the same side with none
+// between them, in O(n^3) (mcdonald & pereira eacl '06): sibling(h, s, m) is
+// the score of m being the modifier of h next after s, going outwards from h;
+// s is h for the modifier closest to h. A nil sibling scores only the arcs.
+func EisnerSecondOrder(scores [][]float64, sibling func(h, s, m int) float64) []int {
+	verifySquare(scores)
+	n := len(scores) - 1
+	heads := make([]int, n+1)
+	heads[0] = -1
+	if n == 0 {
+		return heads
+	}
+	e := newEisnerChart(scores, sibling)
+	e.fill()
+	// the single modifier of the root spans the sentence
+	best, root := math.Inf(-1), 0
+	for r := 1; r <= n; r++ {
+		score := e.complete[left][1][r] + e.complete[right][r][n] + scores[0][r] + e.sibling(0, 0, r)
+		if root == 0 || score > best {
+			best, root = score, r
+		}
+	}
+	heads[root] = 0
+	e.heads = heads
+	e.backtrackComplete(left, 1, root)
+	e.backtrackComplete(right, root, n)
+	return heads
+}
+
+const (
+	left = iota
+	right
+)
+
+// eisnerChart holds the best scores of the spans s..t over the words of the
+// sentence, and their split points for backtracking: complete[right][s][t]
+// is headed by s and complete[left][s][t] by t, with no modifiers left to
+// attach outside the span on the head's side; incomplete[dir][s][t] has an
+// arc between s and t; siblings[s][t] are the complete spans of two
+// adjacent modifiers s and t, facing each other
+type eisnerChart struct {
+	scores          [][]float64
+	siblingScore    func(h, s, m int) float64
+	complete        [2][][]float64
+	incomplete      [2][][]float64
+	siblings        [][]float64
+	completeSplit   [2][][]int
+	incompleteSplit [2][][]int
+	siblingsSplit   [][]int
+	heads           []int
+}
+
+func newEisnerChart(scores [][]float64, sibling func(h, s, m int) float64) *eisnerChart {
+	n := len(scores)
+	e := &eisnerChart{scores: scores, siblingScore: sibling}
+	for dir := left; dir <= right; dir++ {
+		e.complete[dir], e.completeSplit[dir] = newFloatChart(n), newIntChart(n)
+		e.incomplete[dir], e.incompleteSplit[dir] = newFloatChart(n), newIntChart(n)
+	}
+	e.siblings, e.siblingsSplit = newFloatChart(n), newIntChart(n)
+	return e
+}
+
+func (e *eisnerChart) sibling(h, s, m int) float64 {
+	if e.siblingScore == nil {
+		return 0
+	}
+	return e.siblingScore(h, s, m)
+}
+
+func (e *eisnerChart) fill() {
+	n := len(e.scores) - 1
+	for width := 1; width < n; width++ {
+		for s := 1; s+width <= n; s++ {
+			t := s + width
+			// adjacent modifiers s and t
+			best, split := math.Inf(-1), -1
+			for q := s; q < t; q++ {
+				if score := e.complete[right][s][q] + e.complete[left][q+1][t]; split < 0 || score > best {
+					best, split = score, q
+				}
+			}
+			e.siblings[s][t], e.siblingsSplit[s][t] = best, split
+			// s -> t, after the modifier r of s next to t, or first (r = s)
+			best, split = e.complete[right][s][s]+e.complete[left][s+1][t]+e.sibling(s, s, t), s
+			for r := s + 1; r < t; r++ {
+				if score := e.incomplete[right][s][r] + e.siblings[r][t] + e.sibling(s, r, t); score > best {
+					best, split = score, r
+				}
+			}
+			e.incomplete[right][s][t], e.incompleteSplit[right][s][t] = best+e.scores[s][t], split
+			// t -> s, after the modifier r of t next to s, or first (r = t)
+			best, split = e.complete[right][s][t-1]+e.complete[left][t][t]+e.sibling(t, t, s), t
+			for r := s + 1; r < t; r++ {
+				if score := e.siblings[s][r] + e.incomplete[left][r][t] + e.sibling(t, r, s); score > best {
+					best, split = score, r
+				}
+			}
+			e.incomplete[left][s][t], e.incompleteSplit[left][s][t] = best+e.scores[t][s], split
+			// complete spans end with the incomplete span of their last arc
+			best, split = math.Inf(-1), -1
+			for q := s + 1; q <= t; q++ {
+				if score := e.incomplete[right][s][q] + e.complete[right][q][t]; split < 0 || score > best {
+					best, split = score, q
+				}
+			}
+			e.complete[right][s][t], e.completeSplit[right][s][t] = best, split
+			best, split = math.Inf(-1), -1
+			for q := s; q < t; q++ {
+				if score := e.complete[left][s][q] + e.incomplete[left][q][t]; split < 0 || score > best {
+					best, split = score, q
+				}
+			}
+			e.complete[left][s][t], e.completeSplit[left][s][t] = best, split
+		}
+	}
+}
+
+func (e *eisnerChart) backtrackComplete(dir, s, t int) {
+	if s == t {
+		return
+	}
+	q := e.completeSplit[dir][s][t]
+	if dir == right {
+		e.backtrackIncomplete(right, s, q)
+		e.backtrackComplete(right, q, t)
+	} else {
+		e.backtrackComplete(left, s, q)
+		e.backtrackIncomplete(left, q, t)
+	}
+}
+
+func (e *eisnerChart) backtrackIncomplete(dir, s, t int) {
+	r := e.incompleteSplit[dir][s][t]
+	if dir == right {
+		e.heads[t] = s
+		if r == s {
+			e.backtrackComplete(left, s+1, t)
+		} else {
+			e.backtrackIncomplete(right, s, r)
+			e.backtrackSiblings(r, t)
+		}
+	} else {
+		e.heads[s] = t
+		if r == t {
+			e.backtrackComplete(right, s, t-1)
+		} else {
+			e.backtrackSiblings(s, r)
+			e.backtrackIncomplete(left, r, t)
+		}
+	}
+}
+
+func (e *eisnerChart) backtrackSiblings(s, t int) {
+	q := e.siblingsSplit[s][t]
+	e.backtrackComplete(right, s, q)
+	e.backtrackComplete(left, q+1, t)
+}
+
+func newFloatChart(n int) [][]float64 {
+	chart := make([][]float64, n)
+	for i := range chart {
+		chart[i] = make([]float64, n)
+	}
+	return chart
+}
+
+func newIntChart(n int) [][]int {
+	chart := make([][]int, n)
+	for i := range chart {
+		chart[i] = make([]int, n)
+	}
+	return chart
+}
+
+func verifySquare(scores [][]float64) {
+	for h, row := range scores {
+		if len(row) != len(scores) {
+			panic(fmt.Sprintf("Scores of head %d have %d modifiers, expected %d", h, len(row), len(scores)))
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package graph
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestChuLiuEdmonds(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 300; i++ {
+		scores := randomScores(r, 1+r.Intn(6))
+		heads := ChuLiuEdmonds(scores)
+		verifyTree(t, heads, false)
+		best := bruteForce(scores, nil, false)
+		if score := treeScore(scores, nil, heads); math.Abs(score-best) > 1e-9 {
+			t.Fatalf("Got tree %v scoring %v, best is %v", heads, score, best)
+		}
+	}
+}
+
+func TestEisner(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 300; i++ {
+		scores := randomScores(r, 1+r.Intn(6))
+		heads := Eisner(scores)
+		verifyTree(t, heads, true)
+		best := bruteForce(scores, nil, true)
+		if score := treeScore(scores, nil, heads); math.Abs(score-best) > 1e-9 {
+			t.Fatalf("Got tree %v scoring %v, best is %v", heads, score, best)
+		}
+	}
+}
+
+func TestEisnerSecondOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for i := 0; i < 300; i++ {
+		n := 1 + r.Intn(6)
+		scores, siblingScores := randomScores(r, n), make(map[[3]int]float64)
+		sibling := func(h, s, m int) float64 {
+			key := [3]int{h, s, m}
+			if _, exists := siblingScores[key]; !exists {
+				siblingScores[key] = r.Float64()*4 - 2
+			}
+			return siblingScores[key]
+		}
+		heads := EisnerSecondOrder(scores, sibling)
+		verifyTree(t, heads, true)
+		best := bruteForce(scores, sibling, true)
+		if score := treeScore(scores, sibling, heads); math.Abs(score-best) > 1e-9 {
+			t.Fatalf("Got tree %v scoring %v, best is %v", heads, score, best)
+		}
+	}
+}
+
+func randomScores(r *rand.Rand, n int) [][]float64 {
+	scores := make([][]float64, n+1)
+	for h := range scores {
+		scores[h] = make([]float64, n+1)
+		for m := range scores[h] {
+			scores[h][m] = r.Float64()*10 - 5
+		}
+	}
+	return scores
+}
+
+// bruteForce returns the score of the best tree of all head assignments
+func bruteForce(scores [][]float64, sibling func(h, s, m int) float64, projective bool) float64 {
+	n := len(scores) - 1
+	heads := make([]int, n+1)
+	heads[0] = -1
+	best := math.Inf(-1)
+	var assign func(m int)
+	assign = func(m int) {
+		if m > n {
+			if isTree(heads) && (!projective || isProjective(heads)) {
+				best = math.Max(best, treeScore(scores, sibling, heads))
+			}
+			return
+		}
+		for h := 0; h <= n; h++ {
+			if h != m {
+				heads[m] = h
+				assign(m + 1)
+			}
+		}
+	}
+	assign(1)
+	return best
+}
+
+func treeScore(scores [][]float64, sibling func(h, s, m int) float64, heads []int) float64 {
+	var score float64
+	for m := 1; m < len(heads); m++ {
+		score += scores[heads[m]][m]
+		if sibling != nil {
+			score += sibling(heads[m], previousSibling(heads, m), m)
+		}
+	}
+	return score
+}
+
+// previousSibling returns the modifier of the head of m between them closest
+// to m, or the head
+func previousSibling(heads []int, m int) int {
+	h := heads[m]
+	step := 1
+	if m < h {
+		step = -1
+	}
+	for s := m - step; s != h; s -= step {
+		if heads[s] == h {
+			return s
+		}
+	}
+	return h
+}
+
+func verifyTree(t *testing.T, heads []int, projective bool) {
+	if !isTree(heads) {
+		t.Fatalf("Heads %v are not a tree with a single root", heads)
+	}
+	if projective && !isProjective(heads) {
+		t.Fatalf("Heads %v are not projective", heads)
+	}
+}
+
+func isTree(heads []int) bool {
+	var roots int
+	for m := 1; m < len(heads); m++ {
+		if heads[m] == 0 {
+			roots++
+		}
+		node := m
+		for steps := 0; node != 0; steps++ {
+			if steps >= len(heads) || node < 0 {
+				return false
+			}
+			node = heads[node]
+		}
+	}
+	return heads[0] == -1 && (len(heads) == 1 || roots == 1)
+}
+
+func isProjective(heads []int) bool {
+	for m := 1; m < len(heads); m++ {
+		from, to := heads[m], m
+		if from > to {
+			from, to = to, from
+		}
+		for node := from + 1; node < to; node++ {
+			ancestor := node
+			for ancestor != heads[m] && ancestor > 0 {
+				ancestor = heads[ancestor]
+			}
+			if ancestor != heads[m] {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package perceptron
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// UpdateStep sets the amount of the update of a wrongly decoded instance,
+// adding it to the weights of the gold features and subtracting it from
+// those of the decoded features
+type UpdateStep interface {
+	Amount(m Model, gold, decoded DecodedInstance, goldFeatures, decodedFeatures interface{}) int64
+}
+
+// MarginModel is a model that measures its updates: Margin returns the
+// score of the decoded features less the score of the gold features, and
+// the squared norm of the difference of their feature vectors
+type MarginModel interface {
+	Model
+	Margin(goldFeatures, decodedFeatures interface{}) (int64, int64)
+}
+
+// CostFunc is the loss of a decoded instance against the gold, such as the
+// number of wrong arcs or morphemes
+type CostFunc func(gold, decoded DecodedInstance) float64
+
+// PA_SCALE is the weight of a perceptron update of 1.0: the weights of
+// passive-aggressive models are fixed point numbers, so that the step sizes,
+// fractions of an update, can be added to their int64 weights
+const PA_SCALE = 1000
+
+// PassiveAggressive is the PA-I and PA-II update (crammer et al. jmlr '06),
+// or 1-best MIRA: the smallest update separating the gold from the decoded
+// instance by a margin of its cost,
+//
+//	tau = min(C, loss / |gold - decoded|^2)            (PA-I)
+//	tau = loss / (|gold - decoded|^2 + 1 / (2 * C))    (PA-II)
+//
+// where loss = score(decoded) - score(gold) + cost(decoded). The model must
+// be a MarginModel.
+type PassiveAggressive struct {
+	// Variant is 1 for PA-I or 2 for PA-II
+	Variant int
+	// C is the aggressiveness, bounding the step sizes of PA-I and
+	// smoothing those of PA-II
+	C float64
+	// Cost is the cost of the decoded instance; it is at least 1 as the
+	// instance is wrong, and 1 if Cost is nil
+	Cost CostFunc
+}
+
+var _ UpdateStep = &PassiveAggressive{}
+
+func (pa *PassiveAggressive) Amount(m Model, gold, decoded DecodedInstance, goldFeatures, decodedFeatures interface{}) int64 {
+	model, ok := m.(MarginModel)
+	if !ok {
+		panic(fmt.Sprintf("Passive-aggressive update requires a MarginModel, got %T", m))
+	}
+	margin, norm := model.Margin(goldFeatures, decodedFeatures)
+	if norm == 0 {
+		// the gold and decoded features are the same, nothing to update
+		return 1
+	}
+	var cost float64 = 1
+	if pa.Cost != nil {
+		cost = math.Max(1, pa.Cost(gold, decoded))
+	}
+	loss := float64(margin)/PA_SCALE + cost
+	if loss <= 0 {
+		return 1
+	}
+	var tau float64
+	switch pa.Variant {
+	case 1:
+		tau = math.Min(pa.C, loss/float64(norm))
+	case 2:
+		tau = loss / (float64(norm) + 1/(2*pa.C))
+	default:
+		panic(fmt.Sprintf("Unknown passive-aggressive variant %d", pa.Variant))
+	}
+	if amount := int64(math.Floor(tau*PA_SCALE + 0.5)); amount > 1 {
+		return amount
+	}
+	return 1
+}
+
+func (pa *PassiveAggressive) String() string {
+	return fmt.Sprintf("PA-%s (C %v)", strings.Repeat("I", pa.Variant), pa.C)
+}
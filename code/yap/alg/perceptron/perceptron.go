@@ -27,6 +27,10 @@ type LinearPerceptron struct {
 	FailedInstances int
 
 	Continue StopCondition
+
+	// Step is the amount of the update of a wrongly decoded instance, 1 if
+	// it is nil
+	Step UpdateStep
 }
 
 var _ SupervisedTrainer = &LinearPerceptron{}
@@ -71,6 +75,9 @@ func (m *LinearPerceptron) train(goldInstances []DecodedInstance, decoder EarlyU
 	for i := m.TrainI; m.Continue(i, iterations, generations, m.Model); i++ {
 		logPrefix = "IT #" + fmt.Sprintf("%v ", i) + prevPrefix
 		log.SetPrefix(logPrefix)
+		if iterationDecoder, ok := m.GoldDecoder.(IterationDecoder); ok {
+			iterationDecoder.SetIteration(i)
+		}
 		// log.Println("Starting iteration", i)
 		if PercepAllOut {
 			log.SetPrefix("")
@@ -136,14 +143,18 @@ func (m *LinearPerceptron) train(goldInstances []DecodedInstance, decoder EarlyU
 					// 	panic("Decode failed but got nil decode model")
 					// }
 				}
+				var amount int64 = 1
+				if m.Step != nil {
+					amount = m.Step.Amount(m.Model, goldDecoded, decodedInstance, goldFeatures, decodedFeatures)
+				}
 				if PercepAllOut {
-					log.Println("Score 1 to")
+					log.Println("Score", amount, "to")
 				}
-				m.Model.AddSubtract(goldFeatures, decodedFeatures, 1.0)
+				m.Model.AddSubtract(goldFeatures, decodedFeatures, amount)
 				if PercepAllOut {
-					log.Println("Score -1 to")
+					log.Println("Score", -amount, "to")
 				}
-				m.Model.AddSubtract(decodedFeatures, decodedFeatures, -1.0)
+				m.Model.AddSubtract(decodedFeatures, decodedFeatures, -amount)
 				if PercepAllOut {
 					log.Println("ITERATION COMPLETE")
 				}
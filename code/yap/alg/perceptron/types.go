@@ -79,6 +79,12 @@ type InstanceDecoder interface {
 	DecodeGold(i DecodedInstance, m Model) (DecodedInstance, interface{})
 }
 
+// IterationDecoder is implemented by gold decoders whose output changes
+// with the training iteration
+type IterationDecoder interface {
+	SetIteration(int)
+}
+
 type EarlyUpdateInstanceDecoder interface {
 	DecodeEarlyUpdate(i DecodedInstance, m Model) (decoded DecodedInstance, decodedFeatures, goldFeatures interface{}, earlyUpdatedAt, goldSize int, decodeScore float64)
 }
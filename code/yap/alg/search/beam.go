@@ -4,6 +4,7 @@ import (
 	"container/heap"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -60,6 +61,7 @@ type Beam struct {
 }
 
 var _ Interface = &Beam{}
+var _ Ranked = &Beam{}
 var _ perceptron.EarlyUpdateInstanceDecoder = &Beam{}
 
 func (b *Beam) Name() string {
@@ -369,6 +371,23 @@ func (b *Beam) Top(a Agenda) Candidate {
 	return bestCandidate
 }
 
+// Ranked returns copies of the k best candidates of the agenda, best first
+func (b *Beam) Ranked(a Agenda, k int) []Candidate {
+	confs := a.(*BaseAgenda).Confs
+	ranked := make([]*ScoredConfiguration, len(confs))
+	copy(ranked, confs)
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Score() > ranked[j].Score() })
+	if len(ranked) > k {
+		ranked = ranked[:k]
+	}
+	candidates := make([]Candidate, len(ranked))
+	for i, candidate := range ranked {
+		candidate.Expand(b.TransFunc)
+		candidates[i] = candidate.Copy()
+	}
+	return candidates
+}
+
 func (b *Beam) SetEarlyUpdate(i int) {
 	b.EarlyUpdateAt = i
 }
@@ -450,6 +469,53 @@ func (b *Beam) Parse(problem Problem) (transition.Configuration, interface{}) {
 	return beamScored.C, resultParams
 }
 
+// ParseKBest returns up to k of the best final configurations of the beam
+// and their model scores, best first. Configurations with the same key are
+// the same parse reached by different transition sequences, only the best
+// of which is kept; a nil key keeps them all.
+func (b *Beam) ParseKBest(problem Problem, k int, key func(transition.Configuration) string) ([]transition.Configuration, []float64) {
+	start := time.Now()
+	// the search only ranks the final beam for a top k of 2 or more
+	topK := b.Size
+	if k > topK {
+		topK = k
+	}
+	if topK < 2 {
+		topK = 2
+	}
+	candidates := SearchKBest(b, problem, b.Size, topK)
+	var (
+		confs  []transition.Configuration = make([]transition.Configuration, 0, k)
+		scores []float64                  = make([]float64, 0, k)
+		seen   map[string]bool            = make(map[string]bool, k)
+	)
+	for _, candidate := range candidates {
+		scored := candidate.(*ScoredConfiguration)
+		if !scored.C.Terminal() {
+			continue
+		}
+		if key != nil {
+			confKey := key(scored.C)
+			if seen[confKey] {
+				continue
+			}
+			seen[confKey] = true
+		}
+		confs = append(confs, scored.C)
+		scores = append(scores, scored.Score())
+		if len(confs) == k {
+			break
+		}
+	}
+	if len(confs) == 0 && len(candidates) > 0 {
+		// the search stopped before reaching a final configuration
+		best := candidates[0].(*ScoredConfiguration)
+		confs, scores = append(confs, best.C), append(scores, best.Score())
+	}
+	b.DurTotal += time.Since(start)
+	return confs, scores
+}
+
 func (b *Beam) DecodeEarlyUpdate(goldInstance perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}, interface{}, int, int, float64) {
 	b.EarlyUpdateAt = -1
 	start := time.Now()
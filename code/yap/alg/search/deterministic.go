@@ -3,6 +3,7 @@ package search
 import (
 	"fmt"
 	"log"
+	"math/rand"
 	"sort"
 	"yap/alg/featurevector"
 	"yap/alg/perceptron"
@@ -26,9 +27,22 @@ type Deterministic struct {
 	NoRecover          bool
 	TransEnum          *util.EnumSet
 	DefaultTransType  byte
+
+	// Explore is the probability of following a wrong prediction of the
+	// model when generating the gold sequences with a dynamic oracle, from
+	// iteration ExploreAfter on; 0 follows the oracle alone
+	Explore      float64
+	ExploreAfter int
+	iteration    int
+	random       *rand.Rand
 }
 
 var _ perceptron.InstanceDecoder = &Deterministic{}
+var _ perceptron.IterationDecoder = &Deterministic{}
+
+func (d *Deterministic) SetIteration(i int) {
+	d.iteration = i
+}
 
 // Parser functions
 func (d *Deterministic) Parse(problem Problem) (transition.Configuration, interface{}) {
@@ -92,9 +106,21 @@ func (d *Deterministic) ParseOracle(gold perceptron.DecodedInstance) (configurat
 	if SHOW_ORACLE {
 		log.Println(c.String())
 	}
+	// the gold sequence, initial configuration first, takes the oracle's
+	// transitions also where c follows an explored prediction of the model
+	var (
+		sequence = transition.ConfigurationSequence{c}
+		explored bool
+	)
 	for !c.Terminal() {
-		transition := oracle.Transition(c)
-		c = d.TransFunc.Transition(c, transition)
+		goldTransition, followed := d.oracleTransition(oracle, c)
+		next := d.TransFunc.Transition(c, goldTransition)
+		sequence = append(sequence, next)
+		if followed != goldTransition {
+			next = d.TransFunc.Transition(c, followed)
+			explored = true
+		}
+		c = next
 		if SHOW_ORACLE {
 			log.Println(c.String())
 		}
@@ -110,6 +136,13 @@ func (d *Deterministic) ParseOracle(gold perceptron.DecodedInstance) (configurat
 		}
 		if d.ReturnSequence {
 			resultParams.Sequence = c.GetSequence()
+			if explored {
+				// in the order of GetSequence, last configuration first
+				for i, j := 0, len(sequence)-1; i < j; i, j = i+1, j-1 {
+					sequence[i], sequence[j] = sequence[j], sequence[i]
+				}
+				resultParams.Sequence = sequence
+			}
 		}
 	}
 	configuration = c
@@ -117,6 +150,53 @@ func (d *Deterministic) ParseOracle(gold perceptron.DecodedInstance) (configurat
 	return
 }
 
+// oracleTransition returns the oracle's transition at c, and the transition
+// to follow from c. They differ when exploring: the gold transition is the
+// model's best among the oracle's best transitions, and the model's best
+// transition is followed if the oracle finds no better one, or with
+// probability Explore if it does.
+func (d *Deterministic) oracleTransition(oracle transition.Oracle, c transition.Configuration) (transition.Transition, transition.Transition) {
+	dynamic, isDynamic := oracle.(transition.DynamicOracle)
+	if !isDynamic || d.Explore <= 0 || d.Model == nil || d.iteration < d.ExploreAfter {
+		gold := oracle.Transition(c)
+		return gold, gold
+	}
+	transType, transitions, costs := dynamic.Costs(c)
+	if len(transitions) == 0 {
+		gold := oracle.Transition(c)
+		return gold, gold
+	}
+	minCost := costs[0]
+	for _, cost := range costs {
+		if cost < minCost {
+			minCost = cost
+		}
+	}
+	var (
+		predicted, best           int = -1, -1
+		predictedScore, bestScore int64
+	)
+	features := d.FeatExtractor.Features(c, false, transType, nil)
+	for i, t := range transitions {
+		score := d.Model.TransitionScore(transition.ConstTransition(t), features)
+		if predicted < 0 || score > predictedScore {
+			predicted, predictedScore = i, score
+		}
+		if costs[i] == minCost && (best < 0 || score > bestScore) {
+			best, bestScore = i, score
+		}
+	}
+	if d.random == nil {
+		d.random = rand.New(rand.NewSource(1))
+	}
+	gold := &transition.TypedTransition{transType, transitions[best]}
+	// the model's best transition is wrong unless it's the gold one
+	if predicted == best || d.random.Float64() >= d.Explore {
+		return gold, gold
+	}
+	return gold, &transition.TypedTransition{transType, transitions[predicted]}
+}
+
 func (d *Deterministic) ParseOracleEarlyUpdate(sent nlp.Sentence, gold transition.ConfigurationSequence, constraints interface{}, model dependency.ParameterModel) (transition.Configuration, transition.Configuration, interface{}, interface{}, int) {
 	if constraints != nil {
 		panic("Got non-nil constraints; deterministic dependency parsing does not consider constraints")
@@ -181,6 +261,9 @@ func (d *Deterministic) Decode(instance perceptron.Instance, m perceptron.Model)
 
 func (d *Deterministic) DecodeGold(goldInstance perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}) {
 	d.ReturnModelValue = true
+	if model, ok := m.(TransitionModel.Interface); ok {
+		d.Model = model
+	}
 	_, goldParams := d.ParseOracle(goldInstance)
 	// if !graph.Equal(parsedGraph) {
 	// if !parsedGraph.Equal(graph) {
@@ -215,7 +298,13 @@ func (d *Deterministic) DecodeGold(goldInstance perceptron.DecodedInstance, m pe
 			// nextTransition = append(nextTransition, int(val.GetLastTransition()))
 			// d.FeatExtractor.SetLog(true)
 			// log.Println("Features")
-			curFeats = d.FeatExtractor.Features(val, false, nextTransitionType, nextTransition)
+			// the features of the configuration the next transition was taken
+			// in, which isn't val where the oracle explored
+			from := val
+			if i > 0 {
+				from = seq[i-1].Previous()
+			}
+			curFeats = d.FeatExtractor.Features(from, false, nextTransitionType, nextTransition)
 			// d.FeatExtractor.SetLog(false)
 			// log.Println("Features")
 			// log.Println(curFeats)
@@ -56,6 +56,12 @@ type Interface interface {
 	Aligned() bool
 }
 
+// Ranked is implemented by searches that can rank the candidates of their
+// final agenda
+type Ranked interface {
+	Ranked(a Agenda, k int) []Candidate
+}
+
 type IdleFunc func(c Candidate, candidateNum int) Candidate
 
 type Idle interface {
@@ -63,16 +69,26 @@ type Idle interface {
 }
 
 func Search(b Interface, problem Problem, B int) Candidate {
-	candidate, _ := search(b, problem, B, 1, false, nil)
+	candidate, _, _ := search(b, problem, B, 1, false, nil)
 	return candidate
 }
 
+// SearchKBest returns up to topK candidates of the final agenda, best first;
+// b must implement Ranked
+func SearchKBest(b Interface, problem Problem, B, topK int) []Candidate {
+	_, _, ranked := search(b, problem, B, topK, false, nil)
+	return ranked
+}
+
 func SearchEarlyUpdate(b Interface, problem Problem, B int, goldSequence Candidates) (Candidate, Candidate) {
-	return search(b, problem, B, 1, true, goldSequence)
+	best, goldValue, _ := search(b, problem, B, 1, true, goldSequence)
+	return best, goldValue
 }
 
-func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSequence Candidates) (Candidate, Candidate) {
+func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSequence Candidates) (Candidate, Candidate, []Candidate) {
 	var (
+		ranked []Candidate
+
 		goldValue Candidate
 		best      Candidate
 		agenda    Agenda
@@ -322,8 +338,11 @@ func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSeq
 	}
 	if !earlyUpdate {
 		best = b.Best(agenda)
+		if topK > 1 {
+			ranked = b.(Ranked).Ranked(agenda, topK)
+		}
 	}
 	best = best.Copy()
 	agenda = b.Clear(agenda)
-	return best, goldValue
+	return best, goldValue, ranked
 }
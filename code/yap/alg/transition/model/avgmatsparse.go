@@ -9,6 +9,7 @@ import (
 	"yap/util"
 
 	"log"
+	"math"
 	"strings"
 	"sync"
 )
@@ -17,6 +18,8 @@ func init() {
 	gob.Register(&AvgMatrixSparseSerialized{})
 	gob.Register(make(map[interface{}][]int64))
 	gob.Register(make(map[interface{}]map[int]int64))
+	gob.Register(make(map[interface{}]map[int]int32))
+	gob.Register(make(map[interface{}]map[int]int16))
 	gob.Register([2]interface{}{})
 	gob.Register([3]interface{}{})
 	gob.Register([4]interface{}{})
@@ -39,6 +42,9 @@ type AvgMatrixSparse struct {
 	Formatters           []util.Format
 	Log                  bool
 	Extractor            *transition.GenericExtractor
+	// Flat holds the weights of models loaded from a flat (memory mapped)
+	// model file instead of Mat, for parsing only
+	Flat *FlatMatrix
 	// Classifier           TransitionClassifier
 }
 
@@ -46,10 +52,19 @@ type AvgMatrixSparseSerialized struct {
 	Generation int
 	Features   []string
 	Mat        []interface{}
+
+	// Quantization is the number of bits weights were quantized to (0 if
+	// they weren't), and Scale the factor restoring their original range
+	Quantization int
+	Scale        float64
+
+	// flat is set for models read from a flat model file, which have no Mat
+	flat *FlatMatrix
 }
 
 var _ perceptron.Model = &AvgMatrixSparse{}
 var _ Interface = &AvgMatrixSparse{}
+var _ perceptron.MarginModel = &AvgMatrixSparse{}
 
 func (t *AvgMatrixSparse) Score(features interface{}) int64 {
 	var (
@@ -67,7 +82,7 @@ func (t *AvgMatrixSparse) Score(features interface{}) int64 {
 	intTrans = lastTransition.Value()
 	for i, feature := range featuresList.Features {
 		if feature != nil {
-			retval += t.Mat[i].Value(intTrans, feature)
+			retval += t.value(i, intTrans, feature)
 		}
 	}
 	return prevScore + retval
@@ -113,6 +128,63 @@ func (t *AvgMatrixSparse) AddSubtract(goldFeatures, decodedFeatures interface{},
 	wg.Wait()
 }
 
+// Margin returns the score of the decoded features less the score of the
+// gold features, and the squared norm of the difference of their feature
+// vectors, over the transitions AddSubtract(gold, decoded) and
+// AddSubtract(decoded, decoded) update
+func (t *AvgMatrixSparse) Margin(goldFeatures, decodedFeatures interface{}) (int64, int64) {
+	var (
+		counts       = make(map[featureKey]int64)
+		margin, norm int64
+	)
+	g := goldFeatures.(*transition.FeaturesList)
+	f := decodedFeatures.(*transition.FeaturesList)
+	for gold, decoded := g, f; gold.Previous != nil && decoded.Previous != nil; gold, decoded = gold.Previous, decoded.Previous {
+		t.count(gold, 1, counts)
+	}
+	for decoded := f; decoded.Previous != nil; decoded = decoded.Previous {
+		t.count(decoded, -1, counts)
+	}
+	for key, count := range counts {
+		if count != 0 {
+			margin -= count * t.value(key.row, key.transition, key.feature)
+			norm += count * count
+		}
+	}
+	return margin, norm
+}
+
+// featureKey is a weight of the model
+type featureKey struct {
+	row, transition int
+	feature         interface{}
+}
+
+// count adds amount to the counts of the weights the last transition of the
+// features list updates, as apply does
+func (t *AvgMatrixSparse) count(f *transition.FeaturesList, amount int64, counts map[featureKey]int64) {
+	intTrans := f.Transition.Value()
+	for i, feature := range f.Previous.Features {
+		if feature == nil {
+			continue
+		}
+		switch feat := feature.(type) {
+		case []interface{}:
+			for _, generatedFeat := range feat {
+				counts[featureKey{i, intTrans, generatedFeat}] += amount
+			}
+		case TAF:
+			for generatedFeat, transitions := range feat.GetTransFeatures() {
+				if _, tExists := transitions[intTrans]; tExists {
+					counts[featureKey{i, intTrans, generatedFeat}] += amount
+				}
+			}
+		default:
+			counts[featureKey{i, intTrans, feature}] += amount
+		}
+	}
+}
+
 func (t *AvgMatrixSparse) apply(features interface{}, amount int64) perceptron.Model {
 	var (
 		intTrans int
@@ -226,7 +298,7 @@ func (t *AvgMatrixSparse) TransitionScore(transition transition.Transition, feat
 		intTrans int = transition.Value()
 	)
 
-	if len(features) > len(t.Mat) {
+	if len(features) > t.Features {
 		panic("Got more features than known matrix features")
 	}
 	for i, feat := range features {
@@ -239,10 +311,10 @@ func (t *AvgMatrixSparse) TransitionScore(transition transition.Transition, feat
 			switch f := feat.(type) {
 			case []interface{}:
 				for _, generatedFeat := range f {
-					retval += t.Mat[i].Value(intTrans, generatedFeat)
+					retval += t.value(i, intTrans, generatedFeat)
 				}
 			default:
-				retval += t.Mat[i].Value(intTrans, feat)
+				retval += t.value(i, intTrans, feat)
 			}
 		}
 	}
@@ -261,20 +333,35 @@ func (t *AvgMatrixSparse) SetTransitionScores(features []Feature, scores ScoredS
 			switch f := feat.(type) {
 			case []interface{}:
 				for _, generatedFeat := range f {
-					t.Mat[i].SetScores(generatedFeat, scores, integrated)
+					t.setScores(i, generatedFeat, scores, integrated)
 				}
 			case TAF:
 				for feat, _ := range f.GetTransFeatures() {
-					t.Mat[i].SetScores(feat, scores, integrated)
+					t.setScores(i, feat, scores, integrated)
 				}
 			default:
 				// log.Println("\tSetting scores for feature", i)
-				t.Mat[i].SetScores(feat, scores, integrated)
+				t.setScores(i, feat, scores, integrated)
 			}
 		}
 	}
 }
 
+func (t *AvgMatrixSparse) value(row, transition int, feature interface{}) int64 {
+	if t.Flat != nil {
+		return t.Flat.Value(row, transition, feature)
+	}
+	return t.Mat[row].Value(transition, feature)
+}
+
+func (t *AvgMatrixSparse) setScores(row int, feature interface{}, scores ScoredStore, integrated bool) {
+	if t.Flat != nil {
+		t.Flat.SetScores(row, feature, scores, integrated)
+		return
+	}
+	t.Mat[row].SetScores(feature, scores, integrated)
+}
+
 func (t *AvgMatrixSparse) Serialize(generation int) *AvgMatrixSparseSerialized {
 	serialized := &AvgMatrixSparseSerialized{
 		Generation: t.Generation,
@@ -290,15 +377,194 @@ func (t *AvgMatrixSparse) Serialize(generation int) *AvgMatrixSparseSerialized {
 	return serialized
 }
 
+// Each calls f with every weight of a serialized model: the feature row
+// (the feature's index in its transition type's group), the feature value,
+// and the transition the weight scores; quantized weights are scaled back
+// to their original range
+func (s *AvgMatrixSparseSerialized) Each(f func(row int, feature interface{}, transition int, weight int64)) {
+	if s.flat != nil {
+		s.flat.Each(f)
+		return
+	}
+	for row, val := range s.Mat {
+		eachWeight(val, s.Scale, func(feature interface{}, transition int, weight int64) {
+			f(row, feature, transition, weight)
+		})
+	}
+}
+
+// eachWeight calls f with every weight of a serialized row, unquantized
+// (int64) or quantized to int32 or int16 and multiplied by scale
+func eachWeight(serialized interface{}, scale float64, f func(feature interface{}, transition int, weight int64)) {
+	dequantized := func(weight int64) int64 {
+		if scale == 0 {
+			return weight
+		}
+		return int64(math.Floor(float64(weight)*scale + 0.5))
+	}
+	switch features := serialized.(type) {
+	case map[interface{}]map[int]int64:
+		for feature, weights := range features {
+			for transition, weight := range weights {
+				f(feature, transition, dequantized(weight))
+			}
+		}
+	case map[interface{}]map[int]int32:
+		for feature, weights := range features {
+			for transition, weight := range weights {
+				f(feature, transition, dequantized(int64(weight)))
+			}
+		}
+	case map[interface{}]map[int]int16:
+		for feature, weights := range features {
+			for transition, weight := range weights {
+				f(feature, transition, dequantized(int64(weight)))
+			}
+		}
+	default:
+		panic("Can't iterate unknown serialization")
+	}
+}
+
+// PruneStats counts the weights and features of a serialized model before
+// and after pruning
+type PruneStats struct {
+	Weights, Features             int
+	PrunedWeights, PrunedFeatures int
+}
+
+// Prune drops the weights whose absolute value is below minWeight, and the
+// features whose absolute weights sum to less than minFeature; features that
+// were rarely seen in training are left with small averaged weights
+func (s *AvgMatrixSparseSerialized) Prune(minWeight, minFeature int64) *PruneStats {
+	stats := &PruneStats{}
+	for _, val := range s.Mat {
+		features, ok := val.(map[interface{}]map[int]int64)
+		if !ok {
+			panic("Can't prune unknown serialization")
+		}
+		for feature, weights := range features {
+			stats.Features++
+			stats.Weights += len(weights)
+			var total int64
+			for transition, weight := range weights {
+				if abs(weight) < minWeight {
+					delete(weights, transition)
+					stats.PrunedWeights++
+					continue
+				}
+				total += abs(weight)
+			}
+			if len(weights) == 0 || total < minFeature {
+				stats.PrunedWeights += len(weights)
+				stats.PrunedFeatures++
+				delete(features, feature)
+			}
+		}
+	}
+	return stats
+}
+
+// Quantize rounds the weights of a serialized model to signed integers of
+// the given number of bits (16 or 32), and stores them as such, setting the
+// scale Deserialize multiplies them by
+func (s *AvgMatrixSparseSerialized) Quantize(bits int) error {
+	if bits != 16 && bits != 32 {
+		return fmt.Errorf("can't quantize to %d bits, expected 16 or 32", bits)
+	}
+	if s.Scale != 0 {
+		return fmt.Errorf("model is already quantized to %d bits", s.Quantization)
+	}
+	var max int64
+	s.Each(func(row int, feature interface{}, transition int, weight int64) {
+		if abs(weight) > max {
+			max = abs(weight)
+		}
+	})
+	scale := float64(max) / float64(int64(1)<<uint(bits-1)-1)
+	if scale < 1 {
+		// weights already fit
+		scale = 1
+	}
+	quantized := func(weight int64) int64 {
+		return int64(math.Floor(float64(weight)/scale + 0.5))
+	}
+	for i, val := range s.Mat {
+		features := val.(map[interface{}]map[int]int64)
+		if bits == 16 {
+			narrow := make(map[interface{}]map[int]int16, len(features))
+			for feature, weights := range features {
+				narrow[feature] = make(map[int]int16, len(weights))
+				for transition, weight := range weights {
+					narrow[feature][transition] = int16(quantized(weight))
+				}
+				delete(features, feature)
+			}
+			s.Mat[i] = narrow
+		} else {
+			narrow := make(map[interface{}]map[int]int32, len(features))
+			for feature, weights := range features {
+				narrow[feature] = make(map[int]int32, len(weights))
+				for transition, weight := range weights {
+					narrow[feature][transition] = int32(quantized(weight))
+				}
+				delete(features, feature)
+			}
+			s.Mat[i] = narrow
+		}
+	}
+	s.Quantization, s.Scale = bits, scale
+	return nil
+}
+
+// dequantize returns a copy of a serialized row of quantized weights,
+// scaled back to their original range
+func dequantize(serialized interface{}, scale float64) map[interface{}]map[int]int64 {
+	retval := make(map[interface{}]map[int]int64)
+	eachWeight(serialized, scale, func(feature interface{}, transition int, weight int64) {
+		weights, exists := retval[feature]
+		if !exists {
+			weights = make(map[int]int64)
+			retval[feature] = weights
+		}
+		weights[transition] = weight
+	})
+	return retval
+}
+
+func abs(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// SetFlat makes a serialized model of a flat model file use its weights
+func (s *AvgMatrixSparseSerialized) SetFlat(flat *FlatMatrix) {
+	s.flat = flat
+}
+
+// Flat returns the weights of a serialized model read from a flat model
+// file, or nil; such models can be parsed with but not pruned or trained
+func (s *AvgMatrixSparseSerialized) Flat() *FlatMatrix {
+	return s.flat
+}
+
 func (t *AvgMatrixSparse) Deserialize(data *AvgMatrixSparseSerialized) {
 	t.Generation = data.Generation
+	if data.flat != nil {
+		t.Features = data.flat.Rows()
+		t.Mat, t.Flat = nil, data.flat
+		return
+	}
 	t.Features = len(data.Mat)
 	t.Mat = make([]*AvgSparse, len(data.Mat))
 	// log.Println("Started Deserialization")
 	for i, val := range data.Mat {
 		// log.Println("\tDeserializing", i)
 		avgSparse := &AvgSparse{}
-		avgSparse.Deserialize(val, t.Generation)
+		// quantized weights are scaled as they are read, without a copy
+		avgSparse.DeserializeScaled(val, t.Generation, data.Scale)
 		t.Mat[i] = avgSparse
 	}
 }
@@ -334,7 +600,7 @@ func NewAvgMatrixSparse(features int, formatters []util.Format, dense bool) *Avg
 	for i, _ := range Mat {
 		Mat[i] = MakeAvgSparse(dense)
 	}
-	return &AvgMatrixSparse{Mat, features, 0, formatters, AllOut, nil}
+	return &AvgMatrixSparse{Mat, features, 0, formatters, AllOut, nil, nil}
 }
 
 type AveragedModelStrategy struct {
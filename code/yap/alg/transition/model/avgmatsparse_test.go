@@ -0,0 +1,127 @@
+package model
+
+import (
+	"yap/alg/featurevector"
+	"yap/alg/perceptron"
+	"yap/alg/transition"
+
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+// testSerialized returns a serialized model with weights of every kind of
+// feature value
+func testSerialized() *AvgMatrixSparseSerialized {
+	return &AvgMatrixSparseSerialized{
+		Generation: 7,
+		Features:   []string{"S0|w", "S0|w|p", "N0|t"},
+		Mat: []interface{}{
+			map[interface{}]map[int]int64{
+				"שלום": {0: 12, 3: -5},
+				"":     {1: 1},
+			},
+			map[interface{}]map[int]int64{
+				[2]interface{}{"ילד", 4}:   {2: 100000, 5: -3},
+				[2]interface{}{"ילד", nil}: {2: 8},
+				[3]int{1, -2, 3}:           {0: -1},
+			},
+			map[interface{}]map[int]int64{},
+		},
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	for _, bits := range []int{16, 32} {
+		s := testSerialized()
+		original := make(map[[3]interface{}]int64)
+		s.Each(func(row int, feature interface{}, transition int, weight int64) {
+			original[[3]interface{}{row, feature, transition}] = weight
+		})
+		if err := s.Quantize(bits); err != nil {
+			t.Fatalf("Quantize(%d) failed: %v", bits, err)
+		}
+		for row, val := range s.Mat {
+			switch val.(type) {
+			case map[interface{}]map[int]int16:
+				if bits != 16 {
+					t.Errorf("Got int16 weights in row %d quantized to %d bits", row, bits)
+				}
+			case map[interface{}]map[int]int32:
+				if bits != 32 {
+					t.Errorf("Got int32 weights in row %d quantized to %d bits", row, bits)
+				}
+			default:
+				t.Errorf("Got %T weights in row %d quantized to %d bits", val, row, bits)
+			}
+		}
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+			t.Fatalf("Encoding model quantized to %d bits failed: %v", bits, err)
+		}
+		decoded := &AvgMatrixSparseSerialized{}
+		if err := gob.NewDecoder(&buf).Decode(decoded); err != nil {
+			t.Fatalf("Decoding model quantized to %d bits failed: %v", bits, err)
+		}
+		model := &AvgMatrixSparse{}
+		model.Deserialize(decoded)
+		// weights are restored within the scale (~3 for 100000 in 16 bits)
+		for key, weight := range original {
+			value := model.Mat[key[0].(int)].Vals[key[1]].GetValue(key[2].(int))
+			if value == nil || abs(value.Value-weight) > int64(decoded.Scale) {
+				t.Errorf("Got weight %v for %v quantized to %d bits, expected %v", value, key, bits, weight)
+			}
+		}
+		if err := s.Quantize(bits); err == nil {
+			t.Errorf("Quantizing a model quantized to %d bits didn't fail", bits)
+		}
+	}
+}
+
+// featuresList returns the features list of a single transition
+func featuresList(t int, features ...featurevector.Feature) *transition.FeaturesList {
+	return &transition.FeaturesList{
+		Transition: transition.ConstTransition(t),
+		Previous:   &transition.FeaturesList{Features: features},
+	}
+}
+
+func TestMargin(t *testing.T) {
+	model := NewAvgMatrixSparse(2, nil, true)
+	gold := featuresList(1, "a", []interface{}{"x", "y"})
+	decoded := featuresList(2, "a", "z")
+	if margin, norm := model.Margin(gold, decoded); margin != 0 || norm != 5 {
+		t.Fatalf("Got margin %v norm %v, expected 0 and 5", margin, norm)
+	}
+	model.AddSubtract(gold, decoded, 3)
+	model.AddSubtract(decoded, decoded, -3)
+	if margin, norm := model.Margin(gold, decoded); margin != -15 || norm != 5 {
+		t.Errorf("Got margin %v norm %v, expected -15 and 5", margin, norm)
+	}
+	if margin, norm := model.Margin(decoded, decoded); margin != 0 || norm != 0 {
+		t.Errorf("Got margin %v norm %v of equal features, expected 0 and 0", margin, norm)
+	}
+}
+
+func TestPassiveAggressive(t *testing.T) {
+	for _, pa := range []*perceptron.PassiveAggressive{{Variant: 1, C: 1}, {Variant: 2, C: 1e9}} {
+		model := NewAvgMatrixSparse(2, nil, true)
+		gold := featuresList(1, "a", []interface{}{"x", "y"})
+		decoded := featuresList(2, "a", "z")
+		// the smallest update separating the gold from the decoded features
+		// by the cost, 1
+		amount := pa.Amount(model, nil, nil, gold, decoded)
+		if amount != perceptron.PA_SCALE/5 {
+			t.Errorf("%v: got amount %v, expected %v", pa, amount, perceptron.PA_SCALE/5)
+		}
+		model.AddSubtract(gold, decoded, amount)
+		model.AddSubtract(decoded, decoded, -amount)
+		if margin, _ := model.Margin(gold, decoded); margin != -perceptron.PA_SCALE {
+			t.Errorf("%v: got margin %v after the update, expected %v", pa, margin, -perceptron.PA_SCALE)
+		}
+	}
+	capped := &perceptron.PassiveAggressive{Variant: 1, C: 0.01}
+	if amount := capped.Amount(NewAvgMatrixSparse(2, nil, true), nil, nil, featuresList(1, "a"), featuresList(2, "a")); amount != perceptron.PA_SCALE/100 {
+		t.Errorf("%v: got amount %v, expected %v", capped, amount, perceptron.PA_SCALE/100)
+	}
+}
@@ -0,0 +1,442 @@
+package model
+
+import (
+	. "yap/alg/featurevector"
+
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// A flat matrix is the weights of an AvgMatrixSparse laid out in flat sorted
+// arrays, so that it can be memory mapped read-only and used for parsing
+// without decoding. All integers are little endian uint64s unless noted:
+//
+//	generation, rows, row offsets (rows+1, from the start of the matrix)
+//	and per row:
+//		n, key offsets (n+1, from the start of the keys),
+//		entry offsets (n+1, in entries), keys, entries
+//
+// Keys are the encoded feature values (see appendFeatureKey) in byte order,
+// and the entries of a key are its (uint32 transition, int64 weight) pairs
+// ordered by transition.
+const flatEntrySize = 12
+
+type FlatMatrix struct {
+	Generation int
+	rows       []flatRow
+}
+
+type flatRow struct {
+	n                  int
+	keyOffs, entryOffs []byte
+	keys, entries      []byte
+}
+
+var flatKinds = map[reflect.Kind]reflect.Type{
+	reflect.Int:    reflect.TypeOf(int(0)),
+	reflect.Int8:   reflect.TypeOf(int8(0)),
+	reflect.Int16:  reflect.TypeOf(int16(0)),
+	reflect.Int32:  reflect.TypeOf(int32(0)),
+	reflect.Int64:  reflect.TypeOf(int64(0)),
+	reflect.Uint:   reflect.TypeOf(uint(0)),
+	reflect.Uint8:  reflect.TypeOf(uint8(0)),
+	reflect.Uint16: reflect.TypeOf(uint16(0)),
+	reflect.Uint32: reflect.TypeOf(uint32(0)),
+	reflect.Uint64: reflect.TypeOf(uint64(0)),
+	reflect.String: reflect.TypeOf(""),
+	reflect.Bool:   reflect.TypeOf(false),
+}
+
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// appendFeatureKey appends the encoding of a feature value to buf: its kind
+// followed by its value, and for arrays their element kind, length and
+// elements. It returns false for values that can't be encoded.
+func appendFeatureKey(buf []byte, feature interface{}) ([]byte, bool) {
+	switch v := feature.(type) {
+	case int:
+		return appendVarint(append(buf, byte(reflect.Int)), int64(v)), true
+	case string:
+		buf = appendUvarint(append(buf, byte(reflect.String)), uint64(len(v)))
+		return append(buf, v...), true
+	}
+	return appendValueKey(buf, reflect.ValueOf(feature))
+}
+
+// appendVarint, appendUvarint, appendUint32 and appendUint64 append the
+// encodings of binary.PutVarint, PutUvarint and LittleEndian to buf
+func appendVarint(buf []byte, x int64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	return append(buf, scratch[:binary.PutVarint(scratch[:], x)]...)
+}
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	return append(buf, scratch[:binary.PutUvarint(scratch[:], x)]...)
+}
+
+func appendUint32(buf []byte, x uint32) []byte {
+	var scratch [4]byte
+	binary.LittleEndian.PutUint32(scratch[:], x)
+	return append(buf, scratch[:]...)
+}
+
+func appendUint64(buf []byte, x uint64) []byte {
+	var scratch [8]byte
+	binary.LittleEndian.PutUint64(scratch[:], x)
+	return append(buf, scratch[:]...)
+}
+
+func appendValueKey(buf []byte, v reflect.Value) ([]byte, bool) {
+	kind := v.Kind()
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendVarint(append(buf, byte(kind)), v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return appendUvarint(append(buf, byte(kind)), v.Uint()), true
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, byte(kind), 1), true
+		}
+		return append(buf, byte(kind), 0), true
+	case reflect.String:
+		buf = appendUvarint(append(buf, byte(kind)), uint64(v.Len()))
+		return append(buf, v.String()...), true
+	case reflect.Interface:
+		if v.IsNil() {
+			return append(buf, byte(reflect.Invalid)), true
+		}
+		return appendValueKey(buf, v.Elem())
+	case reflect.Array:
+		elem := v.Type().Elem().Kind()
+		if _, known := flatKinds[elem]; !known && elem != reflect.Interface {
+			return buf, false
+		}
+		buf = appendUvarint(append(buf, byte(kind), byte(elem)), uint64(v.Len()))
+		var ok bool
+		for i := 0; i < v.Len(); i++ {
+			if buf, ok = appendValueKey(buf, v.Index(i)); !ok {
+				return buf, false
+			}
+		}
+		return buf, true
+	}
+	return buf, false
+}
+
+// readFeatureKey decodes a value encoded by appendFeatureKey, returning it
+// and the rest of the key
+func readFeatureKey(key []byte) (reflect.Value, []byte, error) {
+	if len(key) == 0 {
+		return reflect.Value{}, nil, fmt.Errorf("truncated feature key")
+	}
+	kind, key := reflect.Kind(key[0]), key[1:]
+	switch kind {
+	case reflect.Invalid:
+		return reflect.Zero(interfaceType), key, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		val, n := binary.Varint(key)
+		if n <= 0 {
+			return reflect.Value{}, nil, fmt.Errorf("bad integer in feature key")
+		}
+		v := reflect.New(flatKinds[kind]).Elem()
+		v.SetInt(val)
+		return v, key[n:], nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		val, n := binary.Uvarint(key)
+		if n <= 0 {
+			return reflect.Value{}, nil, fmt.Errorf("bad integer in feature key")
+		}
+		v := reflect.New(flatKinds[kind]).Elem()
+		v.SetUint(val)
+		return v, key[n:], nil
+	case reflect.Bool:
+		if len(key) == 0 {
+			return reflect.Value{}, nil, fmt.Errorf("truncated feature key")
+		}
+		return reflect.ValueOf(key[0] == 1), key[1:], nil
+	case reflect.String:
+		length, n := binary.Uvarint(key)
+		if n <= 0 || uint64(len(key)-n) < length {
+			return reflect.Value{}, nil, fmt.Errorf("bad string in feature key")
+		}
+		return reflect.ValueOf(string(key[n : n+int(length)])), key[n+int(length):], nil
+	case reflect.Array:
+		if len(key) == 0 {
+			return reflect.Value{}, nil, fmt.Errorf("truncated feature key")
+		}
+		elemType, known := flatKinds[reflect.Kind(key[0])]
+		if reflect.Kind(key[0]) == reflect.Interface {
+			elemType, known = interfaceType, true
+		}
+		length, n := binary.Uvarint(key[1:])
+		if !known || n <= 0 {
+			return reflect.Value{}, nil, fmt.Errorf("bad array in feature key")
+		}
+		key = key[1+n:]
+		arr := reflect.New(reflect.ArrayOf(int(length), elemType)).Elem()
+		for i := 0; i < int(length); i++ {
+			var (
+				elem reflect.Value
+				err  error
+			)
+			if elem, key, err = readFeatureKey(key); err != nil {
+				return reflect.Value{}, nil, err
+			}
+			arr.Index(i).Set(elem)
+		}
+		return arr, key, nil
+	}
+	return reflect.Value{}, nil, fmt.Errorf("unknown kind %v in feature key", kind)
+}
+
+type flatKey struct {
+	key     []byte
+	weights map[int]int64
+}
+
+// WriteFlat writes the weights of a serialized model as a flat matrix;
+// quantized weights are written scaled back to their original range
+func WriteFlat(writer io.Writer, s *AvgMatrixSparseSerialized) error {
+	rowsData := make([][]byte, len(s.Mat))
+	for i, val := range s.Mat {
+		features, ok := val.(map[interface{}]map[int]int64)
+		if s.Scale != 0 {
+			features, ok = dequantize(val, s.Scale), true
+		}
+		if !ok {
+			return fmt.Errorf("can't flatten unknown serialization")
+		}
+		keys := make([]flatKey, 0, len(features))
+		for feature, weights := range features {
+			key, ok := appendFeatureKey(nil, feature)
+			if !ok {
+				return fmt.Errorf("can't flatten feature %v of type %T", feature, feature)
+			}
+			keys = append(keys, flatKey{key, weights})
+		}
+		sort.Slice(keys, func(a, b int) bool { return bytes.Compare(keys[a].key, keys[b].key) < 0 })
+		rowsData[i] = buildFlatRow(keys)
+	}
+	header := make([]byte, 0, 8*(len(rowsData)+3))
+	header = appendUint64(header, uint64(s.Generation))
+	header = appendUint64(header, uint64(len(rowsData)))
+	offset := uint64(8 * (len(rowsData) + 3))
+	for _, row := range rowsData {
+		header = appendUint64(header, offset)
+		offset += uint64(len(row))
+	}
+	header = appendUint64(header, offset)
+	if _, err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rowsData {
+		if _, err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func buildFlatRow(keys []flatKey) []byte {
+	var keysLen, entries int
+	for _, k := range keys {
+		keysLen += len(k.key)
+		entries += len(k.weights)
+	}
+	n := len(keys)
+	row := make([]byte, 0, 8*(2*n+3)+keysLen+flatEntrySize*entries)
+	row = appendUint64(row, uint64(n))
+	var off uint64
+	for _, k := range keys {
+		row = appendUint64(row, off)
+		off += uint64(len(k.key))
+	}
+	row = appendUint64(row, off)
+	off = 0
+	for _, k := range keys {
+		row = appendUint64(row, off)
+		off += uint64(len(k.weights))
+	}
+	row = appendUint64(row, off)
+	for _, k := range keys {
+		row = append(row, k.key...)
+	}
+	for _, k := range keys {
+		transitions := make([]int, 0, len(k.weights))
+		for transition := range k.weights {
+			transitions = append(transitions, transition)
+		}
+		sort.Ints(transitions)
+		for _, transition := range transitions {
+			row = appendUint32(row, uint32(transition))
+			row = appendUint64(row, uint64(k.weights[transition]))
+		}
+	}
+	return row
+}
+
+// OpenFlat reads the layout of a flat matrix written by WriteFlat; the
+// matrix keeps using data, which is usually a memory mapped file
+func OpenFlat(data []byte) (*FlatMatrix, error) {
+	if len(data) < 16 {
+		return nil, fmt.Errorf("flat matrix too short")
+	}
+	numRows := binary.LittleEndian.Uint64(data[8:])
+	if numRows > uint64(len(data))/8 {
+		return nil, fmt.Errorf("bad number of rows %v in flat matrix", numRows)
+	}
+	m := &FlatMatrix{
+		Generation: int(binary.LittleEndian.Uint64(data)),
+		rows:       make([]flatRow, numRows),
+	}
+	for i := range m.rows {
+		start := binary.LittleEndian.Uint64(data[16+8*i:])
+		end := binary.LittleEndian.Uint64(data[16+8*(i+1):])
+		if start > end || end > uint64(len(data)) || end-start < 8 {
+			return nil, fmt.Errorf("bad offsets of row %d in flat matrix", i)
+		}
+		row := data[start:end]
+		n := binary.LittleEndian.Uint64(row)
+		if n > uint64(len(row))/16 {
+			return nil, fmt.Errorf("bad number of features %v in row %d of flat matrix", n, i)
+		}
+		keysStart := 8 + 16*(n+1)
+		r := flatRow{
+			n:         int(n),
+			keyOffs:   row[8 : 8+8*(n+1)],
+			entryOffs: row[8+8*(n+1) : keysStart],
+		}
+		keysLen := binary.LittleEndian.Uint64(r.keyOffs[8*n:])
+		entries := binary.LittleEndian.Uint64(r.entryOffs[8*n:])
+		if keysStart+keysLen+flatEntrySize*entries != uint64(len(row)) {
+			return nil, fmt.Errorf("bad size of row %d in flat matrix", i)
+		}
+		r.keys = row[keysStart : keysStart+keysLen]
+		r.entries = row[keysStart+keysLen:]
+		m.rows[i] = r
+	}
+	return m, nil
+}
+
+// Rows returns the number of feature rows of the matrix
+func (m *FlatMatrix) Rows() int {
+	return len(m.rows)
+}
+
+func (r *flatRow) key(i int) []byte {
+	return r.keys[binary.LittleEndian.Uint64(r.keyOffs[8*i:]):binary.LittleEndian.Uint64(r.keyOffs[8*(i+1):])]
+}
+
+func (r *flatRow) entriesOf(i int) []byte {
+	start := binary.LittleEndian.Uint64(r.entryOffs[8*i:])
+	end := binary.LittleEndian.Uint64(r.entryOffs[8*(i+1):])
+	return r.entries[flatEntrySize*start : flatEntrySize*end]
+}
+
+// find returns the entries of a feature, or nil if it has no weights
+func (m *FlatMatrix) find(row int, feature interface{}) []byte {
+	var buf [64]byte
+	key, ok := appendFeatureKey(buf[:0], feature)
+	if !ok {
+		return nil
+	}
+	r := &m.rows[row]
+	i := sort.Search(r.n, func(i int) bool { return bytes.Compare(r.key(i), key) >= 0 })
+	if i < r.n && bytes.Equal(r.key(i), key) {
+		return r.entriesOf(i)
+	}
+	return nil
+}
+
+// Value returns the weight of a feature for a transition
+func (m *FlatMatrix) Value(row, transition int, feature interface{}) int64 {
+	store := flatStore{entries: m.find(row, feature)}
+	if i := store.search(transition); i >= 0 {
+		return store.weight(i)
+	}
+	return 0
+}
+
+// SetScores adds the weights of a feature to the scores of its transitions
+func (m *FlatMatrix) SetScores(row int, feature interface{}, scores ScoredStore, integrated bool) {
+	if entries := m.find(row, feature); entries != nil {
+		scores.IncAll(&flatStore{m.Generation, entries}, integrated)
+	}
+}
+
+// Each calls f with every weight of the matrix, like
+// AvgMatrixSparseSerialized.Each
+func (m *FlatMatrix) Each(f func(row int, feature interface{}, transition int, weight int64)) {
+	for i := range m.rows {
+		r := &m.rows[i]
+		for j := 0; j < r.n; j++ {
+			value, _, err := readFeatureKey(r.key(j))
+			if err != nil {
+				panic(err)
+			}
+			feature := value.Interface()
+			store := flatStore{entries: r.entriesOf(j)}
+			for k := 0; k < store.Len(); k++ {
+				f(i, feature, store.transition(k), store.weight(k))
+			}
+		}
+	}
+}
+
+// flatStore is a read-only TransitionScoreStore of a feature's entries
+type flatStore struct {
+	generation int
+	entries    []byte
+}
+
+var _ TransitionScoreStore = &flatStore{}
+
+func (s *flatStore) transition(i int) int {
+	return int(binary.LittleEndian.Uint32(s.entries[flatEntrySize*i:]))
+}
+
+func (s *flatStore) weight(i int) int64 {
+	return int64(binary.LittleEndian.Uint64(s.entries[flatEntrySize*i+4:]))
+}
+
+func (s *flatStore) search(transition int) int {
+	n := s.Len()
+	i := sort.Search(n, func(i int) bool { return s.transition(i) >= transition })
+	if i < n && s.transition(i) == transition {
+		return i
+	}
+	return -1
+}
+
+func (s *flatStore) Add(generation, transition int, feature interface{}, amount int64) {
+	panic("Can't add to a flat model")
+}
+
+func (s *flatStore) Integrate(generation int) {
+}
+
+func (s *flatStore) Len() int {
+	return len(s.entries) / flatEntrySize
+}
+
+func (s *flatStore) SetValue(key int, value *HistoryValue) {
+	panic("Can't set values of a flat model")
+}
+
+func (s *flatStore) GetValue(key int) *HistoryValue {
+	if i := s.search(key); i >= 0 {
+		return NewHistoryValue(s.generation, s.weight(i))
+	}
+	return nil
+}
+
+func (s *flatStore) Each(f TransitionScoreKVFunc) {
+	for i := 0; i < s.Len(); i++ {
+		f(s.transition(i), NewHistoryValue(s.generation, s.weight(i)))
+	}
+}
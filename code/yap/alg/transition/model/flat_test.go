@@ -0,0 +1,94 @@
+package model
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func flatten(t *testing.T, s *AvgMatrixSparseSerialized) *FlatMatrix {
+	var buf bytes.Buffer
+	if err := WriteFlat(&buf, s); err != nil {
+		t.Fatalf("WriteFlat failed: %v", err)
+	}
+	flat, err := OpenFlat(buf.Bytes())
+	if err != nil {
+		t.Fatalf("OpenFlat failed: %v", err)
+	}
+	return flat
+}
+
+func TestFlatValues(t *testing.T) {
+	s := testSerialized()
+	flat := flatten(t, s)
+	if flat.Rows() != len(s.Mat) || flat.Generation != s.Generation {
+		t.Fatalf("Got %v rows of generation %v, expected %v of %v", flat.Rows(), flat.Generation, len(s.Mat), s.Generation)
+	}
+	s.Each(func(row int, feature interface{}, transition int, weight int64) {
+		if got := flat.Value(row, transition, feature); got != weight {
+			t.Errorf("Got weight %v for %v of transition %v in row %v, expected %v", got, feature, transition, row, weight)
+		}
+	})
+	for _, missing := range []struct {
+		row, transition int
+		feature         interface{}
+	}{
+		{0, 1, "שלום"},
+		{0, 0, "ילד"},
+		{1, 2, [2]interface{}{"ילד", 5}},
+		{1, 0, [3]interface{}{1, -2, 3}},
+		{2, 0, 1},
+	} {
+		if got := flat.Value(missing.row, missing.transition, missing.feature); got != 0 {
+			t.Errorf("Got weight %v for missing %v of transition %v in row %v", got, missing.feature, missing.transition, missing.row)
+		}
+	}
+}
+
+func TestFlatEach(t *testing.T) {
+	s := testSerialized()
+	expected := make([]map[interface{}]map[int]int64, len(s.Mat))
+	for i := range expected {
+		expected[i] = make(map[interface{}]map[int]int64)
+	}
+	collect := func(into []map[interface{}]map[int]int64) func(int, interface{}, int, int64) {
+		return func(row int, feature interface{}, transition int, weight int64) {
+			if into[row][feature] == nil {
+				into[row][feature] = make(map[int]int64)
+			}
+			into[row][feature][transition] = weight
+		}
+	}
+	s.Each(collect(expected))
+	flattened := &AvgMatrixSparseSerialized{}
+	flattened.SetFlat(flatten(t, s))
+	got := make([]map[interface{}]map[int]int64, len(s.Mat))
+	for i := range got {
+		got[i] = make(map[interface{}]map[int]int64)
+	}
+	flattened.Each(collect(got))
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Got weights %v, expected %v", got, expected)
+	}
+}
+
+func TestFlatQuantized(t *testing.T) {
+	s := testSerialized()
+	if err := s.Quantize(16); err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+	flat := flatten(t, s)
+	if got := flat.Value(1, 2, [2]interface{}{"ילד", 4}); got != 100000 {
+		t.Errorf("Got weight %v of quantized model, expected 100000", got)
+	}
+}
+
+func TestOpenFlatTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFlat(&buf, testSerialized()); err != nil {
+		t.Fatalf("WriteFlat failed: %v", err)
+	}
+	if _, err := OpenFlat(buf.Bytes()[:buf.Len()-1]); err == nil {
+		t.Error("OpenFlat of a truncated matrix didn't fail")
+	}
+}
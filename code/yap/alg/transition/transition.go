@@ -126,6 +126,14 @@ type Oracle interface {
 	Name() string
 }
 
+// DynamicOracle is an Oracle that can score any configuration, also one off
+// the gold path: Costs returns the transitions possible in conf and, for
+// each, the number of gold arcs it makes unreachable
+type DynamicOracle interface {
+	Oracle
+	Costs(conf Configuration) (transType byte, transitions []int, costs []int)
+}
+
 func (seq ConfigurationSequence) String() string {
 	var buf bytes.Buffer
 	w := new(tabwriter.Writer)
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+var outAgree string
+
+func AgreeCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       JointTrainAndParse,
+		UsageLine: "agree <file options> [arguments]",
+		Short:     "reports numeral-noun gender agreement errors in joint parses",
+		Long: `
+runs the joint parser and reports cardinal numerals whose gender doesn't agree
+with the noun they quantify, with the suggested numeral form
+
+	$ ./yap agree -in <input lat> -oa <out report> [options]
+	$ ./yap agree -text <text file> -oa <out report> [options]
+
+each line of the report holds: sentence, token, token range, token form,
+numeral morpheme id, form and gender, noun morpheme id, form and gender,
+suggested numeral and suggested token
+
+`,
+		Flag: *flag.NewFlagSet("agree", flag.ExitOnError),
+	}
+	jointFlags(cmd)
+	cmd.Flag.StringVar(&outAgree, "oa", "", "Output Agreement Report File")
+	return cmd
+}
@@ -28,6 +28,9 @@ var AppCommands = []*commander.Command{
 	DepCmd(),
 	MdCmd(),
 	JointCmd(),
+	AgreeCmd(),
+	HebGenerateCmd(),
+	ModelCmd(),
 	//MALearnCmd(),
 	MACmd(),
 	HebMACmd(),
@@ -45,13 +48,25 @@ func AllCommands() *commander.Command {
 		Flag:        *flag.NewFlagSet("app", flag.ExitOnError),
 	}
 	for _, app := range cmd.Subcommands {
-		app.Run = NewAppWrapCommand(app.Run)
-		app.Flag.IntVar(&CPUs, NUM_CPUS_FLAG, 0, "Max CPUS to use (runtime.GOMAXPROCS); 0 = all")
-		app.Flag.StringVar(&CPUProfile, "cpuprofile", "", "write cpu profile to file")
+		wrapCommand(app)
 	}
 	return cmd
 }
 
+// wrapCommand wraps a runnable command, or the subcommands of a command
+// group such as model
+func wrapCommand(app *commander.Command) {
+	if !app.Runnable() {
+		for _, sub := range app.Subcommands {
+			wrapCommand(sub)
+		}
+		return
+	}
+	app.Run = NewAppWrapCommand(app.Run)
+	app.Flag.IntVar(&CPUs, NUM_CPUS_FLAG, 0, "Max CPUS to use (runtime.GOMAXPROCS); 0 = all")
+	app.Flag.StringVar(&CPUProfile, "cpuprofile", "", "write cpu profile to file")
+}
+
 func InitCommand() {
 	maxCPUs := runtime.NumCPU()
 	if CPUs > maxCPUs {
@@ -0,0 +1,222 @@
+package app
+
+import (
+	"yap/alg/search"
+	"yap/alg/transition"
+	"yap/nlp/format/conllu"
+	"yap/nlp/parser/constraint"
+	"yap/nlp/parser/disambig"
+	nlp "yap/nlp/types"
+
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+)
+
+var (
+	// WithConfidence adds confidence estimates to the output (-conf)
+	WithConfidence bool
+	// ConfTemperature scales the score differences between the candidates
+	// of the final beam when weighing them (-conftemp); 0 scales by the
+	// standard deviation of their scores
+	ConfTemperature float64
+)
+
+// Confidence is the share of the final beam's mass agreeing with a parse:
+// with the segmentation of each token (by mapping), and with the head and
+// label of each morpheme (by node) together with its token's segmentation.
+// Parses without segmentation have no Tokens; parses without arcs have the
+// segmentation confidence of their token for each morpheme.
+type Confidence struct {
+	Tokens    []float64
+	Morphemes []float64
+}
+
+// decisions are the segmentation of every token and the attachment of every
+// morpheme of a parse. Morphemes are named by their token and position in
+// it so that they compare equal between parses segmenting other tokens
+// differently.
+type decisions struct {
+	segmentations []string
+	morphemes     []string
+	morphTokens   []int
+	attachments   map[string]string
+}
+
+func parseDecisions(conf transition.Configuration) *decisions {
+	d := &decisions{attachments: make(map[string]string)}
+	var mappings nlp.Mappings
+	switch c := conf.(type) {
+	case *disambig.MDConfig:
+		mappings = c.Mappings
+	case nlp.MorphDependencyGraph:
+		mappings = c.GetMappings()
+	}
+	for i, mapping := range mappings {
+		d.segmentations = append(d.segmentations, mapping.Spellout.String())
+		for j := range mapping.Spellout {
+			d.morphemes = append(d.morphemes, fmt.Sprintf("%d.%d", i, j))
+			d.morphTokens = append(d.morphTokens, i)
+		}
+	}
+	if _, isMD := conf.(*disambig.MDConfig); isMD {
+		return d
+	}
+	graph, isGraph := conf.(nlp.LabeledDependencyGraph)
+	if !isGraph {
+		return d
+	}
+	if mappings == nil {
+		// dependency parses don't segment, every node is a token
+		for _, node := range graph.GetVertices() {
+			d.morphemes = append(d.morphemes, strconv.Itoa(node))
+			d.morphTokens = append(d.morphTokens, -1)
+		}
+	}
+	for _, arcID := range graph.GetEdges() {
+		arc := graph.GetLabeledArc(arcID)
+		if arc == nil || arc.GetModifier() < 0 || arc.GetModifier() >= len(d.morphemes) {
+			continue
+		}
+		head := "root"
+		if string(arc.GetRelation()) != nlp.ROOT_LABEL && arc.GetHead() >= 0 && arc.GetHead() < len(d.morphemes) {
+			head = d.morphemes[arc.GetHead()]
+		}
+		d.attachments[d.morphemes[arc.GetModifier()]] = head + "|" + string(arc.GetRelation())
+	}
+	return d
+}
+
+// agrees tells whether d has the same segmentation of the token of
+// morpheme m of other and the same attachment of it
+func (d *decisions) agrees(other *decisions, m int) bool {
+	if token := other.morphTokens[m]; token >= 0 {
+		if token >= len(d.segmentations) || d.segmentations[token] != other.segmentations[token] {
+			return false
+		}
+	}
+	name := other.morphemes[m]
+	return d.attachments[name] == other.attachments[name]
+}
+
+// beamWeights weighs the candidates of the final beam by the softmax of
+// their scores
+func beamWeights(scores []float64) []float64 {
+	var maxScore, mean, variance float64 = math.Inf(-1), 0, 0
+	for _, score := range scores {
+		maxScore = math.Max(maxScore, score)
+		mean += score / float64(len(scores))
+	}
+	temperature := ConfTemperature
+	if temperature <= 0 {
+		for _, score := range scores {
+			variance += (score - mean) * (score - mean) / float64(len(scores))
+		}
+		temperature = math.Sqrt(variance)
+		if temperature == 0 {
+			temperature = 1
+		}
+	}
+	weights := make([]float64, len(scores))
+	for i, score := range scores {
+		weights[i] = math.Exp((score - maxScore) / temperature)
+	}
+	return weights
+}
+
+// BeamConfidence estimates the confidence of the first of the final
+// candidates of a beam (the parse) given the candidates and their scores;
+// with no candidates there are no estimates
+func BeamConfidence(candidates []transition.Configuration, scores []float64) *Confidence {
+	if len(candidates) == 0 {
+		return &Confidence{}
+	}
+	weights := beamWeights(scores)
+	parse := parseDecisions(candidates[0])
+	conf := &Confidence{
+		Tokens:    make([]float64, len(parse.segmentations)),
+		Morphemes: make([]float64, len(parse.morphemes)),
+	}
+	var total float64
+	for i, candidate := range candidates {
+		decided := parse
+		if i > 0 {
+			decided = parseDecisions(candidate)
+		}
+		total += weights[i]
+		for t, segmentation := range parse.segmentations {
+			if t < len(decided.segmentations) && decided.segmentations[t] == segmentation {
+				conf.Tokens[t] += weights[i]
+			}
+		}
+		for m := range parse.morphemes {
+			if decided.agrees(parse, m) {
+				conf.Morphemes[m] += weights[i]
+			}
+		}
+	}
+	for t := range conf.Tokens {
+		conf.Tokens[t] /= total
+	}
+	for m := range conf.Morphemes {
+		conf.Morphemes[m] /= total
+	}
+	return conf
+}
+
+// ParseConfidence parses every instance as ParseConstrained does, and
+// estimates the confidence of each parse from the final candidates of the
+// beam
+func ParseConfidence(instances []interface{}, beam *search.Beam, constraints []*constraint.Sentence) ([]interface{}, []*Confidence) {
+	startTime := time.Now()
+	parsed := make([]interface{}, len(instances))
+	confs := make([]*Confidence, len(instances))
+	for i, instance := range instances {
+		log.Println("Parsing instance", i)
+		constrained := Constrain(beam, sentenceConstraints(constraints, i))
+		candidates, scores := constrained.ParseKBest(instance, beam.Size, nil)
+		if len(candidates) == 0 {
+			// the search returned no candidates to estimate from
+			parsed[i], _ = constrained.Parse(instance)
+			confs[i] = BeamConfidence(nil, nil)
+			continue
+		}
+		parsed[i] = candidates[0]
+		confs[i] = BeamConfidence(candidates, scores)
+	}
+	if allOut {
+		log.Println("PARSE Total Time:", time.Since(startTime))
+	}
+	return parsed, confs
+}
+
+func confMisc(conf float64) string {
+	return "Conf=" + strconv.FormatFloat(conf, 'f', 3, 64)
+}
+
+// AddConfidenceConllU adds the confidence of every morpheme to the MISC of
+// its row, and of every token segmented to several morphemes to the MISC of
+// its range line
+func AddConfidenceConllU(sents []interface{}, confs []*Confidence) {
+	for i, genericSent := range sents {
+		sent := genericSent.(conllu.Sentence)
+		conf := confs[i]
+		for m, morphConf := range conf.Morphemes {
+			if row, exists := sent.Deps[m+1]; exists {
+				row.Misc = conllu.AddMisc(row.Misc, confMisc(morphConf))
+				sent.Deps[m+1] = row
+			}
+		}
+		if len(conf.Tokens) > 0 {
+			sent.TokenMisc = make([]string, len(sent.Mappings))
+			for t, tokenConf := range conf.Tokens {
+				if t < len(sent.TokenMisc) {
+					sent.TokenMisc[t] = confMisc(tokenConf)
+				}
+			}
+		}
+		sents[i] = sent
+	}
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"yap/alg/search"
+	"yap/nlp/parser/constraint"
+
+	"log"
+	"time"
+)
+
+// ConstraintsFile holds constraints on the parses of the input sentences,
+// one block per sentence (-constraints)
+var ConstraintsFile string
+
+// ReadConstraints reads the constraints file, if given; the input has
+// numSentences sentences
+func ReadConstraints(numSentences int) []*constraint.Sentence {
+	if len(ConstraintsFile) == 0 {
+		return nil
+	}
+	constraints, err := constraint.ReadFile(ConstraintsFile)
+	if err != nil {
+		log.Fatalln("Failed reading constraints file", ConstraintsFile, err)
+	}
+	if len(constraints) != numSentences {
+		log.Println("Warning: read constraints of", len(constraints), "sentences from", ConstraintsFile, "for", numSentences, "input sentences")
+	}
+	log.Println("Read constraints of", len(constraints), "sentences from", ConstraintsFile)
+	return constraints
+}
+
+// sentenceConstraints returns the constraints of the i-th sentence, nil if
+// there are none
+func sentenceConstraints(constraints []*constraint.Sentence, i int) *constraint.Sentence {
+	if i < len(constraints) {
+		return constraints[i]
+	}
+	return nil
+}
+
+// Constrain returns a copy of the beam whose transitions are restricted to
+// those consistent with the constraints, or the beam itself if there are
+// none
+func Constrain(beam *search.Beam, constraints *constraint.Sentence) *search.Beam {
+	if constraints.Empty() {
+		return beam
+	}
+	constrained := *beam
+	constrained.TransFunc = constraint.Constrain(beam.TransFunc, constraints)
+	return &constrained
+}
+
+// ParseConstrained parses every instance as Parse does, restricted by the
+// constraints of the instance (by index)
+func ParseConstrained(instances []interface{}, beam *search.Beam, constraints []*constraint.Sentence) []interface{} {
+	if len(constraints) == 0 {
+		return Parse(instances, beam)
+	}
+	startTime := time.Now()
+	parsed := make([]interface{}, len(instances))
+	for i, instance := range instances {
+		log.Println("Parsing instance", i)
+		parsed[i], _ = Constrain(beam, sentenceConstraints(constraints, i)).Parse(instance)
+	}
+	if allOut {
+		log.Println("PARSE Total Time:", time.Since(startTime))
+	}
+	return parsed
+}
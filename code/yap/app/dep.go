@@ -15,6 +15,7 @@ import (
 	"yap/util"
 	"yap/util/conf"
 
+	"io"
 	"log"
 	"os"
 	// "strings"
@@ -52,7 +53,9 @@ func DepConfigOut(outModelFile string, b search.Interface, t transition.Transiti
 	log.Println("Configuration")
 	log.Printf("Beam:             \t%s", b.Name())
 	log.Printf("Transition System:\t%s", t.Name())
+	log.Printf("Transition Oracle:\t%s", t.Oracle().Name())
 	log.Printf("Iterations:\t\t%d", Iterations)
+	log.Printf("Update:\t\t\t%s", UpdateDescription())
 	log.Printf("Beam Size:\t\t%d", BeamSize)
 	log.Printf("Beam Concurrent:\t%v", ConcurrentBeam)
 	log.Printf("Model file:\t\t%s", outModelFile)
@@ -109,11 +112,17 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 	case "eager":
 		arcSystem = &ArcEager{}
 		terminalStack = 0
+	case "swap":
+		arcSystem = &ArcSwap{}
+		terminalStack = 1
+	case "hybrid":
+		arcSystem = &ArcHybrid{}
+		terminalStack = 0
 	default:
 		panic("Unknown arc system")
 	}
 
-	arcSystem.AddDefaultOracle()
+	AddArcOracle(arcSystem)
 
 	transitionSystem := transition.TransitionSystem(arcSystem)
 	REQUIRED_FLAGS := []string{"oc"}
@@ -142,8 +151,16 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		model        *transitionmodel.AvgMatrixSparse = &transitionmodel.AvgMatrixSparse{}
 		modelExists  bool
 	)
+	if UseMST() {
+		outModelFile = fmt.Sprintf("%s.mst", DepModelFile)
+	}
 	// search for model file locally or in data/ path
 	modelLocation, found := util.LocateFile(DepModelName, DEFAULT_MODEL_DIRS)
+	// the default -mn is a beam model, so the graph-based parser only loads
+	// a model given with -mn
+	if UseMST() && !flagSet(cmd, "mn") {
+		found = false
+	}
 	if found {
 		modelExists = true
 		outModelFile = modelLocation
@@ -157,7 +174,11 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		VerifyFlags(cmd, REQUIRED_FLAGS)
 	}
 	if allOut && !parseOut {
-		DepConfigOut(outModelFile, &search.Beam{}, transitionSystem)
+		if UseMST() {
+			MSTConfigOut(outModelFile, NewMSTParser(nil))
+		} else {
+			DepConfigOut(outModelFile, &search.Beam{}, transitionSystem)
+		}
 	}
 	// modelExists := false
 	relations, err := conf.ReadFile(DepLabelsFile)
@@ -165,12 +186,40 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		log.Println("Failed reading dependency labels configuration file:", DepLabelsFile)
 		log.Fatalln(err)
 	}
+	// the labels of the arcs lifted by -pseudoproj are added to the relations,
+	// from the training trees or the model
+	var (
+		serialization    *Serialization
+		pseudoProjLabels []string
+	)
+	if modelExists {
+		if allOut && !parseOut {
+			log.Println("Found model file", outModelFile, " ... loading model")
+		}
+		serialization = ReadModel(outModelFile)
+		featuresFile := DepFeaturesFile
+		if UseMST() {
+			featuresFile = ""
+		}
+		CheckModel(outModelFile, serialization, featuresFile, DepLabelsFile, "")
+		mstModel(outModelFile, serialization.Header)
+		if !UseMST() {
+			arcSystemModel(outModelFile, serialization.Header)
+		}
+		relations.Values = append(relations.Values, pseudoProjModel(outModelFile, serialization.Header)...)
+	} else {
+		pseudoProjLabels = PseudoProjLabels(tConll)
+		relations.Values = append(relations.Values, pseudoProjLabels...)
+	}
 	if allOut && !parseOut {
 		log.Println()
 		// start processing - setup enumerations
 		log.Println("Setup enumerations")
 	}
 	SetupDepEnum(relations.Values)
+	if UseMST() {
+		return MSTTrainAndParse(cmd, outModelFile, serialization, pseudoProjLabels)
+	}
 
 	// after calling SetupDepEnum, enums are instantiated and set according to the relations
 	// therefore we re-instantiate the arc system with the right parameters
@@ -195,11 +244,33 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 			REDUCE:  RE.Value(),
 			POPROOT: PR.Value(),
 		}
+	case "swap":
+		arcSystem = &ArcSwap{
+			ArcStandard: ArcStandard{
+				SHIFT:       SH.Value(),
+				LEFT:        LA.Value(),
+				RIGHT:       RA.Value(),
+				Relations:   ERel,
+				Transitions: ETrans,
+			},
+			SWAP: SW.Value(),
+		}
+	case "hybrid":
+		arcSystem = &ArcHybrid{
+			ArcStandard: ArcStandard{
+				SHIFT:       SH.Value(),
+				LEFT:        LA.Value(),
+				RIGHT:       RA.Value(),
+				Relations:   ERel,
+				Transitions: ETrans,
+			},
+			POPROOT: PR.Value(),
+		}
 	default:
 		panic("Unknown arc system")
 	}
 
-	arcSystem.AddDefaultOracle()
+	AddArcOracle(arcSystem)
 
 	transitionSystem = transition.TransitionSystem(arcSystem)
 
@@ -290,6 +361,10 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 				log.Println("Conll:\tRead", len(s), "sentences")
 				log.Println("Conll:\tConverting from conll to internal structure")
 			}
+			if len(PseudoProj) > 0 {
+				lifted, _ := ProjectivizeConllU(s)
+				ReportPseudoProj(lifted, len(s), pseudoProjLabels)
+			}
 			goldGraphs = conllu.ConllU2GraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
 			//goldMorphGraphs = conllu.ConllU2MorphGraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
 
@@ -303,6 +378,10 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 				log.Println("Conll:\tRead", len(s), "sentences")
 				log.Println("Conll:\tConverting from conll to internal structure")
 			}
+			if len(PseudoProj) > 0 {
+				lifted, _ := ProjectivizeConll(s)
+				ReportPseudoProj(lifted, len(s), pseudoProjLabels)
+			}
 			goldGraphs = conll.Conll2GraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
 		}
 		if allOut {
@@ -431,6 +510,9 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 			}
 			evaluator = MakeDepEvalStopCondition(sents, goldSents, testSents, asMorphGraphs, asMorphGoldGraphs, testAsMorphGraphs, decodeTestBeam, perceptron.InstanceDecoder(deterministic), BeamSize)
 		}
+		TrainHeader = NewModelHeader(cmd, DepFeaturesFile, DepLabelsFile, "")
+		TrainHeader.Labels = pseudoProjLabels
+		SetExploration(deterministic)
 		_ = Train(goldSequences, Iterations, DepModelFile, model, perceptron.EarlyUpdateInstanceDecoder(beam), perceptron.InstanceDecoder(deterministic), evaluator)
 		if allOut {
 			log.Println("Done Training")
@@ -440,16 +522,13 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		serialization := &Serialization{
 			model.Serialize(-1),
 			EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
+			TrainHeader,
 		}
 		WriteModel(outModelFile, serialization)
 		if allOut {
 			log.Println("Done writing model")
 		}
 	} else {
-		if allOut && !parseOut {
-			log.Println("Found model file", outModelFile, " ... loading model")
-		}
-		serialization := ReadModel(outModelFile)
 		model.Deserialize(serialization.WeightModel)
 		EWord, EPOS, EWPOS, EMHost, EMSuffix = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix
 		if allOut && !parseOut {
@@ -569,6 +648,47 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		EstimatedTransitions: EstimatedBeamTransitions(),
 		ScoredStoreDense:     true,
 	}
+	constraints := ReadConstraints(len(sents))
+	if constraints != nil && Stream {
+		log.Fatalln("-constraints can't be used with -stream")
+	}
+	if WithConfidence {
+		if Stream || KBest > 1 || !useConllU {
+			log.Fatalln("-conf requires -conllu and can't be used with -stream or -kbest")
+		}
+		parsedGraphs, confs := ParseConfidence(sents, beam, constraints)
+		graphAsConll := conllu.MergeGraphAndMorphCorpus(conllu.Graph2ConllUCorpus(parsedGraphs, EMHost, EMSuffix), asMorphGraphs)
+		DeprojectivizeCorpus(graphAsConll)
+		AddConfidenceConllU(graphAsConll, confs)
+		conllu.WriteFile(outConll, graphAsConll)
+		log.Println("Wrote", len(parsedGraphs), "in conllu format with confidence to", outConll)
+		return nil
+	}
+	if KBest > 1 {
+		if Stream {
+			log.Fatalln("-kbest can't be used with -stream")
+		}
+		writeConll := func(writer io.Writer, graphs []interface{}) {
+			conll.Write(writer, DeprojectivizeCorpus(conll.Graph2ConllCorpus(graphs, EMHost, EMSuffix)))
+		}
+		kbest := ParseKBest(sents, beam, KBest, OutputKey(writeConll), constraints)
+		var err error
+		if useConllU {
+			morphGraphs := make([]interface{}, len(kbest.Parses))
+			for i, sent := range kbest.Sentences {
+				morphGraphs[i] = asMorphGraphs[sent]
+			}
+			graphAsConll := conllu.MergeGraphAndMorphCorpus(conllu.Graph2ConllUCorpus(kbest.Parses, EMHost, EMSuffix), morphGraphs)
+			err = kbest.WriteFile(outConll, DeprojectivizeCorpus(graphAsConll), conllu.Write)
+		} else {
+			err = kbest.WriteFile(outConll, DeprojectivizeCorpus(conll.Graph2ConllCorpus(kbest.Parses, EMHost, EMSuffix)), conll.Write)
+		}
+		if err != nil {
+			log.Fatalln("Failed writing", outConll, err)
+		}
+		log.Println("Wrote", len(kbest.Parses), "parses of", len(sents), "sentences to", outConll)
+		return nil
+	}
 	if Stream {
 		parsedStream := make(chan interface{}, 2)
 		if allOut {
@@ -576,7 +696,7 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		}
 		go ParseStream(sentsStream, parsedStream, beam)
 		log.Println("Streaming conversion to conll")
-		graphAsConllStream := conll.Graph2ConllStream(parsedStream, EMHost, EMSuffix)
+		graphAsConllStream := DeprojectivizeStream(conll.Graph2ConllStream(parsedStream, EMHost, EMSuffix))
 		if allOut {
 			log.Println("Creating writer stream to", outConll)
 		}
@@ -592,20 +712,20 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 			log.Print("Parsing")
 		}
 
-		parsedGraphs := Parse(sents, beam)
+		parsedGraphs := ParseConstrained(sents, beam, constraints)
 		if !parseOut {
 			log.Println("Converting to conll")
 		}
 		if useConllU {
 			graphAsConll := conllu.Graph2ConllUCorpus(parsedGraphs, EMHost, EMSuffix)
 			morphGraphs := conllu.MergeGraphAndMorphCorpus(graphAsConll, asMorphGraphs)
-			conllu.WriteFile(outConll, morphGraphs)
+			conllu.WriteFile(outConll, DeprojectivizeCorpus(morphGraphs))
 			if !parseOut {
 				log.Println("Wrote", len(parsedGraphs), "in conllu format to", outConll)
 			}
 		} else {
 			graphAsConll := conll.Graph2ConllCorpus(parsedGraphs, EMHost, EMSuffix)
-			conll.WriteFile(outConll, graphAsConll)
+			conll.WriteFile(outConll, DeprojectivizeCorpus(graphAsConll))
 			if !parseOut {
 				log.Println("Wrote", len(parsedGraphs), "in conll format to", outConll)
 			}
@@ -618,9 +738,9 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		log.SetPrefix("")
 		log.SetFlags(0)
 		log.Print("Parsing started")
-		parsedGraphs := Parse(sents, beam)
+		parsedGraphs := ParseConstrained(sents, beam, constraints)
 		graphAsConll := conll.Graph2ConllCorpus(parsedGraphs, EMHost, EMSuffix)
-		conll.WriteFile(outConll, graphAsConll)
+		conll.WriteFile(outConll, DeprojectivizeCorpus(graphAsConll))
 		log.Println("Wrote", len(parsedGraphs), "in conll format to", outConll)
 	}
 	return nil
@@ -635,16 +755,26 @@ func DepCmd() *commander.Command {
 runs dependency training/parsing
 
 	$ ./yap dep -f <features> -l <labels> -tc <conll> -in <input tagged> -oc <out conll> [-a eager|standard] [options]
+	$ ./yap dep -decoder mst -l <labels> -tc <conll> -in <input tagged> -oc <out conll> [-mstalg chuliu|eisner] [-mstorder 1|2] [options]
 
 `,
 		Flag: *flag.NewFlagSet("dep", flag.ExitOnError),
 	}
 	cmd.Flag.BoolVar(&ConcurrentBeam, "bconc", true, "Concurrent Beam")
 	cmd.Flag.IntVar(&Iterations, "it", 1, "Number of Perceptron Iterations")
+	cmd.Flag.StringVar(&UpdateRule, "update", "perceptron", "Optional - Training update [perceptron, pa1, pa2]: the fixed perceptron update, or the passive-aggressive (MIRA) PA-I or PA-II update scaled by the cost of the mistakes")
+	cmd.Flag.Float64Var(&PAC, "C", 1, "Optional - Aggressiveness of -update pa1 and pa2")
 	cmd.Flag.IntVar(&BeamSize, "b", 64, "Dependency Beam Size")
 	cmd.Flag.StringVar(&DepModelFile, "m", "model", "Prefix for model file ({m}.b{b}.i{it}.model)")
 	cmd.Flag.StringVar(&DepModelName, "mn", "dep.b64", "Modelfile")
-	cmd.Flag.StringVar(&DepArcSystemStr, "a", "eager", "Optional - Arc System [standard, eager]")
+	cmd.Flag.StringVar(&DepArcSystemStr, "a", "eager", "Optional - Arc System [standard, eager, swap, hybrid]")
+	cmd.Flag.StringVar(&OracleType, "oracle", "static", "Optional - Training oracle of the arc system [static, dynamic]; dynamic requires -a eager")
+	cmd.Flag.Float64Var(&ExploreProb, "explore", 0.9, "Optional - Probability of following a wrong model prediction when training with -oracle dynamic")
+	cmd.Flag.IntVar(&ExploreAfter, "explorefrom", 1, "Optional - First training iteration exploring model predictions with -oracle dynamic")
+	cmd.Flag.StringVar(&DepDecoder, "decoder", "beam", "Optional - Decoder [beam, mst]: the transition parser's beam search, or the graph-based maximum spanning tree parser ({m}.mst)")
+	cmd.Flag.StringVar(&MSTAlgorithm, "mstalg", "chuliu", "Optional - Spanning tree algorithm of -decoder mst [chuliu (non-projective), eisner (projective)]")
+	cmd.Flag.IntVar(&MSTOrder, "mstorder", 1, "Optional - Order of -decoder mst [1 (arcs), 2 (arcs and adjacent siblings, requires -mstalg eisner)]")
+	cmd.Flag.StringVar(&PseudoProj, "pseudoproj", "", "Optional - Lift the non-projective arcs of the training trees, encoding them in the labels [head, path, head+path], and lower them in the parses")
 
 	cmd.Flag.StringVar(&tConll, "tc", "", "Training Conll File")
 	cmd.Flag.StringVar(&input, "in", "", "Dev Tagged Sentences File")
@@ -662,5 +792,9 @@ runs dependency training/parsing
 	cmd.Flag.BoolVar(&search.AllOut, "showbeam", false, "Show candidates in beam")
 	cmd.Flag.BoolVar(&useConllU, "conllu", false, "use CoNLL-U-format input file (for disamb lattices)")
 	cmd.Flag.BoolVar(&Stream, "stream", false, "Stream data from input through parser to output")
+	cmd.Flag.IntVar(&KBest, "kbest", 1, "Optional - Write the k best distinct parses of every sentence, with their scores")
+	cmd.Flag.StringVar(&ConstraintsFile, "constraints", "", "Optional - Constraints file fixing or forbidding arcs of the input sentences")
+	cmd.Flag.BoolVar(&WithConfidence, "conf", false, "Optional - Add the confidence of every arc (Conf= in MISC, requires -conllu)")
+	cmd.Flag.Float64Var(&ConfTemperature, "conftemp", 0, "Optional - Score scale of the beam candidates weighing the confidence; 0 = standard deviation of the scores")
 	return cmd
 }
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"yap/nlp/parser/ma"
+	"yap/util"
+
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+var (
+	genLemma, genPOS, genFeats string
+	genNoPrefix                bool
+	outGenFile                 string
+)
+
+// WriteGenerated writes a generated form per line: form, prefix, host, and
+// the lemma, pos and features of the host
+func WriteGenerated(writer io.Writer, generated []*ma.Generated) {
+	for _, g := range generated {
+		host := g.HostMorpheme()
+		prefix, feats := g.Prefix, host.FeatureStr
+		if len(prefix) == 0 {
+			prefix = "_"
+		}
+		if len(feats) == 0 {
+			feats = "_"
+		}
+		fields := []string{g.Form, prefix, g.Host, host.Lemma, host.CPOS, feats}
+		writer.Write([]byte(strings.Join(fields, "\t")))
+		writer.Write([]byte{'\n'})
+	}
+}
+
+func HebGenerate(cmd *commander.Command, args []string) error {
+	VerifyFlags(cmd, []string{"lemma"})
+	if prefixLocation, found := util.LocateFile(HebMaPrefixFile, HEB_MA_DEFAULT_DATA_DIRS); found {
+		HebMaPrefixFile = prefixLocation
+	}
+	if lexiconLocation, found := util.LocateFile(HebMaLexiconFile, HEB_MA_DEFAULT_DATA_DIRS); found {
+		HebMaLexiconFile = lexiconLocation
+	}
+	maData := LoadHebMA("spmrl")
+	var generated []*ma.Generated
+	if genNoPrefix {
+		generated = maData.GenerateHosts(genLemma, genPOS, genFeats)
+	} else {
+		generated = maData.Generate(genLemma, genPOS, genFeats)
+	}
+	log.Println("Generated", len(generated), "forms of", genLemma)
+	if len(outGenFile) == 0 {
+		WriteGenerated(os.Stdout, generated)
+		return nil
+	}
+	file, err := os.Create(outGenFile)
+	if err != nil {
+		return fmt.Errorf("Failed creating output file %v: %v", outGenFile, err)
+	}
+	defer file.Close()
+	WriteGenerated(file, generated)
+	return nil
+}
+
+func HebGenerateCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       HebGenerate,
+		UsageLine: "gen <file options> [arguments]",
+		Short:     "generate surface forms of a lemma from the Hebrew lexicon",
+		Long: `
+generate every surface form of a lemma in the Hebrew lexicon having the
+given pos and features, including prefixed forms
+
+	$ ./yap gen -lemma <lemma> [-pos <pos>] [-feats <feats, e.g. gen=M|num=P>] [-out <output file>] [options]
+
+each output line holds: form, prefix, host form, lemma, pos and features
+
+`,
+		Flag: *flag.NewFlagSet("gen", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&HebMaPrefixFile, "prefix", "bgupreflex_withdef.utf8.hr", "Prefix file for morphological analyzer")
+	cmd.Flag.StringVar(&HebMaLexiconFile, "lexicon", "bgulex.utf8.hr", "Lexicon file for morphological analyzer")
+	cmd.Flag.StringVar(&genLemma, "lemma", "", "Lemma to generate")
+	cmd.Flag.StringVar(&genPOS, "pos", "", "Optional - POS of the generated forms")
+	cmd.Flag.StringVar(&genFeats, "feats", "", "Optional - Features the generated forms must have (e.g. gen=M|num=P)")
+	cmd.Flag.BoolVar(&genNoPrefix, "noprefix", false, "Don't generate prefixed forms")
+	cmd.Flag.StringVar(&outGenFile, "out", "", "Optional - Output file (default stdout)")
+	return cmd
+}
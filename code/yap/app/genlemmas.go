@@ -68,8 +68,9 @@ func genLemmasInstance(goldLat, ambLat nlp.LatticeSentence) *disambig.MDConfig {
 			continue
 		}
 		mapping := &nlp.Mapping{
-			lat.Token,
-			lat.Spellouts[0],
+			Token:    lat.Token,
+			Spellout: lat.Spellouts[0],
+			Range:    lat.Range,
 		}
 		mappings[i] = mapping
 	}
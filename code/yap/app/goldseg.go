@@ -41,12 +41,11 @@ func GenSegSequence(iAmbLat, iGoldLat interface{}) nlp.LatticeSentence {
 		sharedSpellouts := aLat.Spellouts.Intersect(gLat.Spellouts, "Form", lastTop)
 		// log.Println("Got shared", sharedSpellouts)
 		newLat := &nlp.Lattice{
-			aLat.Token,
-			sharedSpellouts.UniqueMorphemes(),
-			nil,
-			nil,
-			sharedSpellouts[0][0].From(),
-			sharedSpellouts[0][len(sharedSpellouts[0])-1].To(),
+			Token:     aLat.Token,
+			Morphemes: sharedSpellouts.UniqueMorphemes(),
+			BottomId:  sharedSpellouts[0][0].From(),
+			TopId:     sharedSpellouts[0][len(sharedSpellouts[0])-1].To(),
+			Range:     aLat.Range,
 		}
 
 		newLat.GenNexts(false)
@@ -5,6 +5,7 @@ import (
 	"yap/nlp/format/lattice"
 	"yap/nlp/format/lex"
 	"yap/nlp/format/raw"
+	"yap/nlp/format/tokenize"
 	"yap/util"
 
 	"yap/nlp/parser/ma"
@@ -26,6 +27,7 @@ var (
 	HebMaNnpnofeats              bool
 	HebMaShowoov                 bool
 	outJSON                 bool
+	inTextFile              string
 	HEB_MA_DEFAULT_DATA_DIRS       = []string{".", "data/bgulex"}
 )
 
@@ -40,6 +42,8 @@ func HebMAConfigOut() {
 		if len(conlluFile) > 0 {
 			log.Printf("CoNLL-U Input:\t%s", conlluFile)
 		}
+	} else if len(inTextFile) > 0 {
+		log.Printf("Text Input:\t\t%s", inTextFile)
 	} else {
 		if len(inRawFile) > 0 {
 			log.Printf("Raw Input:\t\t%s", inRawFile)
@@ -57,6 +61,8 @@ func HebMA(cmd *commander.Command, args []string) error {
 	if useConllU {
 		lattice.OVERRIDE_XPOS_WITH_UPOS = true
 		REQUIRED_FLAGS = []string{"conllu", "out"}
+	} else if len(inTextFile) > 0 {
+		REQUIRED_FLAGS = []string{"text", "out"}
 	} else {
 		REQUIRED_FLAGS = []string{"raw", "out"}
 	}
@@ -84,13 +90,7 @@ func HebMA(cmd *commander.Command, args []string) error {
 		// Compatibility: No features for PROPN in UD Hebrew
 		lex.STRIP_ALL_NNP_OF_FEATS = true
 	}
-	maData := new(ma.BGULex)
-	maData.MAType = outFormat
-	log.Println("Reading Morphological Analyzer BGU Prefixes")
-	maData.LoadPrefixes(HebMaPrefixFile)
-	log.Println("Reading Morphological Analyzer BGU Lexicon")
-	maData.LoadLex(HebMaLexiconFile, HebMaNnpnofeats)
-	log.Println()
+	maData := LoadHebMA(outFormat)
 	var (
 		sents        []nlp.BasicSentence
 		sentComments [][]string
@@ -135,6 +135,11 @@ func HebMA(cmd *commander.Command, args []string) error {
 				sentComments[i] = sent.Comments
 				sents[i] = newSent
 			}
+		} else if len(inTextFile) > 0 {
+			sents, err = tokenize.ReadFile(inTextFile, limit)
+			if err != nil {
+				panic(fmt.Sprintf("Failed reading text file - %v", err))
+			}
 		} else {
 			sents, err = raw.ReadFile(inRawFile, limit)
 			if err != nil {
@@ -146,8 +151,6 @@ func HebMA(cmd *commander.Command, args []string) error {
 	stats := new(ma.AnalyzeStats)
 	stats.Init()
 	maData.Stats = stats
-	maData.AlwaysNNP = HebMaAlwaysnnp
-	maData.LogOOV = HebMaShowoov
 	prefix := log.Prefix()
 	if Stream {
 		lattices := make(chan nlp.LatticeSentence, 2)
@@ -214,6 +217,37 @@ func HebMA(cmd *commander.Command, args []string) error {
 	return nil
 }
 
+// LoadHebMA loads the BGU prefixes and lexicon from the (located) files
+// set in HebMaPrefixFile and HebMaLexiconFile
+func LoadHebMA(maType string) *ma.BGULex {
+	maData := new(ma.BGULex)
+	maData.MAType = maType
+	log.Println("Reading Morphological Analyzer BGU Prefixes")
+	maData.LoadPrefixes(HebMaPrefixFile)
+	log.Println("Reading Morphological Analyzer BGU Lexicon")
+	maData.LoadLex(HebMaLexiconFile, HebMaNnpnofeats)
+	log.Println()
+	maData.AlwaysNNP = HebMaAlwaysnnp
+	maData.LogOOV = HebMaShowoov
+	return maData
+}
+
+// HebMAText tokenizes a running text file and analyzes its sentences,
+// returning lattices as read from a lattice file along with the offsets
+// of each token in the text
+func HebMAText(maData *ma.BGULex, filename string, limit int) ([]lattice.Lattice, error) {
+	sents, ranges, err := tokenize.ReadFileWithRanges(filename, limit)
+	if err != nil {
+		return nil, err
+	}
+	lattices := make([]nlp.LatticeSentence, len(sents))
+	for i, sent := range sents {
+		lattices[i], _ = maData.Analyze(sent.Tokens())
+		lattices[i].SetRanges(ranges[i])
+	}
+	return lattice.Reparse(lattice.Sentence2LatticeCorpus(lattices, nil))
+}
+
 func HebMACmd() *commander.Command {
 	cmd := &commander.Command{
 		Run:       HebMA,
@@ -223,6 +257,7 @@ func HebMACmd() *commander.Command {
 run lexicon-based morphological analyzer on raw input
 
 	$ ./yap hebma -prefix <prefix file> -lexicon <lexicon file> -raw <raw file> -out <output file> [options]
+	$ ./yap hebma -prefix <prefix file> -lexicon <lexicon file> -text <text file> -out <output file> [options]
 
 `,
 		Flag: *flag.NewFlagSet("ma", flag.ExitOnError),
@@ -230,6 +265,7 @@ run lexicon-based morphological analyzer on raw input
 	cmd.Flag.StringVar(&HebMaPrefixFile, "prefix", "bgupreflex_withdef.utf8.hr", "Prefix file for morphological analyzer")
 	cmd.Flag.StringVar(&HebMaLexiconFile, "lexicon", "bgulex.utf8.hr", "Lexicon file for morphological analyzer")
 	cmd.Flag.StringVar(&inRawFile, "raw", "", "Input raw (tokenized) file")
+	cmd.Flag.StringVar(&inTextFile, "text", "", "Input running text file (tokenized and split to sentences)")
 	cmd.Flag.StringVar(&conlluFile, "conllu", "", "CoNLL-U-format input file")
 	cmd.Flag.StringVar(&outLatticeFile, "out", "", "Output lattice file")
 	cmd.Flag.BoolVar(&HebMaXliter8out, "xliter8out", false, "Transliterate output lattice file")
@@ -10,6 +10,8 @@ import (
 	"yap/nlp/format/lattice"
 	"yap/nlp/format/mapping"
 	"yap/nlp/format/segmentation"
+	"yap/nlp/grammar/agreement"
+	"yap/nlp/numerals"
 	. "yap/nlp/parser/dependency/transition"
 	"yap/nlp/parser/dependency/transition/morph"
 	"yap/nlp/parser/disambig"
@@ -19,6 +21,7 @@ import (
 	"yap/util/conf"
 
 	"fmt"
+	"io"
 	"log"
 	"os"
 
@@ -32,6 +35,10 @@ var (
 	JointStrategy, OracleStrategy string
 	limitdev                      int
 	hebMACompat                   bool
+	// SpellNumerals spells out the digits of parsed numerals in Hebrew words
+	SpellNumerals bool
+	// NumValues sets the value of parsed numeral compounds as a feature
+	NumValues bool
 )
 
 func SetupEnum(relations []string) {
@@ -98,6 +105,7 @@ func JointConfigOut(outModelFile string, b search.Interface, t transition.Transi
 	log.Printf("Transition System:\t%s", t.Name())
 	log.Printf("Transition Oracle:\t%s", t.Oracle().Name())
 	log.Printf("Iterations:\t\t%d", Iterations)
+	log.Printf("Update:\t\t\t%s", UpdateDescription())
 	log.Printf("Beam Size:\t\t%d", BeamSize)
 	log.Printf("Beam Concurrent:\t%v", ConcurrentBeam)
 	log.Printf("Parameter Func:\t%v", MdParamFuncName)
@@ -155,6 +163,12 @@ func JointConfigOut(outModelFile string, b search.Interface, t transition.Transi
 			return
 		}
 	}
+	if len(inTextFile) > 0 {
+		log.Printf("Test file  (running text):\t\t%s", inTextFile)
+		if !VerifyExists(inTextFile) {
+			return
+		}
+	}
 	if len(inputGold) > 0 {
 		log.Printf("Test file  (disambig.  lattice):\t%s", inputGold)
 		if !VerifyExists(inputGold) {
@@ -202,11 +216,17 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 			ArcStandard: ArcStandard{},
 		}
 		terminalStack = 0
+	case "swap":
+		arcSystem = &ArcSwap{}
+		terminalStack = 1
+	case "hybrid":
+		arcSystem = &ArcHybrid{}
+		terminalStack = 0
 	default:
 		panic("Unknown arc system")
 	}
 
-	arcSystem.AddDefaultOracle()
+	AddArcOracle(arcSystem)
 
 	jointTrans := &joint.JointTrans{
 		MDTrans:       mdTrans,
@@ -222,11 +242,26 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 	if !modelExists {
 		outModelFile, modelExists = util.LocateFile(outModelFile, DEFAULT_MODEL_DIRS)
 	}
-	REQUIRED_FLAGS := []string{"in", "oc", "om", "os"}
+	inputFlag := "in"
+	if len(inTextFile) > 0 {
+		inputFlag = "text"
+		if prefixLocation, found := util.LocateFile(HebMaPrefixFile, HEB_MA_DEFAULT_DATA_DIRS); found {
+			HebMaPrefixFile = prefixLocation
+		}
+		if lexiconLocation, found := util.LocateFile(HebMaLexiconFile, HEB_MA_DEFAULT_DATA_DIRS); found {
+			HebMaLexiconFile = lexiconLocation
+		}
+	}
+	outputFlags := []string{"oc", "om", "os"}
+	if len(outAgree) > 0 {
+		// agree only writes the agreement report
+		outputFlags = []string{"oa"}
+	}
+	REQUIRED_FLAGS := append([]string{inputFlag}, outputFlags...)
 	VerifyFlags(cmd, REQUIRED_FLAGS)
 
 	if !modelExists {
-		REQUIRED_FLAGS = []string{"it", "tc", "td", "tl", "in", "oc", "om", "os", "ots", "f", "l", "jointstr", "oraclestr"}
+		REQUIRED_FLAGS = append([]string{"it", "tc", "td", "tl", inputFlag, "ots", "f", "l", "jointstr", "oraclestr"}, outputFlags...)
 		VerifyFlags(cmd, REQUIRED_FLAGS)
 	}
 
@@ -245,6 +280,24 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 		log.Println("Failed reading dependency labels configuration file:", DepLabelsFile)
 		log.Fatalln(err)
 	}
+	// the labels of the arcs lifted by -pseudoproj are added to the relations,
+	// from the training trees or the model
+	var (
+		serialization    *Serialization
+		pseudoProjLabels []string
+	)
+	if modelExists {
+		if allOut && !parseOut {
+			log.Println("Found model file", outModelFile, " ... loading model")
+		}
+		serialization = ReadModel(outModelFile)
+		CheckModel(outModelFile, serialization, JointFeaturesFile, DepLabelsFile, MdParamFuncName)
+		arcSystemModel(outModelFile, serialization.Header)
+		relations.Values = append(relations.Values, pseudoProjModel(outModelFile, serialization.Header)...)
+	} else {
+		pseudoProjLabels = PseudoProjLabels(tConll)
+		relations.Values = append(relations.Values, pseudoProjLabels...)
+	}
 	if allOut {
 		log.Println()
 		// start processing - setup enumerations
@@ -276,10 +329,32 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 			REDUCE:  RE.Value(),
 			POPROOT: PR.Value(),
 		}
+	case "swap":
+		arcSystem = &ArcSwap{
+			ArcStandard: ArcStandard{
+				SHIFT:       SH.Value(),
+				LEFT:        LA.Value(),
+				RIGHT:       RA.Value(),
+				Relations:   ERel,
+				Transitions: ETrans,
+			},
+			SWAP: SW.Value(),
+		}
+	case "hybrid":
+		arcSystem = &ArcHybrid{
+			ArcStandard: ArcStandard{
+				SHIFT:       SH.Value(),
+				LEFT:        LA.Value(),
+				RIGHT:       RA.Value(),
+				Relations:   ERel,
+				Transitions: ETrans,
+			},
+			POPROOT: PR.Value(),
+		}
 	default:
 		panic("Unknown arc system")
 	}
-	arcSystem.AddDefaultOracle()
+	AddArcOracle(arcSystem)
 	jointTrans.ArcSys = arcSystem
 	jointTrans.Transitions = ETrans
 	mdTrans.Transitions = ETrans
@@ -345,6 +420,10 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 				log.Println("Conll:\tRead", len(s), "sentences")
 				log.Println("Conll:\tConverting from conll to internal structure")
 			}
+			if len(PseudoProj) > 0 {
+				lifted, _ := ProjectivizeConllU(s)
+				ReportPseudoProj(lifted, len(s), pseudoProjLabels)
+			}
 			goldConll = conllu.ConllU2MorphGraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
 		} else {
 			s, e := conll.ReadFile(tConll, limit)
@@ -356,6 +435,10 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 				log.Println("Conll:\tRead", len(s), "sentences")
 				log.Println("Conll:\tConverting from conll to internal structure")
 			}
+			if len(PseudoProj) > 0 {
+				lifted, _ := ProjectivizeConll(s)
+				ReportPseudoProj(lifted, len(s), pseudoProjLabels)
+			}
 			goldConll = conll.Conll2GraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
 		}
 
@@ -626,6 +709,9 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 			// TODO: replace nil param with test sentences
 			evaluator = MakeJointEvalStopCondition(convAmbLat, convCombined, testAmbLat, testCombined, decodeTestBeam, perceptron.InstanceDecoder(deterministic), BeamSize)
 		}
+		TrainHeader = NewModelHeader(cmd, JointFeaturesFile, DepLabelsFile, MdParamFuncName)
+		TrainHeader.Labels = pseudoProjLabels
+		SetExploration(deterministic)
 		_ = Train(goldSequences, Iterations, JointModelFile, model, perceptron.EarlyUpdateInstanceDecoder(beam), perceptron.InstanceDecoder(deterministic), evaluator)
 		search.AllOut = false
 		if allOut {
@@ -644,10 +730,6 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 		}
 		return nil
 	} else {
-		if allOut && !parseOut {
-			log.Println("Found model file", outModelFile, " ... loading model")
-		}
-		serialization := ReadModel(outModelFile)
 		model.Deserialize(serialization.WeightModel)
 		EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix, serialization.EMorphProp, serialization.ETrans, serialization.ETokens
 		if allOut && !parseOut {
@@ -674,6 +756,28 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 				REDUCE:  RE.Value(),
 				POPROOT: PR.Value(),
 			}
+		case "swap":
+			arcSystem = &ArcSwap{
+				ArcStandard: ArcStandard{
+					SHIFT:       SH.Value(),
+					LEFT:        LA.Value(),
+					RIGHT:       RA.Value(),
+					Relations:   ERel,
+					Transitions: ETrans,
+				},
+				SWAP: SW.Value(),
+			}
+		case "hybrid":
+			arcSystem = &ArcHybrid{
+				ArcStandard: ArcStandard{
+					SHIFT:       SH.Value(),
+					LEFT:        LA.Value(),
+					RIGHT:       RA.Value(),
+					Relations:   ERel,
+					Transitions: ETrans,
+				},
+				POPROOT: PR.Value(),
+			}
 		default:
 			panic("Unknown arc system")
 		}
@@ -698,15 +802,18 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 	log.Println("*** PARSING ***")
 	log.Print("Parsing test")
 
-	log.Println("Reading ambiguous lattices from", input)
-
 	var (
 		lAmb  []lattice.Lattice
 		lAmbE error
 	)
-	if useConllU {
+	if len(inTextFile) > 0 {
+		log.Println("Analyzing running text from", inTextFile)
+		lAmb, lAmbE = HebMAText(LoadHebMA("spmrl"), inTextFile, limit)
+	} else if useConllU {
+		log.Println("Reading ambiguous lattices from", input)
 		lAmb, lAmbE = lattice.ReadUDFile(input, limit)
 	} else {
+		log.Println("Reading ambiguous lattices from", input)
 		lAmb, lAmbE = lattice.ReadFile(input, limit)
 	}
 	if lAmbE != nil {
@@ -785,7 +892,36 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 	}
 	beam.Model = model
 	beam.ShortTempAgenda = true
-	parsedGraphs := Parse(predAmbLat, beam)
+	constraints := ReadConstraints(len(predAmbLat))
+	if WithConfidence && (!useConllU || KBest > 1) {
+		log.Fatalln("-conf requires -conllu and can't be used with -kbest")
+	}
+	var (
+		parsedGraphs []interface{}
+		kbest        *KBestParses
+		confs        []*Confidence
+	)
+	if KBest > 1 && len(outAgree) == 0 {
+		kbest = ParseKBest(predAmbLat, beam, KBest, OutputKey(writeJointParse), constraints)
+		parsedGraphs = kbest.Parses
+	} else if WithConfidence && len(outAgree) == 0 {
+		parsedGraphs, confs = ParseConfidence(predAmbLat, beam, constraints)
+	} else {
+		parsedGraphs = ParseConstrained(predAmbLat, beam, constraints)
+	}
+
+	if len(outAgree) > 0 {
+		mismatches := agreement.CheckCorpus(parsedGraphs)
+		agreement.WriteFile(outAgree, mismatches)
+		log.Println("Wrote", len(mismatches), "agreement errors to", outAgree)
+		return nil
+	}
+	if NumValues {
+		log.Println("Valued", numerals.AnnotateCorpus(parsedGraphs), "numerals")
+	}
+	if SpellNumerals {
+		log.Println("Spelled out", numerals.SpellOutCorpus(parsedGraphs), "numerals")
+	}
 
 	if allOut {
 		log.Println("Converting", len(parsedGraphs), "to conll")
@@ -793,12 +929,34 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 	if allOut {
 		log.Println("Writing to output file")
 	}
+	if kbest != nil {
+		var err error
+		if useConllU {
+			err = kbest.WriteFile(outConll, DeprojectivizeCorpus(conllu.MorphGraph2ConllCorpus(parsedGraphs)), conllu.Write)
+		} else {
+			err = kbest.WriteFile(outConll, DeprojectivizeCorpus(conll.MorphGraph2ConllCorpus(parsedGraphs)), conll.Write)
+		}
+		if err == nil {
+			err = kbest.WriteFile(outSeg, parsedGraphs, segmentation.Write)
+		}
+		if err == nil {
+			err = kbest.WriteFile(outMap, GetInstances(parsedGraphs, GetJointMDConfig), mapping.Write)
+		}
+		if err != nil {
+			log.Fatalln("Failed writing k-best parses", err)
+		}
+		log.Println("Wrote", len(parsedGraphs), "parses of", len(predAmbLat), "sentences to", outConll, outSeg, outMap)
+		return nil
+	}
 	var graphAsConll []interface{}
 	if useConllU {
-		graphAsConll = conllu.MorphGraph2ConllCorpus(parsedGraphs)
+		graphAsConll = DeprojectivizeCorpus(conllu.MorphGraph2ConllCorpus(parsedGraphs))
+		if confs != nil {
+			AddConfidenceConllU(graphAsConll, confs)
+		}
 		conllu.WriteFile(outConll, graphAsConll)
 	} else {
-		graphAsConll = conll.MorphGraph2ConllCorpus(parsedGraphs)
+		graphAsConll = DeprojectivizeCorpus(conll.MorphGraph2ConllCorpus(parsedGraphs))
 		conll.WriteFile(outConll, graphAsConll)
 	}
 	if allOut {
@@ -821,6 +979,12 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 	return nil
 }
 
+// writeJointParse writes joint parses in CoNLL format, used to tell apart
+// distinct k-best parses
+func writeJointParse(writer io.Writer, parses []interface{}) {
+	conll.Write(writer, DeprojectivizeCorpus(conll.MorphGraph2ConllCorpus(parses)))
+}
+
 func JointCmd() *commander.Command {
 	cmd := &commander.Command{
 		Run:       JointTrainAndParse,
@@ -834,16 +998,30 @@ runs morpho-syntactic training and parsing
 `,
 		Flag: *flag.NewFlagSet("joint", flag.ExitOnError),
 	}
+	jointFlags(cmd)
+	return cmd
+}
+
+func jointFlags(cmd *commander.Command) {
 	cmd.Flag.BoolVar(&ConcurrentBeam, "bconc", true, "Concurrent Beam")
 	cmd.Flag.IntVar(&Iterations, "it", 1, "Number of Perceptron Iterations")
+	cmd.Flag.StringVar(&UpdateRule, "update", "perceptron", "Optional - Training update [perceptron, pa1, pa2]: the fixed perceptron update, or the passive-aggressive (MIRA) PA-I or PA-II update scaled by the cost of the mistakes")
+	cmd.Flag.Float64Var(&PAC, "C", 1, "Optional - Aggressiveness of -update pa1 and pa2")
 	cmd.Flag.IntVar(&BeamSize, "b", 64, "Beam Size")
 	cmd.Flag.StringVar(&JointModelFile, "m", "joint_arc_zeager_model_temp_i33.b64", "Joint model name")
-	cmd.Flag.StringVar(&DepArcSystemStr, "a", "eager", "Optional - Arc System [standard, eager]")
+	cmd.Flag.StringVar(&DepArcSystemStr, "a", "eager", "Optional - Arc System [standard, eager, swap, hybrid]")
+	cmd.Flag.StringVar(&OracleType, "oracle", "static", "Optional - Training oracle of the arc system [static, dynamic]; dynamic requires -a eager")
+	cmd.Flag.Float64Var(&ExploreProb, "explore", 0.9, "Optional - Probability of following a wrong model prediction when training with -oracle dynamic")
+	cmd.Flag.IntVar(&ExploreAfter, "explorefrom", 1, "Optional - First training iteration exploring model predictions with -oracle dynamic")
+	cmd.Flag.StringVar(&PseudoProj, "pseudoproj", "", "Optional - Lift the non-projective arcs of the training trees, encoding them in the labels [head, path, head+path], and lower them in the parses")
 
 	cmd.Flag.StringVar(&tConll, "tc", "", "Training Conll File")
 	cmd.Flag.StringVar(&tLatDis, "td", "", "Training Disambiguated Lattices File")
 	cmd.Flag.StringVar(&tLatAmb, "tl", "", "Training Ambiguous Lattices File")
 	cmd.Flag.StringVar(&input, "in", "", "Dev Ambiguous Lattices File")
+	cmd.Flag.StringVar(&inTextFile, "text", "", "Optional - Input running text file, analyzed with the Hebrew lexicon instead of -in")
+	cmd.Flag.StringVar(&HebMaPrefixFile, "prefix", "bgupreflex_withdef.utf8.hr", "Prefix file for morphological analyzer (with -text)")
+	cmd.Flag.StringVar(&HebMaLexiconFile, "lexicon", "bgulex.utf8.hr", "Lexicon file for morphological analyzer (with -text)")
 	cmd.Flag.StringVar(&inputGold, "ing", "", "Optional - Gold Dev Lattices File (for infusion/convergence into dev ambiguous)")
 	cmd.Flag.StringVar(&test, "test", "", "Test Ambiguous Lattices File")
 	cmd.Flag.StringVar(&testGold, "testgold", "", "Optional - Gold Test Lattices File (for infusion into test ambiguous)")
@@ -851,6 +1029,8 @@ runs morpho-syntactic training and parsing
 	cmd.Flag.StringVar(&outSeg, "os", "", "Output Segmentation File")
 	cmd.Flag.StringVar(&outMap, "om", "", "Output Mapping File")
 	cmd.Flag.StringVar(&tSeg, "ots", "", "Output Training Segmentation File")
+	cmd.Flag.BoolVar(&SpellNumerals, "spellnum", false, "Optional - Spell out numerals written in digits as Hebrew words agreeing with their nouns")
+	cmd.Flag.BoolVar(&NumValues, "numvalue", false, "Optional - Add the value of numeral compounds as a NumValue feature")
 	cmd.Flag.StringVar(&JointFeaturesFile, "f", "jointzeager.yaml", "Features Configuration File")
 	cmd.Flag.StringVar(&DepLabelsFile, "l", "hebtb.labels.conf", "Dependency Labels Configuration File")
 	cmd.Flag.StringVar(&MdParamFuncName, "p", "Funcs_Main_POS_Both_Prop", "Param Func types: ["+nlp.AllParamFuncNames+"]")
@@ -865,10 +1045,13 @@ runs morpho-syntactic training and parsing
 	cmd.Flag.BoolVar(&MdNoconverge, "noconverge", false, "don't test convergence (run -it number of iterations)")
 	cmd.Flag.IntVar(&limit, "limit", 0, "limit training set (in thousands)")
 	cmd.Flag.IntVar(&limitdev, "limitdev", 0, "limit dev set (in thousands)")
+	cmd.Flag.IntVar(&KBest, "kbest", 1, "Optional - Write the k best distinct parses of every sentence, with their scores")
+	cmd.Flag.StringVar(&ConstraintsFile, "constraints", "", "Optional - Constraints file fixing the segmentation or POS of tokens, or fixing or forbidding arcs, of the input sentences")
+	cmd.Flag.BoolVar(&WithConfidence, "conf", false, "Optional - Add the confidence of every token segmentation and arc (Conf= in MISC, requires -conllu)")
+	cmd.Flag.Float64Var(&ConfTemperature, "conftemp", 0, "Optional - Score scale of the beam candidates weighing the confidence; 0 = standard deviation of the scores")
 	cmd.Flag.BoolVar(&useConllU, "conllu", false, "use CoNLL-U-format input file (for disamb lattices)")
 	cmd.Flag.BoolVar(&hebMACompat, "hebcompat", false, "Read CoNLLu with hebrew lexicon compatability")
 	// cmd.Flag.BoolVar(&AlignBeam, "align", false, "Use Beam Alignment")
 	// cmd.Flag.BoolVar(&AverageScores, "average", false, "Use Average Scoring")
 	// cmd.Flag.BoolVar(&alignAverageParseOnly, "parseonly", false, "Use Alignment & Average Scoring in parsing only")
-	return cmd
 }
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"yap/alg/search"
+	"yap/alg/transition"
+	"yap/nlp/parser/constraint"
+
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// KBest is the number of parses written per sentence (-kbest)
+var KBest int = 1
+
+// KBestParses holds the k-best parses of a corpus flattened in order: the
+// parses of every sentence, best first, with their sentence, rank and
+// model score. Parses can be converted and written like the output of Parse.
+type KBestParses struct {
+	Parses    []interface{}
+	Sentences []int
+	Ranks     []int
+	Scores    []float64
+}
+
+// OutputKey returns a key function for ParseKBest that tells parses apart by
+// their output as written by write
+func OutputKey(write func(io.Writer, []interface{})) func(transition.Configuration) string {
+	return func(conf transition.Configuration) string {
+		buf := new(bytes.Buffer)
+		write(buf, []interface{}{conf})
+		return buf.String()
+	}
+}
+
+// ParseKBest parses every instance keeping up to k distinct parses, as told
+// apart by key, restricted by the constraints of the instance (if any)
+func ParseKBest(instances []interface{}, beam *search.Beam, k int, key func(transition.Configuration) string, constraints []*constraint.Sentence) *KBestParses {
+	startTime := time.Now()
+	kbest := &KBestParses{
+		Parses:    make([]interface{}, 0, k*len(instances)),
+		Sentences: make([]int, 0, k*len(instances)),
+		Ranks:     make([]int, 0, k*len(instances)),
+		Scores:    make([]float64, 0, k*len(instances)),
+	}
+	for i, instance := range instances {
+		log.Println("Parsing instance", i)
+		confs, scores := Constrain(beam, sentenceConstraints(constraints, i)).ParseKBest(instance, k, key)
+		for rank, conf := range confs {
+			kbest.Parses = append(kbest.Parses, conf)
+			kbest.Sentences = append(kbest.Sentences, i)
+			kbest.Ranks = append(kbest.Ranks, rank)
+			kbest.Scores = append(kbest.Scores, scores[rank])
+		}
+	}
+	if allOut {
+		log.Println("PARSE Total Time:", time.Since(startTime))
+	}
+	return kbest
+}
+
+// Write writes the converted parses, one block per parse, each preceded by
+// a comment line with its sentence, rank and score (1-based):
+//
+//	# sent = 1 kbest = 2 score = 1234
+func (k *KBestParses) Write(writer io.Writer, converted []interface{}, write func(io.Writer, []interface{})) {
+	for i, parse := range converted {
+		fmt.Fprintf(writer, "# sent = %d kbest = %d score = %v\n", k.Sentences[i]+1, k.Ranks[i]+1, k.Scores[i])
+		write(writer, []interface{}{parse})
+	}
+}
+
+func (k *KBestParses) WriteFile(filename string, converted []interface{}, write func(io.Writer, []interface{})) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	k.Write(writer, converted, write)
+	return writer.Flush()
+}
@@ -16,6 +16,7 @@ import (
 	"yap/util"
 
 	"fmt"
+	"io"
 	"log"
 	"os"
 
@@ -68,8 +69,9 @@ func CombineToGoldMorph(goldLat, ambLat nlp.LatticeSentence) (m *disambig.MDConf
 			continue
 		}
 		mapping := &nlp.Mapping{
-			lat.Token,
-			lat.Spellouts[0],
+			Token:    lat.Token,
+			Spellout: lat.Spellouts[0],
+			Range:    lat.Range,
 		}
 		// if the gold spellout doesn't exist in the lattice, add it
 		if len(ambLat[i].Spellouts) == 0 {
@@ -176,6 +178,7 @@ func MDConfigOut(outModelFile string, b search.Interface, t transition.Transitio
 	log.Printf("Beam:\t\t%s", b.Name())
 	log.Printf("Transition System:\t%s", t.Name())
 	log.Printf("Iterations:\t\t%d", Iterations)
+	log.Printf("Update:\t\t\t%s", UpdateDescription())
 	log.Printf("Beam Size:\t\t%d", BeamSize)
 	log.Printf("Beam Concurrent:\t%v", ConcurrentBeam)
 	log.Printf("Parameter Func:\t%v", MdParamFuncName)
@@ -647,6 +650,7 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 				evaluator = MakeMorphEvalStopCondition(convAmbLat, convCombined, testAmbLat, testCombined, decodeTestBeam, perceptron.InstanceDecoder(deterministic), BeamSize)
 			}
 		}
+		TrainHeader = NewModelHeader(cmd, MdFeaturesFile, "", MdParamFuncName)
 		_ = Train(goldSequences, Iterations, MdModelFile, model, perceptron.EarlyUpdateInstanceDecoder(beam), perceptron.InstanceDecoder(deterministic), evaluator)
 
 		if allOut {
@@ -657,6 +661,7 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 			serialization := &Serialization{
 				model.Serialize(-1),
 				EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
+				TrainHeader,
 			}
 			WriteModel(outModelFile, serialization)
 			log.Println("Done")
@@ -668,6 +673,7 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 		log.Println("Found model file", outModelFile, " ... loading model")
 	}
 	serialization := ReadModel(outModelFile)
+	CheckModel(outModelFile, serialization, MdFeaturesFile, "", MdParamFuncName)
 	model.Deserialize(serialization.WeightModel)
 	EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix, serialization.EMorphProp, serialization.ETrans, serialization.ETokens
 
@@ -708,6 +714,12 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 		EstimatedTransitions: 1000, // chosen by random dice roll
 	}
 	if Stream {
+		if KBest > 1 {
+			log.Fatalln("-kbest can't be used with -stream")
+		}
+		if len(ConstraintsFile) > 0 {
+			log.Fatalln("-constraints can't be used with -stream")
+		}
 
 		if allOut {
 			log.Println("Amb. Lat:\tReading ambiguous conllu lattices from", input)
@@ -823,7 +835,27 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 	beam.ShortTempAgenda = true
 	beam.Model = model
 
-	mappings := Parse(predAmbLat, beam)
+	constraints := ReadConstraints(len(predAmbLat))
+	if KBest > 1 {
+		kbest := ParseKBest(predAmbLat, beam, KBest, OutputKey(mapping.Write), constraints)
+		write := mapping.Write
+		if useConllU {
+			// blocks are written in order, each with its sentence's lattice
+			block := 0
+			write = func(writer io.Writer, mappings []interface{}) {
+				sent := kbest.Sentences[block]
+				mapping.UDWrite(writer, mappings, clAmb[sent:sent+1])
+				block++
+			}
+		}
+		if err := kbest.WriteFile(outMap, kbest.Parses, write); err != nil {
+			log.Fatalln("Failed writing", outMap, err)
+		}
+		log.Println("Wrote", len(kbest.Parses), "disambiguations of", len(predAmbLat), "sentences to", outMap)
+		return nil
+	}
+
+	mappings := ParseConstrained(predAmbLat, beam, constraints)
 
 	/*	if allOut {
 			log.Println("Converting", len(parsedGraphs), "to conll")
@@ -876,6 +908,8 @@ runs standalone morphological disambiguation training and parsing
 	}
 	cmd.Flag.BoolVar(&ConcurrentBeam, "bconc", true, "Concurrent Beam")
 	cmd.Flag.IntVar(&Iterations, "it", 1, "Minimum Number of Perceptron Iterations")
+	cmd.Flag.StringVar(&UpdateRule, "update", "perceptron", "Optional - Training update [perceptron, pa1, pa2]: the fixed perceptron update, or the passive-aggressive (MIRA) PA-I or PA-II update scaled by the cost of the mistakes")
+	cmd.Flag.Float64Var(&PAC, "C", 1, "Optional - Aggressiveness of -update pa1 and pa2")
 	cmd.Flag.IntVar(&BeamSize, "b", 32, "Beam Size")
 	cmd.Flag.StringVar(&MdModelFile, "m", "model", "Prefix for model file ({m}.b{b}.model)")
 	cmd.Flag.StringVar(&MdModelName, "mn", "hebmd.b32", "Modelfile")
@@ -904,5 +938,7 @@ runs standalone morphological disambiguation training and parsing
 	cmd.Flag.IntVar(&limit, "limit", 0, "limit training set")
 	cmd.Flag.BoolVar(&MdNoconverge, "noconverge", false, "don't test convergence (run -it number of iterations)")
 	cmd.Flag.BoolVar(&Stream, "stream", false, "Stream data from input through parser to output")
+	cmd.Flag.StringVar(&ConstraintsFile, "constraints", "", "Optional - Constraints file fixing the segmentation or POS of tokens of the input sentences")
+	cmd.Flag.IntVar(&KBest, "kbest", 1, "Optional - Write the k best distinct disambiguations of every sentence, with their scores")
 	return cmd
 }
@@ -0,0 +1,387 @@
+package app
+
+import (
+	"yap/alg/transition/model"
+	"yap/util"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MODEL_FORMAT_VERSION is the newest version of the model file layout yap
+// reads; models without a header are version 0. WriteModel writes version 1
+// (BASE_FORMAT_VERSION), or version 2 for models with quantized weights
+// (model prune), so that only those are refused by older yap versions.
+const (
+	MODEL_FORMAT_VERSION = 2
+	BASE_FORMAT_VERSION  = 1
+)
+
+// FormatVersion is the model format version of a model's weights
+func FormatVersion(weights *model.AvgMatrixSparseSerialized) int {
+	if weights != nil && weights.Scale != 0 {
+		return MODEL_FORMAT_VERSION
+	}
+	return BASE_FORMAT_VERSION
+}
+
+// ModelHeader describes how a model was trained, so that loading it with
+// other features, labels or MD param func can be refused
+type ModelHeader struct {
+	FormatVersion int
+	YapVersion    string
+	Created       time.Time
+
+	// Command is the training command, Args its command line and Flags
+	// the values of all of its flags
+	Command string
+	Args    []string
+	Flags   map[string]string
+
+	FeaturesFile, FeaturesMD5 string
+	LabelsFile, LabelsMD5     string
+	ParamFunc                 string
+
+	// Labels are the labels added to the labels file by -pseudoproj, to
+	// encode the arcs lifted in the training trees
+	Labels []string
+}
+
+var flattenModelFile, flattenOutFile string
+
+// TrainHeader is set by the training commands before training, and written
+// with every model they serialize
+var TrainHeader *ModelHeader
+
+// NewModelHeader describes a model trained by cmd; featuresFile, labelsFile
+// and paramFunc are empty if the model doesn't use them
+func NewModelHeader(cmd *commander.Command, featuresFile, labelsFile, paramFunc string) *ModelHeader {
+	header := &ModelHeader{
+		FormatVersion: BASE_FORMAT_VERSION,
+		YapVersion:    VERSION,
+		Created:       time.Now(),
+		Command:       cmd.Name(),
+		Args:          os.Args,
+		Flags:         make(map[string]string),
+		FeaturesFile:  featuresFile,
+		LabelsFile:    labelsFile,
+		ParamFunc:     paramFunc,
+	}
+	cmd.Flag.VisitAll(func(f *flag.Flag) {
+		header.Flags[f.Name] = f.Value.String()
+	})
+	var err error
+	if len(featuresFile) > 0 {
+		if header.FeaturesMD5, err = util.MD5File(featuresFile); err != nil {
+			log.Fatalln("Failed computing checksum of", featuresFile, err)
+		}
+	}
+	if len(labelsFile) > 0 {
+		if header.LabelsMD5, err = util.MD5File(labelsFile); err != nil {
+			log.Fatalln("Failed computing checksum of", labelsFile, err)
+		}
+	}
+	return header
+}
+
+// Check returns an error if a model with this header can't be used with the
+// given features file, labels file and MD param func; empty arguments aren't
+// checked. Models without a header (nil) can't be checked and always pass.
+func (h *ModelHeader) Check(featuresFile, labelsFile, paramFunc string) error {
+	if h == nil {
+		return nil
+	}
+	if h.FormatVersion > MODEL_FORMAT_VERSION {
+		return fmt.Errorf("model format version %d is newer than the supported version %d (model written by yap %v)", h.FormatVersion, MODEL_FORMAT_VERSION, h.YapVersion)
+	}
+	if err := checkMD5("features", featuresFile, h.FeaturesFile, h.FeaturesMD5); err != nil {
+		return err
+	}
+	if err := checkMD5("labels", labelsFile, h.LabelsFile, h.LabelsMD5); err != nil {
+		return err
+	}
+	if len(paramFunc) > 0 && len(h.ParamFunc) > 0 && paramFunc != h.ParamFunc {
+		return fmt.Errorf("model was trained with MD param func %v, not %v", h.ParamFunc, paramFunc)
+	}
+	return nil
+}
+
+func checkMD5(kind, file, trainedFile, trainedMD5 string) error {
+	if len(file) == 0 || len(trainedMD5) == 0 {
+		return nil
+	}
+	md5, err := util.MD5File(file)
+	if err != nil {
+		return err
+	}
+	if md5 != trainedMD5 {
+		return fmt.Errorf("model was trained with %v file %v (md5 %v), but %v has md5 %v", kind, filepath.Base(trainedFile), trainedMD5, file, md5)
+	}
+	return nil
+}
+
+// CheckModel stops yap if the model read from modelFile can't be used with
+// the given features file, labels file and MD param func
+func CheckModel(modelFile string, data *Serialization, featuresFile, labelsFile, paramFunc string) {
+	if err := data.Header.Check(featuresFile, labelsFile, paramFunc); err != nil {
+		log.Fatalln("Refusing to load model", modelFile+":", err)
+	}
+}
+
+// FLAT_MODEL_MAGIC starts flat model files, written by WriteFlatModel
+const FLAT_MODEL_MAGIC = "YAPFLAT1"
+
+// WriteFlatModel writes a model whose weights can be memory mapped: the
+// magic, the length of the gob encoded model without its weights, the gob
+// encoded model, and the weights as a flat matrix (see model.WriteFlat)
+func WriteFlatModel(file string, data *Serialization) error {
+	weights := *data.WeightModel
+	weights.Mat, weights.Quantization, weights.Scale = nil, 0, 0
+	rest := *data
+	rest.WeightModel = &weights
+	if data.Header != nil {
+		// the flat weights are dequantized
+		header := *data.Header
+		header.FormatVersion = FormatVersion(&weights)
+		rest.Header = &header
+	}
+	var encoded bytes.Buffer
+	if err := gob.NewEncoder(&encoded).Encode(&rest); err != nil {
+		return err
+	}
+	fObj, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(fObj)
+	var length [8]byte
+	binary.LittleEndian.PutUint64(length[:], uint64(encoded.Len()))
+	writer.WriteString(FLAT_MODEL_MAGIC)
+	writer.Write(length[:])
+	writer.Write(encoded.Bytes())
+	if err := model.WriteFlat(writer, data.WeightModel); err != nil {
+		fObj.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		fObj.Close()
+		return err
+	}
+	return fObj.Close()
+}
+
+// decodeFlatModel memory maps a model written by WriteFlatModel; the mapping
+// is kept for the lifetime of the process
+func decodeFlatModel(file string) (*Serialization, error) {
+	mapped, err := util.MapFile(file)
+	if err != nil {
+		return nil, err
+	}
+	headerLen := len(FLAT_MODEL_MAGIC) + 8
+	if len(mapped) < headerLen {
+		util.UnmapFile(mapped)
+		return nil, fmt.Errorf("flat model %v is truncated", file)
+	}
+	length := binary.LittleEndian.Uint64(mapped[len(FLAT_MODEL_MAGIC):])
+	if length > uint64(len(mapped)-headerLen) {
+		util.UnmapFile(mapped)
+		return nil, fmt.Errorf("flat model %v is truncated", file)
+	}
+	data := &Serialization{}
+	if err := gob.NewDecoder(bytes.NewReader(mapped[headerLen : headerLen+int(length)])).Decode(data); err != nil {
+		util.UnmapFile(mapped)
+		return nil, fmt.Errorf("failed decoding model %v: %v", file, err)
+	}
+	flat, err := model.OpenFlat(mapped[headerLen+int(length):])
+	if err != nil {
+		util.UnmapFile(mapped)
+		return nil, fmt.Errorf("failed reading weights of flat model %v: %v", file, err)
+	}
+	data.WeightModel.SetFlat(flat)
+	return data, nil
+}
+
+// DecodeModel reads a model written by WriteModel or WriteFlatModel
+func DecodeModel(file string) (*Serialization, error) {
+	fObj, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fObj.Close()
+	reader := bufio.NewReader(fObj)
+	data := &Serialization{}
+	if magic, _ := reader.Peek(len(FLAT_MODEL_MAGIC)); string(magic) == FLAT_MODEL_MAGIC {
+		if data, err = decodeFlatModel(file); err != nil {
+			return nil, err
+		}
+	} else if err := gob.NewDecoder(reader).Decode(data); err != nil {
+		return nil, fmt.Errorf("failed decoding model %v: %v", file, err)
+	}
+	if data.Header == nil {
+		log.Println("Warning: model", file, "has no header, its features, labels and param func can't be checked")
+	}
+	return data, nil
+}
+
+// String formats the header for model info
+func (h *ModelHeader) String() string {
+	if h == nil {
+		return "Format version:\t0 (no header)\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Format version:\t%d\n", h.FormatVersion)
+	fmt.Fprintf(&b, "Yap version:\t%v\n", h.YapVersion)
+	fmt.Fprintf(&b, "Created:\t%v\n", h.Created.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Command:\t%v\n", strings.Join(h.Args, " "))
+	if len(h.FeaturesFile) > 0 {
+		fmt.Fprintf(&b, "Features file:\t%v (md5 %v)\n", h.FeaturesFile, h.FeaturesMD5)
+	}
+	if len(h.LabelsFile) > 0 {
+		fmt.Fprintf(&b, "Labels file:\t%v (md5 %v)\n", h.LabelsFile, h.LabelsMD5)
+	}
+	if len(h.ParamFunc) > 0 {
+		fmt.Fprintf(&b, "Param func:\t%v\n", h.ParamFunc)
+	}
+	if len(h.Labels) > 0 {
+		fmt.Fprintf(&b, "Added labels:\t%v\n", strings.Join(h.Labels, " "))
+	}
+	names := make([]string, 0, len(h.Flags))
+	for name := range h.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(&b, "%v flags:\n", h.Command)
+	for _, name := range names {
+		fmt.Fprintf(&b, "\t-%v=%v\n", name, h.Flags[name])
+	}
+	return b.String()
+}
+
+func ModelInfo(cmd *commander.Command, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected a model file")
+	}
+	modelFile := args[0]
+	if !VerifyExists(modelFile) {
+		location, found := util.LocateFile(modelFile, DEFAULT_MODEL_DIRS)
+		if !found {
+			return fmt.Errorf("model file %v not found", modelFile)
+		}
+		modelFile = location
+	}
+	data, err := DecodeModel(modelFile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Model:\t\t%v\n", modelFile)
+	fmt.Print(data.Header)
+	if data.WeightModel != nil && data.WeightModel.Flat() != nil {
+		fmt.Printf("Layout:\t\tflat (memory mapped)\n")
+	}
+	if data.WeightModel != nil && data.WeightModel.Quantization != 0 {
+		fmt.Printf("Quantization:\t%d bits (scale %g)\n", data.WeightModel.Quantization, data.WeightModel.Scale)
+	}
+	for _, enum := range []struct {
+		name string
+		set  *util.EnumSet
+	}{
+		{"Transitions", data.ETrans},
+		{"Words", data.EWord},
+		{"POS tags", data.EPOS},
+		{"Word/POS", data.EWPOS},
+		{"Morph hosts", data.EMHost},
+		{"Morph suffixes", data.EMSuffix},
+		{"Morph props", data.EMorphProp},
+		{"Tokens", data.ETokens},
+	} {
+		if enum.set != nil {
+			fmt.Printf("%v:\t%d\n", enum.name, enum.set.Len())
+		}
+	}
+	return nil
+}
+
+func ModelInfoCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       ModelInfo,
+		UsageLine: "info <model file>",
+		Short:     "print the header of a model file",
+		Long: `
+print the header of a model file: its format and yap versions, the training
+command and flags, and the features and labels files and param func it was
+trained with
+
+	$ ./yap model info <model file>
+
+`,
+		Flag: *flag.NewFlagSet("info", flag.ExitOnError),
+	}
+	return cmd
+}
+
+func ModelFlatten(cmd *commander.Command, args []string) error {
+	VerifyFlags(cmd, []string{"m", "o"})
+	if !VerifyExists(flattenModelFile) {
+		location, found := util.LocateFile(flattenModelFile, DEFAULT_MODEL_DIRS)
+		if !found {
+			return fmt.Errorf("model file %v not found", flattenModelFile)
+		}
+		flattenModelFile = location
+	}
+	data := ReadModel(flattenModelFile)
+	if data.WeightModel.Flat() != nil {
+		return fmt.Errorf("model %v is already flat", flattenModelFile)
+	}
+	if err := WriteFlatModel(flattenOutFile, data); err != nil {
+		return fmt.Errorf("failed writing flat model %v: %v", flattenOutFile, err)
+	}
+	log.Println("Wrote flat model", flattenOutFile)
+	return nil
+}
+
+func ModelFlattenCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       ModelFlatten,
+		UsageLine: "flatten <file options>",
+		Short:     "convert a model to the flat memory mapped format",
+		Long: `
+convert a (gob) model to the flat format, whose weights are memory mapped
+read-only instead of decoded when loaded, so that loading is near-instant and
+processes loading the same model share a single copy of its weights; flat
+models can be used for parsing with dep, md, joint and the api server
+
+	$ ./yap model flatten -m <model file> -o <flat model file>
+
+`,
+		Flag: *flag.NewFlagSet("flatten", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&flattenModelFile, "m", "", "Model file")
+	cmd.Flag.StringVar(&flattenOutFile, "o", "", "Output flat model file")
+	return cmd
+}
+
+func ModelCmd() *commander.Command {
+	return &commander.Command{
+		UsageLine: "model <command>",
+		Short:     "model file utilities",
+		Subcommands: []*commander.Command{
+			ModelInfoCmd(),
+			ModelInspectCmd(),
+			ModelPruneCmd(),
+			ModelFlattenCmd(),
+		},
+		Flag: *flag.NewFlagSet("model", flag.ExitOnError),
+	}
+}
@@ -0,0 +1,279 @@
+package app
+
+import (
+	"yap/alg/transition"
+	"yap/util"
+	"yap/util/conf"
+
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+var (
+	inspectModelFile, inspectFeaturesFile, inspectLabelsFile string
+	inspectTemplate, inspectType, inspectFormat              string
+	inspectTop                                               int
+)
+
+// InspectedWeight is a feature instance of a model and its weight for a
+// transition
+type InspectedWeight struct {
+	Transition string `json:"transition"`
+	Template   string `json:"template"`
+	Feature    string `json:"feature"`
+	Weight     int64  `json:"weight"`
+}
+
+// InspectedType holds the strongest positive and negative weights of a
+// transition type, out of all of its non-zero weights
+type InspectedType struct {
+	Type     string             `json:"type"`
+	Weights  int                `json:"weights"`
+	Positive []*InspectedWeight `json:"positive"`
+	Negative []*InspectedWeight `json:"negative"`
+}
+
+// rawWeight is a weight before its transition and feature are decoded
+type rawWeight struct {
+	row        int
+	feature    interface{}
+	transition int
+	weight     int64
+}
+
+type inspection struct {
+	weights            int
+	positive, negative []*rawWeight
+}
+
+// ClassifyTransition returns the type of a transition name of ETrans (SH, RE,
+// PR, SW, LA, RA, POP or MD) and the transition type byte of its feature group.
+// Lexical transitions, which are only trained with lemmas, are reported as MD.
+func ClassifyTransition(name string) (string, byte) {
+	switch {
+	case strings.HasPrefix(name, "LA-"):
+		return "LA", 'A'
+	case strings.HasPrefix(name, "RA-"):
+		return "RA", 'A'
+	}
+	switch name {
+	case "SH", "RE", "PR", "SW", "AL", "AR", "IDLE", "NO":
+		return name, 'A'
+	case "POP":
+		return name, 'P'
+	}
+	return "MD", 'M'
+}
+
+// FeatureGroupTypes returns the transition types of the feature groups of a
+// feature setup, for SetupExtractor
+func FeatureGroupTypes(setup *transition.FeatureSetup) []byte {
+	types := make([]byte, 0, len(setup.FeatureGroups))
+	for _, group := range setup.FeatureGroups {
+		transType := transition.ConstTransition(0).Type()
+		if len(group.Transition) > 0 {
+			transType = group.Transition[0]
+		}
+		if strings.IndexByte(string(types), transType) < 0 {
+			types = append(types, transType)
+		}
+	}
+	return types
+}
+
+// keepTop inserts w into weights, ordered by before, keeping at most n weights
+func keepTop(weights []*rawWeight, w *rawWeight, n int, before func(a, b int64) bool) []*rawWeight {
+	if n < 1 {
+		return weights
+	}
+	if len(weights) == n && !before(w.weight, weights[n-1].weight) {
+		return weights
+	}
+	i := sort.Search(len(weights), func(i int) bool { return before(w.weight, weights[i].weight) })
+	if len(weights) < n {
+		weights = append(weights, nil)
+	}
+	copy(weights[i+1:], weights[i:len(weights)-1])
+	weights[i] = w
+	return weights
+}
+
+// formatFeature decodes a feature value through the template's enumerations
+func formatFeature(template transition.FeatureTemplate, feature interface{}) (formatted string) {
+	defer func() {
+		if r := recover(); r != nil {
+			formatted = fmt.Sprintf("%v", feature)
+		}
+	}()
+	return template.FormatWithGenerator(feature, false)
+}
+
+// InspectModel returns the top n positive and negative weights of every
+// transition type of a model, whose enumerations are set in the app globals;
+// templates and types, if not nil/empty, keep only the matching feature
+// templates and transition type
+func InspectModel(data *Serialization, extractor *transition.GenericExtractor, n int, templates *regexp.Regexp, onlyType string) []*InspectedType {
+	group := func(transType byte) *transition.TransTypeGroup {
+		if g, exists := extractor.TransTypeGroups[transType]; exists {
+			return g
+		}
+		// older feature setups have a single group with no transition
+		if len(extractor.TransTypeGroups) == 1 {
+			for _, g := range extractor.TransTypeGroups {
+				return g
+			}
+		}
+		return nil
+	}
+	inspections := make(map[string]*inspection)
+	data.WeightModel.Each(func(row int, feature interface{}, trans int, weight int64) {
+		if weight == 0 || trans >= ETrans.Len() {
+			return
+		}
+		tType, transType := ClassifyTransition(fmt.Sprintf("%v", ETrans.ValueOf(trans)))
+		if len(onlyType) > 0 && tType != onlyType {
+			return
+		}
+		g := group(transType)
+		if g == nil || row >= len(g.FeatureTemplates) {
+			return
+		}
+		if templates != nil && !templates.MatchString(g.FeatureTemplates[row].String()) {
+			return
+		}
+		cur, exists := inspections[tType]
+		if !exists {
+			cur = &inspection{}
+			inspections[tType] = cur
+		}
+		cur.weights++
+		w := &rawWeight{row, feature, trans, weight}
+		if weight > 0 {
+			cur.positive = keepTop(cur.positive, w, n, func(a, b int64) bool { return a > b })
+		} else {
+			cur.negative = keepTop(cur.negative, w, n, func(a, b int64) bool { return a < b })
+		}
+	})
+	decode := func(weights []*rawWeight) []*InspectedWeight {
+		decoded := make([]*InspectedWeight, len(weights))
+		for i, w := range weights {
+			name := fmt.Sprintf("%v", ETrans.ValueOf(w.transition))
+			tType, transType := ClassifyTransition(name)
+			if tType == "MD" {
+				name = "MD-" + name
+			}
+			template := group(transType).FeatureTemplates[w.row]
+			decoded[i] = &InspectedWeight{name, template.String(), formatFeature(template, w.feature), w.weight}
+		}
+		return decoded
+	}
+	types := make([]string, 0, len(inspections))
+	for tType := range inspections {
+		types = append(types, tType)
+	}
+	sort.Strings(types)
+	retval := make([]*InspectedType, len(types))
+	for i, tType := range types {
+		cur := inspections[tType]
+		retval[i] = &InspectedType{tType, cur.weights, decode(cur.positive), decode(cur.negative)}
+	}
+	return retval
+}
+
+// WriteInspection writes the output of InspectModel as text, a block per
+// transition type with a tab separated line per weight
+func WriteInspection(writer io.Writer, inspected []*InspectedType) {
+	for _, tType := range inspected {
+		fmt.Fprintf(writer, "%v\t%d weights\n", tType.Type, tType.Weights)
+		for _, weights := range [][]*InspectedWeight{tType.Positive, tType.Negative} {
+			for _, w := range weights {
+				fmt.Fprintf(writer, "\t%d\t%v\t%v\t%v\n", w.Weight, w.Transition, w.Template, w.Feature)
+			}
+		}
+	}
+}
+
+func ModelInspect(cmd *commander.Command, args []string) error {
+	VerifyFlags(cmd, []string{"m", "f"})
+	if inspectTop < 1 {
+		log.Printf("-n must be at least 1, got %d", inspectTop)
+		cmd.Usage()
+		os.Exit(1)
+	}
+	if inspectFormat != "text" && inspectFormat != "json" {
+		return fmt.Errorf("unknown output format %v, expected text or json", inspectFormat)
+	}
+	var templates *regexp.Regexp
+	if len(inspectTemplate) > 0 {
+		var err error
+		if templates, err = regexp.Compile(inspectTemplate); err != nil {
+			return fmt.Errorf("bad template pattern %v: %v", inspectTemplate, err)
+		}
+	}
+	if location, found := util.LocateFile(inspectModelFile, DEFAULT_MODEL_DIRS); found {
+		inspectModelFile = location
+	}
+	if location, found := util.LocateFile(inspectFeaturesFile, DEFAULT_CONF_DIRS); found {
+		inspectFeaturesFile = location
+	}
+	if len(inspectLabelsFile) > 0 {
+		if location, found := util.LocateFile(inspectLabelsFile, DEFAULT_CONF_DIRS); found {
+			inspectLabelsFile = location
+		}
+		relations, err := conf.ReadFile(inspectLabelsFile)
+		if err != nil {
+			return fmt.Errorf("Failed reading dependency labels configuration file %v: %v", inspectLabelsFile, err)
+		}
+		SetupRelationEnum(relations.Values)
+	}
+	featureSetup, err := transition.LoadFeatureConfFile(inspectFeaturesFile)
+	if err != nil {
+		return fmt.Errorf("Failed reading feature configuration file %v: %v", inspectFeaturesFile, err)
+	}
+	data := ReadModel(inspectModelFile)
+	CheckModel(inspectModelFile, data, inspectFeaturesFile, inspectLabelsFile, "")
+	EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens = data.EWord, data.EPOS, data.EWPOS, data.EMHost, data.EMSuffix, data.EMorphProp, data.ETrans, data.ETokens
+	extractor := SetupExtractor(featureSetup, FeatureGroupTypes(featureSetup))
+	inspected := InspectModel(data, extractor, inspectTop, templates, inspectType)
+	if inspectFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(inspected)
+	}
+	WriteInspection(os.Stdout, inspected)
+	return nil
+}
+
+func ModelInspectCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       ModelInspect,
+		UsageLine: "inspect <file options> [arguments]",
+		Short:     "list the strongest feature weights of a model per transition type",
+		Long: `
+list the top positive and negative weighted feature instances of a model for
+every transition type (MD, POP, SH, RE, PR, LA, RA), with the feature values
+decoded through the model's enumerations
+
+	$ ./yap model inspect -m <model file> -f <features file> [-l <labels file>] [-n 20] [-template 'S0w'] [-type LA] [-format text|json]
+
+`,
+		Flag: *flag.NewFlagSet("inspect", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&inspectModelFile, "m", "", "Model file")
+	cmd.Flag.StringVar(&inspectFeaturesFile, "f", "", "Features configuration file the model was trained with")
+	cmd.Flag.StringVar(&inspectLabelsFile, "l", "", "Dependency labels configuration file, for label set features")
+	cmd.Flag.IntVar(&inspectTop, "n", 10, "Number of positive and of negative weights per transition type")
+	cmd.Flag.StringVar(&inspectTemplate, "template", "", "Only feature templates matching this regular expression (e.g. S0w)")
+	cmd.Flag.StringVar(&inspectType, "type", "", "Only this transition type (MD, POP, SH, RE, PR, LA or RA)")
+	cmd.Flag.StringVar(&inspectFormat, "format", "text", "Output format: text or json")
+	return cmd
+}
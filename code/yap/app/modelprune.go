@@ -0,0 +1,318 @@
+package app
+
+import (
+	"yap/eval"
+	"yap/nlp/format/conll"
+	"yap/nlp/format/lattice"
+	"yap/util"
+	"yap/util/conf"
+
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+var (
+	pruneModelFile, pruneOutFile    string
+	pruneDev, pruneGold, pruneCmd   string
+	pruneArgs                       string
+	pruneMinWeight, pruneMinFeature int64
+	pruneQuantize                   int
+)
+
+// pruneSkipFlags are the training command flags that aren't passed on when
+// parsing the dev set: model, training, input and output files, and logging
+var pruneSkipFlags = map[string]bool{
+	"m": true, "mn": true, "it": true, "limit": true, "limitdev": true,
+	"tc": true, "td": true, "tl": true, "ots": true, "noconverge": true,
+	"in": true, "inl": true, "ing": true, "text": true, "prefix": true, "lexicon": true,
+	"test": true, "testgold": true, "infusedev": true,
+	"oc": true, "om": true, "os": true, "oa": true,
+	"showbeam": true, "showoracle": true, "showfeats": true, "stream": true,
+	NUM_CPUS_FLAG: true, "cpuprofile": true,
+}
+
+// devAccuracy is the accuracy of a model on a dev set, keyed by metric
+type devAccuracy map[string]float64
+
+// parseDev parses the dev set with the model in modelFile by running yap's
+// command cmd, with the flags the model was trained with, and evaluates the
+// output against the gold file; the model link and outputs start with prefix
+func parseDev(modelFile, cmd string, header *ModelHeader, prefix string) (devAccuracy, error) {
+	beamSize := "64"
+	if cmd == "md" {
+		beamSize = "32"
+	}
+	args := []string{cmd}
+	if header != nil {
+		names := make([]string, 0, len(header.Flags))
+		for name := range header.Flags {
+			if !pruneSkipFlags[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			args = append(args, fmt.Sprintf("-%v=%v", name, header.Flags[name]))
+		}
+		if b, exists := header.Flags["b"]; exists {
+			beamSize = b
+		}
+	}
+	args = append(args, strings.Fields(pruneArgs)...)
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-b=") {
+			beamSize = arg[len("-b="):]
+		} else if arg == "-b" && i+1 < len(args) {
+			beamSize = args[i+1]
+		}
+	}
+	// dep and md look for {m}.b{b} when -mn isn't found
+	link := prefix
+	absModel, err := filepath.Abs(modelFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Symlink(absModel, link+".b"+beamSize); err != nil {
+		return nil, err
+	}
+	output := prefix + ".out"
+	// the beam size is set explicitly as the commands share its default
+	args = append(args, "-b", beamSize, "-in", pruneDev)
+	switch cmd {
+	case "dep":
+		args = append(args, "-m", link, "-mn", link+".b"+beamSize, "-oc", output)
+	case "md":
+		args = append(args, "-m", link, "-mn", link+".b"+beamSize, "-om", output)
+	case "joint":
+		args = append(args, "-m", link+".b"+beamSize, "-om", output,
+			"-oc", prefix+".conll", "-os", prefix+".seg")
+	default:
+		return nil, fmt.Errorf("can't evaluate models of command %v, expected dep, md or joint", cmd)
+	}
+	log.Println("Parsing", pruneDev, "with", modelFile)
+	parse := exec.Command(os.Args[0], args...)
+	parse.Stderr = os.Stderr
+	if err := parse.Run(); err != nil {
+		return nil, fmt.Errorf("failed parsing with %v: %v", modelFile, err)
+	}
+	if cmd == "dep" {
+		return depAccuracy(output, header)
+	}
+	return mdAccuracy(output)
+}
+
+func depAccuracy(output string, header *ModelHeader) (devAccuracy, error) {
+	if ERel == nil {
+		labelsFile := DepLabelsFile
+		if header != nil && len(header.LabelsFile) > 0 {
+			labelsFile = header.LabelsFile
+		}
+		relations, err := conf.ReadFile(labelsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed reading dependency labels configuration file %v: %v", labelsFile, err)
+		}
+		SetupEvalEnum(relations.Values)
+	}
+	test, err := conll.ReadFile(output, 0)
+	if err != nil {
+		return nil, err
+	}
+	gold, err := conll.ReadFile(pruneGold, 0)
+	if err != nil {
+		return nil, err
+	}
+	testGraphs := conll.Conll2GraphCorpus(test, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+	goldGraphs := conll.Conll2GraphCorpus(gold, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+	if len(testGraphs) != len(goldGraphs) {
+		return nil, fmt.Errorf("parsed %d sentences, but gold has %d", len(testGraphs), len(goldGraphs))
+	}
+	total, utotal := &eval.Total{}, &eval.Total{}
+	for i, graph := range testGraphs {
+		result := DepEvalConll(graph, goldGraphs[i])
+		total.Add(result)
+		utotal.Add(result.Other.(*eval.Result))
+	}
+	return devAccuracy{"LAS": total.Precision(), "UAS": utotal.Precision()}, nil
+}
+
+// tokenMorphemes maps the tokens of a disambiguated lattice to the counts of
+// their morphemes' form, POS and features
+func tokenMorphemes(lat lattice.Lattice) map[int]map[string]int {
+	retval := make(map[int]map[string]int)
+	for _, edges := range lat {
+		for _, edge := range edges {
+			morphs, exists := retval[edge.Token]
+			if !exists {
+				morphs = make(map[string]int)
+				retval[edge.Token] = morphs
+			}
+			morphs[edge.Word+"_"+edge.CPosTag+"_"+edge.FeatStr]++
+		}
+	}
+	return retval
+}
+
+// mdAccuracy evaluates an output mapping file against gold disambiguated
+// lattices by the F1 of morphemes (Form_POS_Prop) matched per token
+func mdAccuracy(output string) (devAccuracy, error) {
+	test, err := lattice.ReadFile(output, 0)
+	if err != nil {
+		return nil, err
+	}
+	gold, err := lattice.ReadFile(pruneGold, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(test) != len(gold) {
+		return nil, fmt.Errorf("disambiguated %d sentences, but gold has %d", len(test), len(gold))
+	}
+	total := &eval.Total{}
+	for i, lat := range test {
+		result := &eval.Result{}
+		goldMorphs := tokenMorphemes(gold[i])
+		for token, morphs := range tokenMorphemes(lat) {
+			for morph, count := range morphs {
+				matched := count
+				if goldCount := goldMorphs[token][morph]; goldCount < matched {
+					matched = goldCount
+				}
+				result.TP += matched
+				result.FP += count - matched
+			}
+		}
+		for _, morphs := range goldMorphs {
+			for _, count := range morphs {
+				result.TN += count
+			}
+		}
+		// TN holds the gold morphemes that weren't matched
+		result.TN -= result.TP
+		total.Add(result)
+	}
+	return devAccuracy{"F1": total.F1()}, nil
+}
+
+func fileSize(file string) int64 {
+	info, err := os.Stat(file)
+	if err != nil {
+		log.Fatalln("Failed reading size of", file, err)
+	}
+	return info.Size()
+}
+
+func ModelPrune(cmd *commander.Command, args []string) error {
+	VerifyFlags(cmd, []string{"m", "o"})
+	if pruneQuantize != 0 && pruneQuantize != 16 && pruneQuantize != 32 {
+		return fmt.Errorf("can't quantize to %d bits, expected 16 or 32", pruneQuantize)
+	}
+	if (len(pruneDev) > 0) != (len(pruneGold) > 0) {
+		return fmt.Errorf("evaluating on a dev set requires both -dev and -gold")
+	}
+	if !VerifyExists(pruneModelFile) {
+		location, found := util.LocateFile(pruneModelFile, DEFAULT_MODEL_DIRS)
+		if !found {
+			return fmt.Errorf("model file %v not found", pruneModelFile)
+		}
+		pruneModelFile = location
+	}
+	data := ReadModel(pruneModelFile)
+	if data.WeightModel.Flat() != nil {
+		return fmt.Errorf("can't prune flat model %v, prune the model it was flattened from", pruneModelFile)
+	}
+	if data.WeightModel.Scale != 0 {
+		return fmt.Errorf("can't prune quantized model %v, prune the model it was quantized from", pruneModelFile)
+	}
+	stats := data.WeightModel.Prune(pruneMinWeight, pruneMinFeature)
+	if pruneQuantize != 0 {
+		if err := data.WeightModel.Quantize(pruneQuantize); err != nil {
+			return err
+		}
+		if data.Header == nil {
+			// keep older yap versions from reading quantized weights as is;
+			// WriteModel sets the format version
+			data.Header = &ModelHeader{YapVersion: VERSION, Created: time.Now()}
+		}
+	}
+	WriteModel(pruneOutFile, data)
+
+	before, after := fileSize(pruneModelFile), fileSize(pruneOutFile)
+	fmt.Printf("Weights:\t%d -> %d (%d pruned)\n", stats.Weights, stats.Weights-stats.PrunedWeights, stats.PrunedWeights)
+	fmt.Printf("Features:\t%d -> %d (%d pruned)\n", stats.Features, stats.Features-stats.PrunedFeatures, stats.PrunedFeatures)
+	if pruneQuantize != 0 {
+		fmt.Printf("Quantization:\t%d bits (scale %g)\n", pruneQuantize, data.WeightModel.Scale)
+	}
+	fmt.Printf("Size:\t\t%d -> %d bytes (%+.2f%%)\n", before, after, 100*float64(after-before)/float64(before))
+
+	if len(pruneDev) == 0 {
+		return nil
+	}
+	parseCmd := pruneCmd
+	if len(parseCmd) == 0 {
+		if data.Header == nil || len(data.Header.Command) == 0 {
+			return fmt.Errorf("model %v has no header, set its command with -cmd", pruneModelFile)
+		}
+		parseCmd = data.Header.Command
+	}
+	dir, err := ioutil.TempDir("", "yap-prune")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	original, err := parseDev(pruneModelFile, parseCmd, data.Header, filepath.Join(dir, "original"))
+	if err != nil {
+		return err
+	}
+	pruned, err := parseDev(pruneOutFile, parseCmd, data.Header, filepath.Join(dir, "pruned"))
+	if err != nil {
+		return err
+	}
+	metrics := make([]string, 0, len(original))
+	for metric := range original {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+	for _, metric := range metrics {
+		fmt.Printf("%v:\t\t%.4f -> %.4f (%+.4f)\n", metric, original[metric], pruned[metric], pruned[metric]-original[metric])
+	}
+	return nil
+}
+
+func ModelPruneCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       ModelPrune,
+		UsageLine: "prune <file options> [arguments]",
+		Short:     "write a smaller model by pruning and quantizing its weights",
+		Long: `
+write a smaller copy of a model, without the weights whose absolute value is
+below -min-weight and the features whose absolute weights sum to less than
+-min-feature, and with its weights optionally quantized to 16 or 32 bits.
+Reports the size reduction, and with -dev and -gold the accuracy change on a
+dev set (LAS/UAS for dep models, morpheme F1 for md and joint models), parsed
+with the flags the model was trained with
+
+	$ ./yap model prune -m <model file> -o <output model file> [-min-weight 10] [-min-feature 0] [-quantize 16|32] [-dev <dev input> -gold <dev gold>] [-cmd dep|md|joint] [-args '<parsing flags>']
+
+`,
+		Flag: *flag.NewFlagSet("prune", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&pruneModelFile, "m", "", "Model file")
+	cmd.Flag.StringVar(&pruneOutFile, "o", "", "Output model file")
+	cmd.Flag.Int64Var(&pruneMinWeight, "min-weight", 1, "Drop weights whose absolute value is below this")
+	cmd.Flag.Int64Var(&pruneMinFeature, "min-feature", 0, "Drop features whose absolute weights sum to less than this")
+	cmd.Flag.IntVar(&pruneQuantize, "quantize", 0, "Optional - Quantize weights to 16 or 32 bits")
+	cmd.Flag.StringVar(&pruneDev, "dev", "", "Optional - Dev input file (conll for dep, ambiguous lattices for md and joint)")
+	cmd.Flag.StringVar(&pruneGold, "gold", "", "Optional - Dev gold file (conll for dep, disambiguated lattices for md and joint)")
+	cmd.Flag.StringVar(&pruneCmd, "cmd", "", "Optional - Parsing command (dep, md or joint), for models without a header")
+	cmd.Flag.StringVar(&pruneArgs, "args", "", "Optional - Additional parsing flags, e.g. the features file of models without a header")
+	return cmd
+}
@@ -0,0 +1,266 @@
+package app
+
+import (
+	transitionmodel "yap/alg/transition/model"
+	"yap/nlp/format/conll"
+	"yap/nlp/format/conllu"
+	"yap/nlp/format/lattice"
+	"yap/nlp/parser/mst"
+	nlp "yap/nlp/types"
+
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+var (
+	// DepDecoder is the decoder of the dependency parser: beam for the
+	// transition parser, mst for the graph-based parser
+	DepDecoder string
+	// MSTAlgorithm is the spanning tree algorithm of the graph-based parser,
+	// chuliu (non-projective) or eisner (projective), and MSTOrder 1 to score
+	// arcs or 2 to score adjacent siblings too (eisner only)
+	MSTAlgorithm string
+	MSTOrder     int
+)
+
+// UseMST tells if dep parses with the graph-based parser
+func UseMST() bool {
+	switch DepDecoder {
+	case "beam":
+		return false
+	case "mst":
+		return true
+	}
+	log.Fatalln("Unknown decoder", DepDecoder, "choose beam or mst")
+	return false
+}
+
+// NewMSTParser returns the graph-based parser set up by the flags
+func NewMSTParser(model *transitionmodel.AvgMatrixSparse) *mst.Parser {
+	parser := &mst.Parser{Model: model, ERel: ERel}
+	switch MSTAlgorithm {
+	case "chuliu":
+	case "eisner":
+		parser.Projective = true
+	default:
+		log.Fatalln("Unknown MST algorithm", MSTAlgorithm, "choose chuliu or eisner")
+	}
+	switch MSTOrder {
+	case 1:
+	case 2:
+		if !parser.Projective {
+			log.Fatalln("-mstorder 2 requires -mstalg eisner")
+		}
+		parser.SecondOrder = true
+	default:
+		log.Fatalln("Unknown MST order", MSTOrder, "choose 1 or 2")
+	}
+	return parser
+}
+
+// MSTConfigOut logs the configuration of the graph-based parser
+func MSTConfigOut(outModelFile string, parser *mst.Parser) {
+	log.Println("Configuration")
+	log.Printf("Decoder:\t\tMST %s", parser.Name())
+	log.Printf("Iterations:\t\t%d", Iterations)
+	log.Printf("Update:\t\t\t%s", UpdateDescription())
+	log.Printf("Model file:\t\t%s", outModelFile)
+	log.Printf("Labels File:\t\t%s", DepLabelsFile)
+	log.Println()
+}
+
+// mstModel checks a model was trained by the decoder dep parses with, and
+// sets up the graph-based parser as it was trained: its order scores
+// features the model has, or doesn't have
+func mstModel(modelFile string, header *ModelHeader) {
+	trained := "beam"
+	if header != nil && len(header.Flags["decoder"]) > 0 {
+		trained = header.Flags["decoder"]
+	}
+	if trained != DepDecoder {
+		log.Fatalln("Model", modelFile, "was trained with -decoder", trained, "not", DepDecoder)
+	}
+	if trained != "mst" {
+		return
+	}
+	if order, err := strconv.Atoi(header.Flags["mstorder"]); err == nil && order != MSTOrder {
+		if order == 2 && MSTAlgorithm != "eisner" {
+			log.Fatalln("Model", modelFile, "was trained with -mstorder 2, parse with -mstalg eisner")
+		}
+		if allOut {
+			log.Println("Parsing with the order", order, "of the model instead of", MSTOrder)
+		}
+		MSTOrder = order
+	}
+}
+
+// MSTTrainAndParse trains the graph-based parser, unless a model was read
+// (serialization), and parses the input with it; the enums are set up
+func MSTTrainAndParse(cmd *commander.Command, outModelFile string, serialization *Serialization, pseudoProjLabels []string) error {
+	if Stream || KBest > 1 || len(ConstraintsFile) > 0 || WithConfidence {
+		log.Fatalln("-stream, -kbest, -constraints and -conf can't be used with -decoder mst")
+	}
+	model := &transitionmodel.AvgMatrixSparse{}
+	if serialization == nil {
+		if allOut {
+			log.Println("Model file", outModelFile, "not found, training")
+			log.Println("Reading training sentences from", tConll)
+		}
+		if len(inputGold) > 0 {
+			log.Println("Ignoring -ing, the graph-based parser trains for -it iterations")
+		}
+		var goldGraphs []interface{}
+		if useConllU {
+			s, _, e := conllu.ReadFile(tConll, limit)
+			if e != nil {
+				log.Println(e)
+				return e
+			}
+			if allOut {
+				log.Println("Conll:\tRead", len(s), "sentences")
+			}
+			if len(PseudoProj) > 0 {
+				lifted, _ := ProjectivizeConllU(s)
+				ReportPseudoProj(lifted, len(s), pseudoProjLabels)
+			}
+			goldGraphs = conllu.ConllU2GraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+		} else {
+			s, e := conll.ReadFile(tConll, limit)
+			if e != nil {
+				log.Println(e)
+				return e
+			}
+			if allOut {
+				log.Println("Conll:\tRead", len(s), "sentences")
+			}
+			if len(PseudoProj) > 0 {
+				lifted, _ := ProjectivizeConll(s)
+				ReportPseudoProj(lifted, len(s), pseudoProjLabels)
+			}
+			goldGraphs = conll.Conll2GraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+		}
+		goldSequences := TrainingSequences(goldGraphs, GetAsTaggedSentence, GetAsLabeledDepGraph)
+		if allOut {
+			log.Println()
+			log.Println("Training", Iterations, "iteration(s)")
+		}
+		model = transitionmodel.NewAvgMatrixSparse(mst.NumFeatures, mst.Formatters(), true)
+		parser := NewMSTParser(model)
+		// the features of the graph-based parser aren't configured by a file
+		TrainHeader = NewModelHeader(cmd, "", DepLabelsFile, "")
+		TrainHeader.Labels = pseudoProjLabels
+		_ = Train(goldSequences, Iterations, DepModelFile, model, parser, parser, nil)
+		if allOut {
+			log.Println("Done Training")
+			log.Println()
+			log.Println("Writing model to", outModelFile)
+		}
+		WriteModel(outModelFile, &Serialization{
+			model.Serialize(-1),
+			EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
+			TrainHeader,
+		})
+		if allOut {
+			log.Println("Done writing model")
+		}
+	} else {
+		model.Deserialize(serialization.WeightModel)
+		EWord, EPOS, EWPOS, EMHost, EMSuffix = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix
+		if allOut && !parseOut {
+			log.Println("Loaded model")
+		}
+	}
+	if allOut {
+		log.Println()
+	}
+
+	var sents, asMorphGraphs []interface{}
+	if len(inputLat) > 0 {
+		lDisamb, lDisambE := lattice.ReadFile(inputLat, limit)
+		if lDisambE != nil {
+			log.Fatalln(lDisambE)
+		}
+		if allOut {
+			log.Println("Read", len(lDisamb), "disambiguated lattices from", inputLat)
+		}
+		internalSents := lattice.Lattice2SentenceCorpus(lDisamb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+		sents = make([]interface{}, len(internalSents))
+		for i, instance := range internalSents {
+			sents[i] = instance.(nlp.LatticeSentence).TaggedSentence()
+		}
+	} else {
+		var asGraphs []interface{}
+		if useConllU {
+			devi, _, e2 := conllu.ReadFile(input, limit)
+			if e2 != nil {
+				log.Fatalln(e2)
+			}
+			if allOut {
+				log.Println("Read", len(devi), "sentences from", input)
+			}
+			asGraphs = conllu.ConllU2GraphCorpus(devi, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+			asMorphGraphs = conllu.ConllU2MorphGraphCorpus(devi, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
+		} else {
+			devi, e2 := conll.ReadFile(input, limit)
+			if e2 != nil {
+				log.Fatalln(e2)
+			}
+			if allOut {
+				log.Println("Read", len(devi), "sentences from", input)
+			}
+			asGraphs = conll.Conll2GraphCorpus(devi, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+		}
+		sents = make([]interface{}, len(asGraphs))
+		for i, instance := range asGraphs {
+			sents[i] = GetAsTaggedSentence(instance)
+		}
+	}
+
+	if allOut && !parseOut {
+		log.Print("Parsing")
+	}
+	parsedGraphs := ParseMST(sents, NewMSTParser(model))
+	if useConllU {
+		graphAsConll := conllu.Graph2ConllUCorpus(parsedGraphs, EMHost, EMSuffix)
+		morphGraphs := conllu.MergeGraphAndMorphCorpus(graphAsConll, asMorphGraphs)
+		conllu.WriteFile(outConll, DeprojectivizeCorpus(morphGraphs))
+		if allOut && !parseOut {
+			log.Println("Wrote", len(parsedGraphs), "in conllu format to", outConll)
+		}
+	} else {
+		graphAsConll := conll.Graph2ConllCorpus(parsedGraphs, EMHost, EMSuffix)
+		conll.WriteFile(outConll, DeprojectivizeCorpus(graphAsConll))
+		if allOut && !parseOut {
+			log.Println("Wrote", len(parsedGraphs), "in conll format to", outConll)
+		}
+	}
+	return nil
+}
+
+// flagSet tells if a flag of the command was given
+func flagSet(cmd *commander.Command, name string) bool {
+	var set bool
+	cmd.Flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+// ParseMST parses the sentences with the graph-based parser
+func ParseMST(sents []interface{}, parser *mst.Parser) []interface{} {
+	startTime := time.Now()
+	parsed := make([]interface{}, len(sents))
+	for i, sent := range sents {
+		log.Println("Parsing instance", i)
+		parsed[i] = parser.Parse(sent.(nlp.EnumTaggedSentence))
+	}
+	if allOut {
+		parseTime := time.Since(startTime)
+		log.Println("PARSE Total Time:", parseTime)
+	}
+	return parsed
+}
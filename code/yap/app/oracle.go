@@ -0,0 +1,49 @@
+package app
+
+import (
+	"yap/alg/search"
+	"yap/alg/transition"
+	. "yap/nlp/parser/dependency/transition"
+
+	"log"
+)
+
+var (
+	// OracleType is the oracle of the arc system generating the training
+	// sequences (-oracle): static, or dynamic to train with exploration
+	OracleType string
+	// ExploreProb is the probability of following a wrong prediction of the
+	// model when training with the dynamic oracle (-explore)
+	ExploreProb float64
+	// ExploreAfter is the number of warm-up iterations following the
+	// dynamic oracle alone (-explorefrom)
+	ExploreAfter int
+)
+
+// AddArcOracle adds the oracle chosen by OracleType to the arc system
+func AddArcOracle(arcSystem transition.TransitionSystem) {
+	switch OracleType {
+	case "", "static":
+		arcSystem.AddDefaultOracle()
+	case "dynamic":
+		eager, isEager := arcSystem.(*ArcEager)
+		if !isEager {
+			log.Fatalln("The dynamic oracle requires the eager arc system (-a eager)")
+		}
+		eager.AddDynamicOracle()
+	default:
+		log.Fatalln("Unknown oracle", OracleType, "- choose static or dynamic")
+	}
+}
+
+// SetExploration makes the gold decoder explore the model's predictions
+// when training with the dynamic oracle
+func SetExploration(deterministic *search.Deterministic) {
+	if OracleType != "dynamic" {
+		return
+	}
+	deterministic.Explore, deterministic.ExploreAfter = ExploreProb, ExploreAfter
+	if allOut {
+		log.Println("Exploring with probability", ExploreProb, "from iteration", ExploreAfter)
+	}
+}
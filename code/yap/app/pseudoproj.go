@@ -0,0 +1,209 @@
+package app
+
+import (
+	"yap/nlp/format/conll"
+	"yap/nlp/format/conllu"
+	"yap/nlp/parser/pseudoproj"
+
+	"log"
+	"sort"
+)
+
+// PseudoProj is the encoding of the arcs lifted to make the training trees
+// projective (-pseudoproj): head, path or head+path; empty to train on the
+// trees as they are
+var PseudoProj string
+
+// pseudoProjEncoding returns the encoding of PseudoProj
+func pseudoProjEncoding() pseudoproj.Encoding {
+	encoding, err := pseudoproj.ParseEncoding(PseudoProj)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return encoding
+}
+
+// PseudoProjLabels projectivizes the training file and returns the encoded
+// labels of its lifted arcs, sorted, to add to the labels of the parser
+func PseudoProjLabels(trainFile string) []string {
+	if len(PseudoProj) == 0 {
+		return nil
+	}
+	var labels []string
+	if useConllU {
+		sents, _, err := conllu.ReadFile(trainFile, limit)
+		if err != nil {
+			log.Fatalln("Failed reading", trainFile, err)
+		}
+		_, labels = ProjectivizeConllU(sents)
+	} else {
+		sents, err := conll.ReadFile(trainFile, limit)
+		if err != nil {
+			log.Fatalln("Failed reading", trainFile, err)
+		}
+		_, labels = ProjectivizeConll(sents)
+	}
+	return labels
+}
+
+// ProjectivizeConll lifts the non-projective arcs of CoNLL sentences; it
+// returns the number of arcs lifted and their encoded labels
+func ProjectivizeConll(sents []conll.Sentence) (int, []string) {
+	trees := make([]depTree, len(sents))
+	for i, sent := range sents {
+		trees[i] = conllTree(sent)
+	}
+	return projectivize(trees)
+}
+
+// ProjectivizeConllU lifts the non-projective arcs of CoNLL-U sentences; it
+// returns the number of arcs lifted and their encoded labels
+func ProjectivizeConllU(sents []*conllu.Sentence) (int, []string) {
+	trees := make([]depTree, len(sents))
+	for i, sent := range sents {
+		trees[i] = conllUTree(sent.Deps)
+	}
+	return projectivize(trees)
+}
+
+// ReportPseudoProj logs the number of arcs lifted in the training sentences
+func ReportPseudoProj(lifted, numSents int, labels []string) {
+	log.Printf("Pseudo-projective (%v): lifted %d arcs of %d sentences, adding %d labels", PseudoProj, lifted, numSents, len(labels))
+}
+
+func projectivize(trees []depTree) (int, []string) {
+	encoding := pseudoProjEncoding()
+	var lifted int
+	encoded := make(map[string]bool)
+	for _, tree := range trees {
+		heads, labels := tree.get()
+		if numLifted := pseudoproj.Projectivize(heads, labels, encoding); numLifted > 0 {
+			lifted += numLifted
+			for _, label := range labels {
+				if pseudoproj.Base(label) != label {
+					encoded[label] = true
+				}
+			}
+			tree.set(heads, labels)
+		}
+	}
+	labels := make([]string, 0, len(encoded))
+	for label := range encoded {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return lifted, labels
+}
+
+// DeprojectivizeCorpus lowers the lifted arcs of parsed CoNLL or CoNLL-U
+// sentences, in place, when parsing with a pseudo-projective model
+func DeprojectivizeCorpus(sents []interface{}) []interface{} {
+	if len(PseudoProj) == 0 {
+		return sents
+	}
+	encoding := pseudoProjEncoding()
+	for _, sent := range sents {
+		deprojectivize(sent, encoding)
+	}
+	return sents
+}
+
+// DeprojectivizeStream lowers the lifted arcs of a stream of parsed CoNLL
+// sentences
+func DeprojectivizeStream(sents chan interface{}) chan interface{} {
+	if len(PseudoProj) == 0 {
+		return sents
+	}
+	encoding := pseudoProjEncoding()
+	deprojectivized := make(chan interface{}, cap(sents))
+	go func() {
+		for sent := range sents {
+			deprojectivize(sent, encoding)
+			deprojectivized <- sent
+		}
+		close(deprojectivized)
+	}()
+	return deprojectivized
+}
+
+func deprojectivize(sent interface{}, encoding pseudoproj.Encoding) {
+	var tree depTree
+	switch s := sent.(type) {
+	case conll.Sentence:
+		tree = conllTree(s)
+	case conllu.Sentence:
+		tree = conllUTree(s.Deps)
+	case *conllu.Sentence:
+		tree = conllUTree(s.Deps)
+	default:
+		log.Panicf("Can't deprojectivize %T", sent)
+	}
+	heads, labels := tree.get()
+	pseudoproj.Deprojectivize(heads, labels, encoding)
+	tree.set(heads, labels)
+}
+
+// depTree gets and sets the heads and labels of the rows of a sentence
+type depTree struct {
+	get func() ([]int, []string)
+	set func([]int, []string)
+}
+
+func conllTree(sent conll.Sentence) depTree {
+	return depTree{
+		get: func() ([]int, []string) {
+			heads, labels := make([]int, len(sent)), make([]string, len(sent))
+			for i := range heads {
+				heads[i], labels[i] = sent[i+1].Head, sent[i+1].DepRel
+			}
+			return heads, labels
+		},
+		set: func(heads []int, labels []string) {
+			for i := range heads {
+				row := sent[i+1]
+				row.Head, row.DepRel = heads[i], labels[i]
+				sent[i+1] = row
+			}
+		},
+	}
+}
+
+func conllUTree(deps map[int]conllu.Row) depTree {
+	return depTree{
+		get: func() ([]int, []string) {
+			heads, labels := make([]int, len(deps)), make([]string, len(deps))
+			for i := range heads {
+				heads[i], labels[i] = deps[i+1].Head, deps[i+1].DepRel
+			}
+			return heads, labels
+		},
+		set: func(heads []int, labels []string) {
+			for i := range heads {
+				row := deps[i+1]
+				row.Head, row.DepRel = heads[i], labels[i]
+				deps[i+1] = row
+			}
+		},
+	}
+}
+
+// pseudoProjModel sets up parsing with a model trained with -pseudoproj: it
+// takes the encoding the model was trained with and returns the labels the
+// model added to its labels file
+func pseudoProjModel(modelFile string, header *ModelHeader) []string {
+	if header == nil || len(header.Flags["pseudoproj"]) == 0 {
+		if len(PseudoProj) > 0 {
+			log.Fatalln("Model", modelFile, "wasn't trained with -pseudoproj")
+		}
+		return nil
+	}
+	trained := header.Flags["pseudoproj"]
+	if len(PseudoProj) > 0 && PseudoProj != trained {
+		log.Fatalln("Model", modelFile, "was trained with -pseudoproj", trained, "not", PseudoProj)
+	}
+	PseudoProj = trained
+	if allOut {
+		log.Println("Deprojectivizing the parses with the", PseudoProj, "encoding of", len(header.Labels), "labels")
+	}
+	return header.Labels
+}
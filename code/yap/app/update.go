@@ -0,0 +1,110 @@
+package app
+
+import (
+	"yap/alg/perceptron"
+	"yap/alg/search"
+	"yap/nlp/parser/disambig"
+	"yap/nlp/parser/joint"
+	nlp "yap/nlp/types"
+
+	"fmt"
+	"log"
+)
+
+var (
+	// UpdateRule is the update of the perceptron training (-update):
+	// perceptron for the fixed update, pa1 or pa2 for the passive-aggressive
+	// (MIRA) updates, and PAC their aggressiveness (-C)
+	UpdateRule string
+	PAC        float64
+)
+
+// NewUpdateStep returns the update step of the training set up by the
+// flags, nil for the perceptron update
+func NewUpdateStep() perceptron.UpdateStep {
+	var variant int
+	switch UpdateRule {
+	case "perceptron":
+		return nil
+	case "pa1":
+		variant = 1
+	case "pa2":
+		variant = 2
+	default:
+		log.Fatalln("Unknown update", UpdateRule, "choose perceptron, pa1 or pa2")
+	}
+	if PAC <= 0 {
+		log.Fatalln("-C must be positive, got", PAC)
+	}
+	return &perceptron.PassiveAggressive{Variant: variant, C: PAC, Cost: UpdateCost}
+}
+
+// UpdateDescription describes the update of the training for the
+// configuration output
+func UpdateDescription() string {
+	if step, ok := NewUpdateStep().(fmt.Stringer); ok {
+		return step.String()
+	}
+	return "Perceptron"
+}
+
+// UpdateCost is the cost of a wrongly decoded instance of the passive-
+// aggressive updates: its wrong morphemes, for morphological disambiguation,
+// its wrong arcs, for dependency parsing, or both, for joint parsing. Early
+// updates cost the mistakes of the partial decoded instance.
+func UpdateCost(gold, decoded perceptron.DecodedInstance) float64 {
+	goldDecoded := gold.Decoded()
+	if sequence, ok := goldDecoded.(search.ScoredConfigurations); ok {
+		goldDecoded = sequence[len(sequence)-1].C
+	}
+	switch decodedConf := decoded.Decoded().(type) {
+	case *joint.JointConfig:
+		goldConf := goldDecoded.(*joint.JointConfig)
+		return float64(wrongMorphemes(decodedConf.MDConfig.Mappings, goldConf.MDConfig.Mappings) + wrongArcs(decodedConf, goldConf))
+	case *disambig.MDConfig:
+		return float64(wrongMorphemes(decodedConf.Mappings, goldDecoded.(*disambig.MDConfig).Mappings))
+	case nlp.LabeledDependencyGraph:
+		return float64(wrongArcs(decodedConf, goldDecoded.(nlp.LabeledDependencyGraph)))
+	default:
+		panic(fmt.Sprintf("Can't compute the update cost of %T", decodedConf))
+	}
+}
+
+// wrongMorphemes counts the morphemes of the decoded mappings that aren't in
+// the gold mappings of their token
+func wrongMorphemes(decoded, gold nlp.Mappings) int {
+	var wrong int
+	for i, mapping := range decoded {
+		if i >= len(gold) {
+			wrong += len(mapping.Spellout)
+			continue
+		}
+		_, _, FP, _ := mapping.Spellout.Compare(gold[i].Spellout, MdParamFuncName)
+		wrong += FP
+	}
+	return wrong
+}
+
+// wrongArcs counts the arcs of the decoded graph whose head or label differ
+// from the gold arc of their modifier
+func wrongArcs(decoded, gold nlp.LabeledDependencyGraph) int {
+	var wrong int
+	goldArcs := arcsByModifier(gold)
+	for _, arc := range arcsByModifier(decoded) {
+		goldArc, exists := goldArcs[arc.GetModifier()]
+		if !exists || goldArc.GetHead() != arc.GetHead() || goldArc.GetRelation() != arc.GetRelation() {
+			wrong++
+		}
+	}
+	return wrong
+}
+
+func arcsByModifier(g nlp.LabeledDependencyGraph) map[int]nlp.LabeledDepArc {
+	arcs := make(map[int]nlp.LabeledDepArc, g.NumberOfNodes())
+	for i := 0; i < g.NumberOfNodes(); i++ {
+		if arc := g.GetLabeledArc(i); arc != nil {
+			arcs[arc.GetModifier()] = arc
+		}
+	}
+	return arcs
+}
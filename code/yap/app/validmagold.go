@@ -89,8 +89,9 @@ func genInstance(goldLat, ambLat nlp.LatticeSentence) *disambig.MDConfig {
 			continue
 		}
 		mapping := &nlp.Mapping{
-			lat.Token,
-			lat.Spellouts[0],
+			Token:    lat.Token,
+			Spellout: lat.Spellouts[0],
+			Range:    lat.Range,
 		}
 		mappings[i] = mapping
 	}
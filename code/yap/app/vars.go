@@ -62,6 +62,8 @@ var (
 
 	// enumeration offsets of transitions
 	SH, RE, PR, LA, RA, IDLE, POP, MD transition.Transition
+	// SW is the swap transition, only enumerated for the swap arc system
+	SW transition.Transition
 	//DepSH, DepRE, DepPR, DepLA, DepRA, DepIDLE, DepPOP, DepMD transition.Transition
 
 	// file names
@@ -106,6 +108,7 @@ type Serialization struct {
 	EMorphProp                           *util.EnumSet
 	ETrans                               *util.EnumSet
 	ETokens                              *util.EnumSet
+	Header                               *ModelHeader
 }
 
 func WriteModel(file string, data *Serialization) {
@@ -121,6 +124,9 @@ func WriteModel(file string, data *Serialization) {
 		}
 	}()
 	//defer fObj.Close()
+	if data.Header != nil {
+		data.Header.FormatVersion = FormatVersion(data.WeightModel)
+	}
 	writer := gob.NewEncoder(fObj)
 	err = writer.Encode(data)
 	if err != nil {
@@ -130,15 +136,11 @@ func WriteModel(file string, data *Serialization) {
 }
 
 func ReadModel(file string) *Serialization {
-	data := &Serialization{}
-	fObj, err := os.Open(file)
+	data, err := DecodeModel(file)
 	if err != nil {
 		log.Fatalln("Failed reading model from", file, err)
 		return nil
 	}
-	defer fObj.Close()
-	reader := gob.NewDecoder(fObj)
-	reader.Decode(data)
 	return data
 }
 
@@ -162,10 +164,13 @@ func SetupTransEnum(relations []string) {
 	_, _ = ETrans.Add("AL") // dummy action transition for zpar equivalence
 	_, _ = ETrans.Add("AR") // dummy action transition for zpar equivalence
 	iPR, _ := ETrans.Add("PR")
+	// the arc hybrid system shares SH, LA, RA and PR (its root arc) with the
+	// other arc systems
 	SH = transition.ConstTransition(iSH)
 	RE = transition.ConstTransition(iRE)
 	PR = transition.ConstTransition(iPR)
-	LA = transition.ConstTransition(iPR + 1)
+	SetupSwapEnum()
+	LA = transition.ConstTransition(ETrans.Len())
 	ETrans.Add("LA-" + string(nlp.ROOT_LABEL))
 	for _, transition := range relations {
 		ETrans.Add("LA-" + string(transition))
@@ -177,6 +182,28 @@ func SetupTransEnum(relations []string) {
 	}
 }
 
+// SetupSwapEnum adds the swap transition when parsing with the swap arc
+// system, keeping the transitions of models of other arc systems unchanged
+func SetupSwapEnum() {
+	if DepArcSystemStr != "swap" {
+		return
+	}
+	iSW, _ := ETrans.Add("SW")
+	SW = transition.ConstTransition(iSW)
+}
+
+// arcSystemModel checks a model was trained with the arc system it is
+// loaded with, as the swap transition shifts the arc transitions of the swap
+// system's models
+func arcSystemModel(modelFile string, header *ModelHeader) {
+	if header == nil || len(header.Flags["a"]) == 0 {
+		return
+	}
+	if trained := header.Flags["a"]; trained != DepArcSystemStr {
+		log.Fatalln("Model", modelFile, "was trained with -a", trained, "not", DepArcSystemStr)
+	}
+}
+
 func SetupMorphTransEnum(relations []string) {
 	ETrans = util.NewEnumSet((len(relations)+1)*2 + 2 + APPROX_MORPH_TRANSITIONS)
 	_, _ = ETrans.Add("NO") // dummy for 0 action
@@ -189,9 +216,10 @@ func SetupMorphTransEnum(relations []string) {
 	SH = transition.ConstTransition(iSH)
 	RE = transition.ConstTransition(iRE)
 	PR = transition.ConstTransition(iPR)
+	SetupSwapEnum()
 	// IDLE = transition.Transition(iIDLE)
 	// LA = IDLE + 1
-	LA = transition.ConstTransition(iPR + 1)
+	LA = transition.ConstTransition(ETrans.Len())
 	ETrans.Add("LA-" + string(nlp.ROOT_LABEL))
 	for _, transition := range relations {
 		ETrans.Add("LA-" + string(transition))
@@ -410,6 +438,7 @@ func Train(trainingSet []perceptron.DecodedInstance, Iterations int, filename st
 		Decoder:     decoder,
 		GoldDecoder: goldDecoder,
 		Updater:     updater,
+		Step:        NewUpdateStep(),
 		Continue:    converge,
 		Tempfile:    filename,
 		TempLines:   500}
@@ -842,6 +871,7 @@ func serialize(perceptronModel perceptron.Model, iteration, generations int) str
 	serialization := &Serialization{
 		perceptronModel.(*model.AvgMatrixSparse).Serialize(generations),
 		EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
+		TrainHeader,
 	}
 	modelFile := fmt.Sprintf("model.temp.i%d", iteration)
 	WriteModel(modelFile, serialization)
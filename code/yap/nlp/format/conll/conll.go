@@ -389,6 +389,12 @@ func WriteStreamToFile(filename string, sents chan interface{}) error {
 }
 
 func GetMorphProperties(node *transition.TaggedDepNode, eMHost, eMSuffix *util.EnumSet) string {
+	if eMHost.IsUnknown(node.MHost) || eMSuffix.IsUnknown(node.MSuffix) {
+		if len(node.RawFeats) > 0 {
+			return node.RawFeats
+		}
+		return "_"
+	}
 	host := eMHost.ValueOf(node.MHost).(string)
 	suffix := eMSuffix.ValueOf(node.MSuffix).(string)
 	if len(host) > 0 && len(suffix) > 0 {
@@ -513,6 +519,7 @@ func Conll2Graph(sent Sentence, eWord, ePOS, eWPOS, eRel, eMHost, eMSuffix *util
 			Id:       i - 1,
 			RawToken: row.Form,
 			RawPOS:   row.CPosTag,
+			RawFeats: row.FeatStr,
 		}
 
 		switch WORD_TYPE {
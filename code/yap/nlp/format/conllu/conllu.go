@@ -127,6 +127,9 @@ type Sentence struct {
 	Tokens   []string
 	Mappings nlp.Mappings
 	Comments []string
+	// TokenMisc holds MISC values of the multiword token range lines,
+	// by mapping; tokens of a single morpheme have no range line
+	TokenMisc []string
 }
 
 func NewSentence() *Sentence {
@@ -454,55 +457,68 @@ func ReadFileAsStream(filename string, limit int) (chan *Sentence, error) {
 	return ReadStream(file, limit), nil
 }
 
-func Write(writer io.Writer, sents []interface{}) {
+// tokenMisc is the MISC value holding a token's offsets in the input text
+func tokenMisc(mapping *nlp.Mapping) string {
+	if !mapping.Range.IsSet() {
+		return ""
+	}
+	return "TokenRange=" + mapping.Range.String()
+}
+
+// AddMisc appends a value to a MISC field
+func AddMisc(misc, value string) string {
+	if len(misc) == 0 || misc == "_" {
+		return value
+	}
+	if len(value) == 0 {
+		return misc
+	}
+	return misc + "|" + value
+}
+
+// writeSentence writes a sentence's rows; the MISC of a multiword token
+// range line (or of a single morpheme token's row) holds the token range
+func writeSentence(writer io.Writer, sent Sentence) {
 	var lastToken int
-	for _, genericsent := range sents {
-		lastToken = 0
-		// log.Println("Write sent")
-		sent := genericsent.(Sentence)
-		for i := 1; i <= len(sent.Deps); i++ {
-			// log.Println("At dep", i)
-			row := sent.Deps[i]
-			if row.TokenID > lastToken {
-				mapping := sent.Mappings[row.TokenID-1]
-				if len(mapping.Spellout) > 1 {
-					writer.Write([]byte(fmt.Sprintf("%d-%d\t%s", i, i+len(mapping.Spellout)-1, mapping.Token)))
-					for j := 0; j < 8; j++ {
-						writer.Write([]byte("\t_"))
-					}
-					writer.Write([]byte("\n"))
+	for i := 1; i <= len(sent.Deps); i++ {
+		// log.Println("At dep", i)
+		row := sent.Deps[i]
+		if row.TokenID > lastToken {
+			mapping := sent.Mappings[row.TokenID-1]
+			misc := tokenMisc(mapping)
+			if len(mapping.Spellout) > 1 {
+				if row.TokenID-1 < len(sent.TokenMisc) {
+					misc = AddMisc(misc, sent.TokenMisc[row.TokenID-1])
 				}
+				writer.Write([]byte(fmt.Sprintf("%d-%d\t%s", i, i+len(mapping.Spellout)-1, mapping.Token)))
+				for j := 0; j < 7; j++ {
+					writer.Write([]byte("\t_"))
+				}
+				if len(misc) == 0 {
+					misc = "_"
+				}
+				writer.Write([]byte("\t" + misc + "\n"))
+			} else {
+				row.Misc = AddMisc(row.Misc, misc)
 			}
-			writer.Write(append([]byte(row.String()), '\n'))
-			lastToken = row.TokenID
 		}
-		writer.Write([]byte{'\n'})
+		writer.Write(append([]byte(row.String()), '\n'))
+		lastToken = row.TokenID
+	}
+	writer.Write([]byte{'\n'})
+}
+
+func Write(writer io.Writer, sents []interface{}) {
+	for _, genericsent := range sents {
+		// log.Println("Write sent")
+		writeSentence(writer, genericsent.(Sentence))
 	}
 }
 
 func WriteStream(writer io.Writer, sents chan interface{}) {
-	var lastToken int
 	for genericsent := range sents {
-		lastToken = 0
 		// log.Println("Write sent")
-		sent := genericsent.(Sentence)
-		for i := 1; i <= len(sent.Deps); i++ {
-			// log.Println("At dep", i)
-			row := sent.Deps[i]
-			if row.TokenID > lastToken {
-				mapping := sent.Mappings[row.TokenID-1]
-				if len(mapping.Spellout) > 1 {
-					writer.Write([]byte(fmt.Sprintf("%d-%d\t%s", i, i+len(mapping.Spellout)-1, mapping.Token)))
-					for j := 0; j < 8; j++ {
-						writer.Write([]byte("\t_"))
-					}
-					writer.Write([]byte("\n"))
-				}
-			}
-			writer.Write(append([]byte(row.String()), '\n'))
-			lastToken = row.TokenID
-		}
-		writer.Write([]byte{'\n'})
+		writeSentence(writer, genericsent.(Sentence))
 	}
 }
 
@@ -658,6 +674,7 @@ func ConllU2MorphGraph(sent *Sentence, eWord, ePOS, eWPOS, eRel, eMFeat, eMHost,
 			Id:       i - 1,
 			RawToken: row.Form,
 			RawPOS:   row.UPosTag,
+			RawFeats: row.FeatStr,
 		}
 
 		switch WORD_TYPE {
@@ -724,7 +741,7 @@ func ConllU2MorphGraph(sent *Sentence, eWord, ePOS, eWPOS, eRel, eMFeat, eMHost,
 
 	for i, lat := range lattices {
 		lat.GenSpellouts()
-		mappings[i] = &nlp.Mapping{lat.Token, lat.Spellouts[0]}
+		mappings[i] = &nlp.Mapping{Token: lat.Token, Spellout: lat.Spellouts[0]}
 	}
 
 	morphGraph := &morphtypes.BasicMorphGraph{
@@ -765,6 +782,7 @@ func ConllU2Graph(sent *Sentence, eWord, ePOS, eWPOS, eRel, eMHost, eMSuffix *ut
 			Id:       i - 1,
 			RawToken: row.Form,
 			RawPOS:   row.UPosTag,
+			RawFeats: row.FeatStr,
 		}
 
 		switch WORD_TYPE {
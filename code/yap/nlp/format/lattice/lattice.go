@@ -114,6 +114,7 @@ type Edge struct {
 	Token    int
 	Id       int
 	TokenStr string
+	Range    nlp.TokenRange // offsets of the token in the input text, not serialized
 }
 
 type EdgeSlice []Edge
@@ -1060,6 +1061,37 @@ func ReadUDFile(filename string, limit int) ([]Lattice, error) {
 	return UDRead(file, limit)
 }
 
+// Reparse round trips lattices through the lattice format, so that their
+// edges are exactly as read from a lattice file (parsed features, edge ids);
+// token ranges, which the format doesn't carry, are kept
+func Reparse(lattices []Lattice) ([]Lattice, error) {
+	buf := new(bytes.Buffer)
+	if err := Write(buf, lattices); err != nil {
+		return nil, err
+	}
+	reparsed, err := Read(buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(reparsed) != len(lattices) {
+		return nil, errors.New(fmt.Sprintf("Reparsed %d lattices, expected %d", len(reparsed), len(lattices)))
+	}
+	for i, lat := range reparsed {
+		ranges := make(map[int]nlp.TokenRange)
+		for _, edges := range lattices[i] {
+			for _, edge := range edges {
+				ranges[edge.Token] = edge.Range
+			}
+		}
+		for _, edges := range lat {
+			for j := range edges {
+				edges[j].Range = ranges[edges[j].Token]
+			}
+		}
+	}
+	return reparsed, nil
+}
+
 func WriteStreamToFile(filename string, sents chan Lattice) error {
 	file, err := os.Create(filename)
 	defer file.Close()
@@ -1138,6 +1170,7 @@ func Lattice2Sentence(lattice Lattice, eWord, ePOS, eWPOS, eMorphFeat, eMHost, e
 			skipEdge = false
 
 			lat := &sent[edge.Token-1]
+			lat.Range = edge.Range
 
 			// FIX Fusional 'H' in Modern Hebrew Corpus
 			if _FIX_FUSIONAL_H {
@@ -1345,6 +1378,7 @@ func Sentence2Lattice(lattice nlp.LatticeSentence, xliter8or xliter8.Interface)
 				m.TokenID,
 				m.ID(),
 				string(sentlat.Token),
+				sentlat.Range,
 			}
 			if len(m.FeatureStr) == 0 {
 				e.FeatStr = "_"
@@ -8,40 +8,56 @@ import (
 	nlp "yap/nlp/types"
 
 	"bufio"
-	"bytes"
 	"io"
 	// "log"
 	"os"
+	"strings"
+	"unicode/utf8"
 )
 
 func Read(reader io.Reader, limit int) ([]nlp.BasicSentence, error) {
-	var sentences []nlp.BasicSentence
+	sentences, _, err := ReadWithRanges(reader, limit)
+	return sentences, err
+}
+
+// ReadWithRanges also returns the rune offsets of every token in the input
+func ReadWithRanges(reader io.Reader, limit int) ([]nlp.BasicSentence, [][]nlp.TokenRange, error) {
+	var (
+		sentences []nlp.BasicSentence
+		ranges    [][]nlp.TokenRange
+	)
 	bufReader := bufio.NewReader(reader)
 
 	var (
-		i int
+		offset int
 	)
 	currentSent := make(nlp.BasicSentence, 0, 10)
-	for curLine, isPrefix, err := bufReader.ReadLine(); err == nil; curLine, isPrefix, err = bufReader.ReadLine() {
-		if isPrefix {
-			panic("Buffer not large enough, fix me :(")
-		}
-		buf := bytes.NewBuffer(curLine)
-		// log.Println("At record", i)
+	currentRanges := make([]nlp.TokenRange, 0, 10)
+	for curLine, err := bufReader.ReadString('\n'); len(curLine) > 0; curLine, err = bufReader.ReadString('\n') {
+		token := strings.TrimRight(curLine, "\r\n")
 		// an empty line indicates a new record
-		if len(curLine) == 0 {
+		if len(token) == 0 {
+			if err != nil {
+				// an incomplete last line is not a sentence end
+				break
+			}
 			sentences = append(sentences, currentSent)
+			ranges = append(ranges, currentRanges)
 			if limit > 0 && len(sentences) >= limit {
 				break
 			}
 			currentSent = make(nlp.BasicSentence, 0, 10)
+			currentRanges = make([]nlp.TokenRange, 0, 10)
 		} else {
-			currentSent = append(currentSent, nlp.Token(buf.String()))
+			currentSent = append(currentSent, nlp.Token(token))
+			currentRanges = append(currentRanges, nlp.TokenRange{Start: offset, End: offset + utf8.RuneCountInString(token)})
+		}
+		offset += utf8.RuneCountInString(curLine)
+		if err != nil {
+			break
 		}
-
-		i++
 	}
-	return sentences, nil
+	return sentences, ranges, nil
 }
 
 func ReadFile(filename string, limit int) ([]nlp.BasicSentence, error) {
@@ -0,0 +1,359 @@
+package tokenize
+
+// Package tokenize splits running Hebrew text into sentences and tokens
+// in the form expected by the morphological analyzer (see format/raw)
+
+import (
+	nlp "yap/nlp/types"
+
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode"
+)
+
+const (
+	GERESH    = '׳'
+	GERSHAYIM = '״'
+	MAQAF     = '־'
+	SOF_PASUQ = '׃'
+)
+
+var (
+	// tokens ending a sentence
+	SENTENCE_FINAL = map[string]bool{".": true, "?": true, "!": true, "...": true, "…": true, string(SOF_PASUQ): true}
+	// tokens following a sentence final token that still belong to the sentence
+	SENTENCE_CLOSERS = map[string]bool{"\"": true, "'": true, ")": true, "]": true, "”": true, "’": true, string(GERSHAYIM): true}
+	// closers that also open quotes; they only close a sentence if they close
+	// a quote opened in it
+	STRAIGHT_QUOTES = map[string]bool{"\"": true, "'": true, string(GERSHAYIM): true}
+
+	URL_PREFIXES = []string{"http://", "https://", "ftp://", "www."}
+	// trailing punctuation that is not considered part of a URL
+	URL_TRAILING = ".,;:!?)]\"'"
+	// separators allowed between digits of a number (1,000 3.14 12:30 1/2)
+	NUMBER_SEPARATORS = ".,:/"
+)
+
+func IsHebrewLetter(r rune) bool {
+	return r >= 'א' && r <= 'ת'
+}
+
+// IsHebrewMark is true for niqqud and cantillation marks, which never
+// separate tokens; maqaf and sof pasuq are punctuation
+func IsHebrewMark(r rune) bool {
+	return r >= '֑' && r <= 'ׇ' && r != MAQAF && r != SOF_PASUQ && r != '׀' && r != '׆'
+}
+
+func isLatinLetter(r rune) bool {
+	return unicode.IsLetter(r) && !IsHebrewLetter(r) && r < '֐'
+}
+
+func isQuote(r rune) bool {
+	return r == '"' || r == GERSHAYIM || r == '“' || r == '”'
+}
+
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == GERESH || r == '’'
+}
+
+type scanner struct {
+	text []rune
+	pos  int
+}
+
+func (s *scanner) at(i int) rune {
+	if i < 0 || i >= len(s.text) {
+		return 0
+	}
+	return s.text[i]
+}
+
+func (s *scanner) hasPrefix(prefix string) bool {
+	p := []rune(prefix)
+	if s.pos+len(p) > len(s.text) {
+		return false
+	}
+	return strings.EqualFold(string(s.text[s.pos:s.pos+len(p)]), prefix)
+}
+
+func (s *scanner) scanURL() int {
+	end := s.pos
+	for end < len(s.text) && !unicode.IsSpace(s.text[end]) {
+		end++
+	}
+	for end > s.pos && strings.ContainsRune(URL_TRAILING, s.text[end-1]) {
+		end--
+	}
+	return end
+}
+
+// scanHebrew consumes a Hebrew word, keeping geresh (ג'ירפה, פרופ')
+// and gershayim between letters (צה"ל) inside the token
+func (s *scanner) scanHebrew() int {
+	end := s.pos
+	for end < len(s.text) {
+		r := s.text[end]
+		switch {
+		case IsHebrewLetter(r) || IsHebrewMark(r):
+			end++
+		case isApostrophe(r) && IsHebrewLetter(s.at(end-1)):
+			end++
+		case isQuote(r) && IsHebrewLetter(s.at(end-1)) && IsHebrewLetter(s.at(end+1)):
+			end++
+		default:
+			return end
+		}
+	}
+	return end
+}
+
+func (s *scanner) scanNumber() int {
+	end := s.pos
+	for end < len(s.text) {
+		r := s.text[end]
+		switch {
+		case unicode.IsDigit(r):
+			end++
+		case strings.ContainsRune(NUMBER_SEPARATORS, r) && unicode.IsDigit(s.at(end+1)) && unicode.IsDigit(s.at(end-1)):
+			end++
+		default:
+			return end
+		}
+	}
+	return end
+}
+
+// scanLatin consumes a run of Latin letters and digits, keeping inner
+// apostrophes (don't) and hyphens (e-mail)
+func (s *scanner) scanLatin() int {
+	end := s.pos
+	for end < len(s.text) {
+		r := s.text[end]
+		switch {
+		case isLatinLetter(r) || unicode.IsDigit(r):
+			end++
+		case (isApostrophe(r) || r == '-') && isLatinLetter(s.at(end-1)) && isLatinLetter(s.at(end+1)):
+			end++
+		default:
+			return end
+		}
+	}
+	return end
+}
+
+func (s *scanner) scanPunct() int {
+	r := s.text[s.pos]
+	if r == '.' {
+		end := s.pos
+		for end < len(s.text) && s.text[end] == '.' {
+			end++
+		}
+		if end-s.pos >= 3 {
+			return end
+		}
+	}
+	return s.pos + 1
+}
+
+// next returns the rune span of the next token, or false at end of text
+func (s *scanner) next() (int, int, bool) {
+	for s.pos < len(s.text) && (unicode.IsSpace(s.text[s.pos]) || unicode.Is(unicode.Cf, s.text[s.pos])) {
+		s.pos++
+	}
+	if s.pos >= len(s.text) {
+		return 0, 0, false
+	}
+	var end int
+	r := s.text[s.pos]
+	switch {
+	case s.isURLStart():
+		end = s.scanURL()
+	case IsHebrewLetter(r):
+		end = s.scanHebrew()
+	case unicode.IsDigit(r):
+		end = s.scanNumber()
+	case isLatinLetter(r):
+		end = s.scanLatin()
+	default:
+		end = s.scanPunct()
+	}
+	start := s.pos
+	s.pos = end
+	return start, end, true
+}
+
+func (s *scanner) isURLStart() bool {
+	for _, prefix := range URL_PREFIXES {
+		if s.hasPrefix(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Span is a token and its rune offsets in the tokenized text
+type Span struct {
+	Form  string
+	Range nlp.TokenRange
+}
+
+// TokenizeSpans splits text into tokens, without splitting sentences
+func TokenizeSpans(text string) []Span {
+	s := &scanner{text: []rune(text)}
+	var spans []Span
+	for start, end, ok := s.next(); ok; start, end, ok = s.next() {
+		spans = append(spans, Span{string(s.text[start:end]), nlp.TokenRange{Start: start, End: end}})
+	}
+	return spans
+}
+
+// Tokenize splits text into tokens, without splitting sentences
+func Tokenize(text string) []string {
+	var tokens []string
+	for _, span := range TokenizeSpans(text) {
+		tokens = append(tokens, span.Form)
+	}
+	return tokens
+}
+
+// sentenceEnds returns the index following the last token of each sentence,
+// splitting after sentence final punctuation and keeping closing quotes and
+// brackets with the preceding sentence; a straight quote is closing if it
+// closes a quote opened in the sentence, and otherwise opens the next one
+func sentenceEnds(tokens []string) []int {
+	var (
+		ends  []int
+		ended bool
+		open  = make(map[string]bool)
+	)
+	for i, token := range tokens {
+		closing := SENTENCE_CLOSERS[token] && (!STRAIGHT_QUOTES[token] || open[token])
+		if ended && !closing && !SENTENCE_FINAL[token] {
+			ends = append(ends, i)
+			ended = false
+			open = make(map[string]bool)
+		}
+		if STRAIGHT_QUOTES[token] {
+			open[token] = !open[token]
+		}
+		if SENTENCE_FINAL[token] {
+			ended = true
+		}
+	}
+	if len(tokens) > 0 {
+		ends = append(ends, len(tokens))
+	}
+	return ends
+}
+
+// SplitSentences splits a token sequence after sentence final punctuation,
+// keeping closing quotes and brackets with the preceding sentence
+func SplitSentences(tokens []string) [][]string {
+	var (
+		sents [][]string
+		start int
+	)
+	for _, end := range sentenceEnds(tokens) {
+		sents = append(sents, tokens[start:end])
+		start = end
+	}
+	return sents
+}
+
+// isParagraphBreak is true if the whitespace between two tokens contains
+// an empty line, which always ends a sentence
+func isParagraphBreak(gap []rune) bool {
+	var newlines int
+	for _, r := range gap {
+		if r == '\n' {
+			newlines++
+		}
+	}
+	return newlines > 1
+}
+
+// SentenceSpans tokenizes running text into sentences, keeping the offsets
+// of each token in text
+func SentenceSpans(text string) [][]Span {
+	runes := []rune(text)
+	spans := TokenizeSpans(text)
+	var (
+		sents [][]Span
+		start int
+	)
+	splitParagraph := func(paragraph []Span) {
+		forms := make([]string, len(paragraph))
+		for i, span := range paragraph {
+			forms[i] = span.Form
+		}
+		var sentStart int
+		for _, end := range sentenceEnds(forms) {
+			sents = append(sents, paragraph[sentStart:end])
+			sentStart = end
+		}
+	}
+	for i := 1; i <= len(spans); i++ {
+		if i == len(spans) || isParagraphBreak(runes[spans[i-1].Range.End:spans[i].Range.Start]) {
+			splitParagraph(spans[start:i])
+			start = i
+		}
+	}
+	return sents
+}
+
+// SentencesWithRanges tokenizes running text into sentences, returning the
+// rune offsets of every token in text alongside each sentence
+func SentencesWithRanges(text string) ([]nlp.BasicSentence, [][]nlp.TokenRange) {
+	spanSents := SentenceSpans(text)
+	sents := make([]nlp.BasicSentence, len(spanSents))
+	ranges := make([][]nlp.TokenRange, len(spanSents))
+	for i, spans := range spanSents {
+		sents[i] = make(nlp.BasicSentence, len(spans))
+		ranges[i] = make([]nlp.TokenRange, len(spans))
+		for j, span := range spans {
+			sents[i][j] = nlp.Token(span.Form)
+			ranges[i][j] = span.Range
+		}
+	}
+	return sents, ranges
+}
+
+// Sentences tokenizes running text into sentences
+func Sentences(text string) []nlp.BasicSentence {
+	sents, _ := SentencesWithRanges(text)
+	return sents
+}
+
+func Read(reader io.Reader, limit int) ([]nlp.BasicSentence, error) {
+	sents, _, err := ReadWithRanges(reader, limit)
+	return sents, err
+}
+
+func ReadWithRanges(reader io.Reader, limit int) ([]nlp.BasicSentence, [][]nlp.TokenRange, error) {
+	text, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	sents, ranges := SentencesWithRanges(string(text))
+	if limit > 0 && len(sents) > limit {
+		sents, ranges = sents[:limit], ranges[:limit]
+	}
+	return sents, ranges, nil
+}
+
+func ReadFile(filename string, limit int) ([]nlp.BasicSentence, error) {
+	sents, _, err := ReadFileWithRanges(filename, limit)
+	return sents, err
+}
+
+func ReadFileWithRanges(filename string, limit int) ([]nlp.BasicSentence, [][]nlp.TokenRange, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	return ReadWithRanges(file, limit)
+}
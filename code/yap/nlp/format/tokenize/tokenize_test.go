@@ -0,0 +1,107 @@
+package tokenize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected []string
+	}{
+		{"גנן גידל דגן בגן.", []string{"גנן", "גידל", "דגן", "בגן", "."}},
+		{"חיילי צה\"ל ראו ג'ירפה", []string{"חיילי", "צה\"ל", "ראו", "ג'ירפה"}},
+		{"פרופ׳ כהן מצה״ל", []string{"פרופ׳", "כהן", "מצה״ל"}},
+		{"הוא אמר \"שלום\"", []string{"הוא", "אמר", "\"", "שלום", "\""}},
+		{"בית־ספר", []string{"בית", "־", "ספר"}},
+		{"שילם 1,000.50 ש\"ח ב-12:30", []string{"שילם", "1,000.50", "ש\"ח", "ב", "-", "12:30"}},
+		{"ראו https://example.com/a?b=1.", []string{"ראו", "https://example.com/a?b=1", "."}},
+		{"הוא קנה iPhone 12 don't", []string{"הוא", "קנה", "iPhone", "12", "don't"}},
+		{"חכה...", []string{"חכה", "..."}},
+	}
+	for _, c := range cases {
+		tokens := Tokenize(c.text)
+		if !reflect.DeepEqual(tokens, c.expected) {
+			t.Errorf("Tokenize(%q): expected %q, got %q", c.text, c.expected, tokens)
+		}
+	}
+}
+
+func TestSentences(t *testing.T) {
+	sents := Sentences("הוא בא. היא אמרה \"לא!\" ואז הלכה\n\nכותרת ללא נקודה\nהמשך")
+	expected := [][]string{
+		{"הוא", "בא", "."},
+		{"היא", "אמרה", "\"", "לא", "!", "\""},
+		{"ואז", "הלכה"},
+		{"כותרת", "ללא", "נקודה", "המשך"},
+	}
+	if len(sents) != len(expected) {
+		t.Fatalf("Expected %d sentences, got %d: %v", len(expected), len(sents), sents)
+	}
+	for i, sent := range sents {
+		if !reflect.DeepEqual(sent.Tokens(), expected[i]) {
+			t.Errorf("Sentence %d: expected %q, got %q", i, expected[i], sent.Tokens())
+		}
+	}
+}
+
+func TestSentencesQuotes(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected [][]string
+	}{
+		// a quote opening the next sentence stays with it
+		{"הוא אמר. \"שלום לכם\" והלך.", [][]string{
+			{"הוא", "אמר", "."},
+			{"\"", "שלום", "לכם", "\"", "והלך", "."},
+		}},
+		{"הוא אמר: 'שלום.' והלך. 'לאן?' שאלה", [][]string{
+			{"הוא", "אמר", ":", "'", "שלום", ".", "'"},
+			{"והלך", "."},
+			{"'", "לאן", "?", "'"},
+			{"שאלה"},
+		}},
+		// closing brackets and curly quotes always close the sentence
+		{"(הוא בא.) “היא לא.” סוף", [][]string{
+			{"(", "הוא", "בא", ".", ")"},
+			{"“", "היא", "לא", ".", "”"},
+			{"סוף"},
+		}},
+	}
+	for _, c := range cases {
+		sents := Sentences(c.text)
+		if len(sents) != len(c.expected) {
+			t.Errorf("Sentences(%q): expected %d sentences, got %d: %v", c.text, len(c.expected), len(sents), sents)
+			continue
+		}
+		for i, sent := range sents {
+			if !reflect.DeepEqual(sent.Tokens(), c.expected[i]) {
+				t.Errorf("Sentences(%q) sentence %d: expected %q, got %q", c.text, i, c.expected[i], sent.Tokens())
+			}
+		}
+	}
+}
+
+func TestSentencesWithRanges(t *testing.T) {
+	text := "שלום, עולם.\n\nמה ב-12:30?"
+	runes := []rune(text)
+	sents, ranges := SentencesWithRanges(text)
+	if len(sents) != 2 || len(ranges) != 2 {
+		t.Fatalf("Expected 2 sentences, got %d: %v", len(sents), sents)
+	}
+	for i, sent := range sents {
+		if len(ranges[i]) != len(sent) {
+			t.Fatalf("Sentence %d: expected %d ranges, got %d", i, len(sent), len(ranges[i]))
+		}
+		for j, token := range sent {
+			r := ranges[i][j]
+			if substr := string(runes[r.Start:r.End]); substr != string(token) {
+				t.Errorf("Sentence %d token %d: range %v is %q, expected %q", i, j, r, substr, token)
+			}
+		}
+	}
+	if r := ranges[1][0]; r.Start != 13 || r.End != 15 {
+		t.Errorf("Expected first token of second sentence at 13:15, got %v", r)
+	}
+}
@@ -0,0 +1,175 @@
+package agreement
+
+// Package agreement checks gender agreement between cardinal numerals and
+// the nouns they quantify in the morpho-syntactic parses of the joint parser
+
+import (
+	"yap/nlp/numerals"
+	nlp "yap/nlp/types"
+
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Mismatch is a numeral whose gender doesn't agree with its noun;
+// morpheme ids match the ids of the parser's CoNLL output
+type Mismatch struct {
+	Sentence        int             `json:"sentence"`
+	Token           int             `json:"token"`
+	TokenForm       string          `json:"token_form"`
+	Range           *nlp.TokenRange `json:"range,omitempty"`
+	Numeral         int             `json:"numeral"`
+	NumeralForm     string          `json:"numeral_form"`
+	NumeralGender   string          `json:"numeral_gender"`
+	Noun            int             `json:"noun"`
+	NounForm        string          `json:"noun_form"`
+	NounGender      string          `json:"noun_gender"`
+	Suggestion      string          `json:"suggestion,omitempty"`
+	TokenSuggestion string          `json:"token_suggestion,omitempty"`
+}
+
+func (m *Mismatch) String() string {
+	return fmt.Sprintf("%v (%v) -> %v (%v): %v", m.NumeralForm, m.NumeralGender, m.NounForm, m.NounGender, m.Suggestion)
+}
+
+// morphTokens returns the index of the mapping (token) of every morpheme
+func morphTokens(mappings nlp.Mappings) []int {
+	var tokens []int
+	for i, m := range mappings {
+		if m.Token == nlp.ROOT_TOKEN {
+			continue
+		}
+		for _, morph := range m.Spellout {
+			if morph != nil {
+				tokens = append(tokens, i)
+			}
+		}
+	}
+	return tokens
+}
+
+// Check returns the gender agreement errors between numerals and nouns in
+// a parsed sentence
+func Check(graph nlp.MorphDependencyGraph) []*Mismatch {
+	morphs := numerals.Morphemes(graph)
+	mappings := graph.GetMappings()
+	tokens := morphTokens(mappings)
+	pairs := numerals.QuantifiedNouns(graph, morphs)
+
+	var mismatches []*Mismatch
+	for numeral := 0; numeral < len(morphs); numeral++ {
+		noun, exists := pairs[numeral]
+		if !exists {
+			continue
+		}
+		numMorph, nounMorph := morphs[numeral], morphs[noun]
+		nounGender := numerals.Gender(nounMorph.FeatureStr)
+		if len(nounGender) == 0 {
+			continue
+		}
+		value, formGender, construct, known := numerals.Lookup(numMorph.Form)
+		unitNoun, hasUnit := pairs[numeral-1]
+		teen := known && numerals.IsTeen(numMorph.Form) && hasUnit && unitNoun == noun
+		var numGender, suggestion string
+		switch {
+		case teen:
+			numGender, suggestion = numerals.TeenGender(numMorph.Form), numerals.TeenForm(nounGender)
+		default:
+			numGender = numerals.Gender(numMorph.FeatureStr)
+			if len(numGender) == 0 {
+				numGender = formGender
+			}
+			if known {
+				suggestion = numerals.Form(value, nounGender, construct || numMorph.CPOS == numerals.CONSTRUCT_POS)
+			}
+		}
+		if len(numGender) == 0 || numGender == nounGender {
+			continue
+		}
+		mismatch := &Mismatch{
+			Numeral:       numeral + 1,
+			NumeralForm:   numMorph.Form,
+			NumeralGender: numGender,
+			Noun:          noun + 1,
+			NounForm:      nounMorph.Form,
+			NounGender:    nounGender,
+			Suggestion:    suggestion,
+		}
+		if suggestion == numMorph.Form {
+			mismatch.Suggestion = ""
+		}
+		if numeral < len(tokens) {
+			mapping := mappings[tokens[numeral]]
+			mismatch.Token = tokens[numeral] + 1
+			mismatch.TokenForm = string(mapping.Token)
+			if mapping.Range.IsSet() {
+				tokenRange := mapping.Range
+				mismatch.Range = &tokenRange
+			}
+			if len(mismatch.Suggestion) > 0 && strings.HasSuffix(mismatch.TokenForm, numMorph.Form) {
+				mismatch.TokenSuggestion = strings.TrimSuffix(mismatch.TokenForm, numMorph.Form) + mismatch.Suggestion
+			}
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+	return mismatches
+}
+
+// CheckCorpus checks every parsed graph, numbering sentences from 1
+func CheckCorpus(graphs []interface{}) []*Mismatch {
+	var mismatches []*Mismatch
+	for i, graph := range graphs {
+		for _, mismatch := range Check(graph.(nlp.MorphDependencyGraph)) {
+			mismatch.Sentence = i + 1
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	return mismatches
+}
+
+func field(value string) string {
+	if len(value) == 0 {
+		return "_"
+	}
+	return value
+}
+
+// Write writes a mismatch per line: sentence, token, token range, token form,
+// numeral id, form and gender, noun id, form and gender, suggested numeral
+// and suggested token
+func Write(writer io.Writer, mismatches []*Mismatch) {
+	for _, m := range mismatches {
+		tokenRange := "_"
+		if m.Range != nil {
+			tokenRange = m.Range.String()
+		}
+		fields := []string{
+			fmt.Sprintf("%d", m.Sentence),
+			fmt.Sprintf("%d", m.Token),
+			tokenRange,
+			field(m.TokenForm),
+			fmt.Sprintf("%d", m.Numeral),
+			m.NumeralForm,
+			m.NumeralGender,
+			fmt.Sprintf("%d", m.Noun),
+			m.NounForm,
+			m.NounGender,
+			field(m.Suggestion),
+			field(m.TokenSuggestion),
+		}
+		writer.Write([]byte(strings.Join(fields, "\t")))
+		writer.Write([]byte{'\n'})
+	}
+}
+
+func WriteFile(filename string, mismatches []*Mismatch) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	Write(file, mismatches)
+	return nil
+}
@@ -0,0 +1,93 @@
+package agreement
+
+import (
+	"testing"
+
+	"yap/nlp/numerals"
+	"yap/nlp/parser/dependency/transition"
+	"yap/nlp/parser/dependency/transition/morph"
+	nlp "yap/nlp/types"
+)
+
+type testMorph struct {
+	form, pos, feats string
+	token, head      int
+	rel              string
+}
+
+// buildGraph builds a parsed sentence; heads are 1-based, 0 is the root
+func buildGraph(tokens []string, morphs []testMorph) nlp.MorphDependencyGraph {
+	graph := &morph.BasicMorphGraph{}
+	mappings := make(nlp.Mappings, len(tokens))
+	for i, token := range tokens {
+		mappings[i] = &nlp.Mapping{Token: nlp.Token(token), Range: nlp.TokenRange{Start: i * 10, End: i*10 + len([]rune(token))}}
+	}
+	for i, m := range morphs {
+		emorph := &nlp.EMorpheme{Morpheme: nlp.Morpheme{Form: m.form, CPOS: m.pos, POS: m.pos, FeatureStr: m.feats, TokenID: m.token}}
+		graph.Nodes = append(graph.Nodes, emorph)
+		graph.Arcs = append(graph.Arcs, &transition.BasicDepArc{Head: m.head - 1, Modifier: i, RawRelation: nlp.DepRel(m.rel)})
+		mappings[m.token-1].Spellout = append(mappings[m.token-1].Spellout, emorph)
+	}
+	graph.Mappings = mappings
+	return graph
+}
+
+func TestCheckNumRelation(t *testing.T) {
+	graph := buildGraph([]string{"ראיתי", "ושלוש", "ילדים"}, []testMorph{
+		{"ראיתי", "VB", "gen=F|gen=M|num=S|per=1", 1, 0, "ROOT"},
+		{"ו", "CONJ", "_", 2, 1, "conj"},
+		{"שלוש", "CD", "gen=F|num=P", 2, 4, "num"},
+		{"ילדים", "NN", "gen=M|num=P", 3, 2, "obj"},
+	})
+	mismatches := Check(graph)
+	if len(mismatches) != 1 {
+		t.Fatalf("Expected 1 mismatch, got %v", mismatches)
+	}
+	m := mismatches[0]
+	if m.Numeral != 3 || m.Noun != 4 || m.NumeralGender != numerals.FEMININE || m.NounGender != numerals.MASCULINE {
+		t.Errorf("Unexpected mismatch %+v", m)
+	}
+	if m.Suggestion != "שלושה" || m.TokenSuggestion != "ושלושה" {
+		t.Errorf("Expected suggestion שלושה (ושלושה), got %v (%v)", m.Suggestion, m.TokenSuggestion)
+	}
+	if m.Token != 2 || m.Range == nil || m.Range.Start != 10 || m.Range.End != 15 {
+		t.Errorf("Expected token 2 at 10:15, got %v at %v", m.Token, m.Range)
+	}
+}
+
+func TestCheckConstruct(t *testing.T) {
+	graph := buildGraph([]string{"שלוש", "הילדים"}, []testMorph{
+		{"שלוש", "CDT", "gen=F|num=P", 1, 0, "ROOT"},
+		{"ה", "DEF", "_", 2, 3, "def"},
+		{"ילדים", "NN", "gen=M|num=P", 2, 1, "gobj"},
+	})
+	mismatches := Check(graph)
+	if len(mismatches) != 1 || mismatches[0].Suggestion != "שלושת" {
+		t.Fatalf("Expected construct suggestion שלושת, got %v", mismatches)
+	}
+}
+
+func TestCheckTeen(t *testing.T) {
+	graph := buildGraph([]string{"שלושה", "עשרה", "ילדים"}, []testMorph{
+		{"שלושה", "CD", "gen=M|num=P", 1, 3, "num"},
+		{"עשרה", "CD", "gen=M|num=P", 2, 1, "dep"},
+		{"ילדים", "NN", "gen=M|num=P", 3, 0, "ROOT"},
+	})
+	mismatches := Check(graph)
+	if len(mismatches) != 1 || mismatches[0].Numeral != 2 || mismatches[0].Suggestion != "עשר" {
+		t.Fatalf("Expected teen suggestion עשר, got %v", mismatches)
+	}
+}
+
+func TestCheckAgreement(t *testing.T) {
+	graph := buildGraph([]string{"שלוש", "ילדות", "ושמונה", "ילדים"}, []testMorph{
+		{"שלוש", "CD", "gen=F|num=P", 1, 2, "num"},
+		{"ילדות", "NN", "gen=F|num=P", 2, 0, "ROOT"},
+		{"ו", "CONJ", "_", 3, 2, "conj"},
+		{"שמונה", "CD", "_", 3, 5, "num"},
+		{"ילדים", "NN", "gen=M|num=P", 4, 3, "conj"},
+	})
+	if mismatches := Check(graph); len(mismatches) != 0 {
+		t.Errorf("Expected no mismatches, got %v", mismatches)
+	}
+}
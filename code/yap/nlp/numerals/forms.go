@@ -0,0 +1,117 @@
+package numerals
+
+const (
+	MASCULINE = "M"
+	FEMININE  = "F"
+)
+
+// numeralForm is a cardinal numeral surface form; Gender is empty if the
+// form is shared by both genders (e.g. שמונה)
+type numeralForm struct {
+	Value     int
+	Gender    string
+	Construct bool
+}
+
+// numeralForms holds the masculine and feminine, absolute and construct
+// forms of a cardinal; the first spelling of each is the one suggested
+type numeralForms struct {
+	Value            int
+	Masc, MascConstr []string
+	Fem, FemConstr   []string
+}
+
+var (
+	CARDINALS = []numeralForms{
+		{1, []string{"אחד"}, []string{"אחד"}, []string{"אחת"}, []string{"אחת"}},
+		{2, []string{"שניים", "שנים"}, []string{"שני"}, []string{"שתיים", "שתים"}, []string{"שתי"}},
+		{3, []string{"שלושה", "שלשה"}, []string{"שלושת", "שלשת"}, []string{"שלוש", "שלש"}, []string{"שלוש", "שלש"}},
+		{4, []string{"ארבעה"}, []string{"ארבעת"}, []string{"ארבע"}, []string{"ארבע"}},
+		{5, []string{"חמישה", "חמשה"}, []string{"חמשת", "חמישת"}, []string{"חמש"}, []string{"חמש"}},
+		{6, []string{"שישה", "ששה"}, []string{"ששת", "שישת"}, []string{"שש"}, []string{"שש"}},
+		{7, []string{"שבעה"}, []string{"שבעת"}, []string{"שבע"}, []string{"שבע"}},
+		{8, []string{"שמונה"}, []string{"שמונת"}, []string{"שמונה"}, []string{"שמונה"}},
+		{9, []string{"תשעה"}, []string{"תשעת"}, []string{"תשע"}, []string{"תשע"}},
+		{10, []string{"עשרה"}, []string{"עשרת"}, []string{"עשר"}, []string{"עשר"}},
+	}
+
+	// the second part of 11-19 takes the opposite form of 10
+	// (שלושה עשר ילדים, שלוש עשרה ילדות)
+	TEEN_MASC = "עשר"
+	TEEN_FEM  = "עשרה"
+
+	forms    = make(map[string]numeralForm)
+	cardinal = make(map[int]numeralForms)
+)
+
+func init() {
+	add := func(spellings []string, value int, gender string, construct bool) {
+		for _, form := range spellings {
+			if cur, exists := forms[form]; exists {
+				if cur.Gender != gender {
+					cur.Gender = ""
+					forms[form] = cur
+				}
+				continue
+			}
+			forms[form] = numeralForm{value, gender, construct}
+		}
+	}
+	for _, c := range CARDINALS {
+		cardinal[c.Value] = c
+		add(c.Masc, c.Value, MASCULINE, false)
+		add(c.Fem, c.Value, FEMININE, false)
+		add(c.MascConstr, c.Value, MASCULINE, true)
+		add(c.FemConstr, c.Value, FEMININE, true)
+	}
+}
+
+// Lookup returns the value, gender and state of a numeral form
+func Lookup(form string) (value int, gender string, construct bool, found bool) {
+	numeral, found := forms[form]
+	return numeral.Value, numeral.Gender, numeral.Construct, found
+}
+
+// Form returns the form of value (1-10) agreeing with gender, in construct
+// state if construct is set; empty if value is not a known cardinal
+func Form(value int, gender string, construct bool) string {
+	c, exists := cardinal[value]
+	if !exists {
+		return ""
+	}
+	switch {
+	case gender == MASCULINE && construct:
+		return c.MascConstr[0]
+	case gender == MASCULINE:
+		return c.Masc[0]
+	case gender == FEMININE && construct:
+		return c.FemConstr[0]
+	case gender == FEMININE:
+		return c.Fem[0]
+	}
+	return ""
+}
+
+// IsTeen is true for the second part of the numerals 11-19
+func IsTeen(form string) bool {
+	return form == TEEN_MASC || form == TEEN_FEM
+}
+
+// TeenForm returns the second part of 11-19 agreeing with gender
+func TeenForm(gender string) string {
+	switch gender {
+	case MASCULINE:
+		return TEEN_MASC
+	case FEMININE:
+		return TEEN_FEM
+	}
+	return ""
+}
+
+// TeenGender returns the gender of a noun agreeing with the teen form
+func TeenGender(form string) string {
+	if form == TEEN_MASC {
+		return MASCULINE
+	}
+	return FEMININE
+}
@@ -0,0 +1,152 @@
+package numerals
+
+import (
+	nlp "yap/nlp/types"
+
+	"strings"
+)
+
+var DEFINITE_POS = map[string]bool{"DEF": true}
+
+// definite is true if the noun morpheme is preceded by a definite article
+// of the same token (הילדים)
+func definite(morphs []*nlp.EMorpheme, noun int) bool {
+	return noun > 0 && DEFINITE_POS[morphs[noun-1].CPOS] && morphs[noun-1].TokenID == morphs[noun].TokenID
+}
+
+// setFeature sets a feature of a morpheme, replacing any value it had
+func setFeature(morph *nlp.EMorpheme, name, value string) {
+	var pairs []string
+	for _, pair := range strings.Split(morph.FeatureStr, "|") {
+		if len(pair) > 0 && pair != "_" && !strings.HasPrefix(pair, name+"=") {
+			pairs = append(pairs, pair)
+		}
+	}
+	morph.FeatureStr = strings.Join(append(pairs, name+"="+value), "|")
+	if morph.Features == nil {
+		morph.Features = make(map[string]string)
+	}
+	morph.Features[name] = value
+}
+
+// SpellOutGraph replaces the digits of every numeral morpheme of a parsed
+// sentence with Hebrew words agreeing with the noun the numeral is attached
+// to; a numeral preceding its noun takes construct state for two and for a
+// definite noun (שני ילדים, שלושת הילדים). Morphemes are changed in place;
+// tokens keep their original digits. Returns the number of numerals spelled out.
+func SpellOutGraph(graph nlp.MorphDependencyGraph) int {
+	morphs := Morphemes(graph)
+	pairs := QuantifiedNouns(graph, morphs)
+	var spelled int
+	for i, morph := range morphs {
+		if morph == nil || !NUMERAL_POS[morph.CPOS] {
+			continue
+		}
+		var gender string
+		var construct bool
+		if noun, exists := pairs[i]; exists {
+			gender = Gender(morphs[noun].FeatureStr)
+			construct = noun > i && (morph.Form == "2" || definite(morphs, noun))
+		}
+		words, ok := SpellOut(morph.Form, gender, construct)
+		if !ok {
+			continue
+		}
+		if morph.Lemma == morph.Form {
+			morph.Lemma = words
+		}
+		morph.Form = words
+		if len(gender) > 0 && len(Gender(morph.FeatureStr)) == 0 {
+			setFeature(morph, "gen", gender)
+		}
+		spelled++
+	}
+	return spelled
+}
+
+// SpellOutCorpus spells out the numerals of every parsed graph
+func SpellOutCorpus(graphs []interface{}) int {
+	var spelled int
+	for _, graph := range graphs {
+		spelled += SpellOutGraph(graph.(nlp.MorphDependencyGraph))
+	}
+	return spelled
+}
+
+// numeralRun returns the end (exclusive) of the numeral compound starting
+// at morpheme start, including the conjunctions inside it, and whether it
+// has a morpheme tagged as a numeral
+func numeralRun(morphs []*nlp.EMorpheme, start int) (int, bool) {
+	var tagged bool
+	end := start
+	for end < len(morphs) {
+		morph := morphs[end]
+		switch {
+		case morph == nil:
+			return end, tagged
+		case NUMERAL_POS[morph.CPOS] || IsNumberWord(morph.Form):
+			tagged = tagged || NUMERAL_POS[morph.CPOS]
+		case morph.Form == AND && end > start && end+1 < len(morphs) && morphs[end+1] != nil && IsNumberWord(morphs[end+1].Form):
+		default:
+			return end, tagged
+		}
+		end++
+	}
+	return end, tagged
+}
+
+// annotate sets the value of every morpheme of a compound but its
+// conjunctions
+func annotate(morphs []*nlp.EMorpheme, value string) {
+	for _, morph := range morphs {
+		if morph.Form != AND {
+			setFeature(morph, NUM_VALUE_FEATURE, value)
+		}
+	}
+}
+
+// AnnotateValues recognizes the numeral compounds of a parsed sentence
+// (adjacent numeral morphemes, e.g. שלושה עשר אלף ומאתיים) and sets their
+// value as the NumValue feature of each of their morphemes; numerals that
+// don't make up a single number are valued separately. Returns the number
+// of numbers valued.
+func AnnotateValues(graph nlp.MorphDependencyGraph) int {
+	morphs := Morphemes(graph)
+	var valued int
+	for start := 0; start < len(morphs); {
+		end, tagged := numeralRun(morphs, start)
+		if end == start {
+			start++
+			continue
+		}
+		if tagged {
+			run := morphs[start:end]
+			forms := make([]string, len(run))
+			for i, morph := range run {
+				forms[i] = morph.Form
+			}
+			if value, ok := Value(forms); ok {
+				annotate(run, value)
+				valued++
+			} else {
+				for _, morph := range run {
+					if value, ok := Value([]string{morph.Form}); ok && NUMERAL_POS[morph.CPOS] {
+						annotate([]*nlp.EMorpheme{morph}, value)
+						valued++
+					}
+				}
+			}
+		}
+		start = end
+	}
+	return valued
+}
+
+// AnnotateCorpus sets the values of the numerals of every parsed graph
+func AnnotateCorpus(graphs []interface{}) int {
+	var valued int
+	for _, graph := range graphs {
+		valued += AnnotateValues(graph.(nlp.MorphDependencyGraph))
+	}
+	return valued
+}
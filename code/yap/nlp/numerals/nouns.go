@@ -0,0 +1,97 @@
+package numerals
+
+import (
+	nlp "yap/nlp/types"
+
+	"strings"
+)
+
+var (
+	NUMERAL_POS = map[string]bool{"CD": true, "CDT": true, "NUM": true}
+	NOUN_POS    = map[string]bool{"NN": true, "NNT": true, "NOUN": true}
+	// relations attaching a numeral to the noun it quantifies
+	NUMERAL_RELATIONS = map[string]bool{"num": true, "nummod": true}
+
+	CONSTRUCT_POS = "CDT"
+)
+
+// Gender returns the single gender in a feature string, or empty if it has
+// none or both (gen=F|gen=M)
+func Gender(featureStr string) string {
+	var gender string
+	for _, feat := range strings.Split(featureStr, "|") {
+		if !strings.HasPrefix(feat, "gen=") {
+			continue
+		}
+		value := strings.TrimPrefix(feat, "gen=")
+		if value != MASCULINE && value != FEMININE {
+			continue
+		}
+		if len(gender) > 0 && gender != value {
+			return ""
+		}
+		gender = value
+	}
+	return gender
+}
+
+// Morphemes returns the morphemes of a parsed sentence by vertex index
+func Morphemes(graph nlp.MorphDependencyGraph) []*nlp.EMorpheme {
+	vertices := graph.GetVertices()
+	morphs := make([]*nlp.EMorpheme, len(vertices))
+	for i, nodeID := range vertices {
+		morphs[i] = graph.GetMorpheme(nodeID)
+	}
+	return morphs
+}
+
+// QuantifiedNouns pairs each numeral morpheme with the noun it quantifies:
+// either through a numeral relation, or for a construct state numeral
+// through any arc between them (שלושת הילדים)
+func QuantifiedNouns(graph nlp.MorphDependencyGraph, morphs []*nlp.EMorpheme) map[int]int {
+	pairs := make(map[int]int)
+	var construct []nlp.LabeledDepArc
+	for _, arcID := range graph.GetEdges() {
+		arc := graph.GetLabeledArc(arcID)
+		if arc == nil {
+			continue
+		}
+		head, modifier := arc.GetHead(), arc.GetModifier()
+		if head < 0 || head >= len(morphs) || modifier < 0 || modifier >= len(morphs) {
+			continue
+		}
+		if NUMERAL_RELATIONS[string(arc.GetRelation())] {
+			switch {
+			case NUMERAL_POS[morphs[modifier].CPOS] && NOUN_POS[morphs[head].CPOS]:
+				pairs[modifier] = head
+			case NUMERAL_POS[morphs[head].CPOS] && NOUN_POS[morphs[modifier].CPOS]:
+				pairs[head] = modifier
+			}
+		} else {
+			construct = append(construct, arc)
+		}
+	}
+	for _, arc := range construct {
+		head, modifier := arc.GetHead(), arc.GetModifier()
+		if _, exists := pairs[head]; !exists && morphs[head].CPOS == CONSTRUCT_POS && NOUN_POS[morphs[modifier].CPOS] {
+			pairs[head] = modifier
+		}
+	}
+	for _, arc := range construct {
+		head, modifier := arc.GetHead(), arc.GetModifier()
+		if _, exists := pairs[modifier]; !exists && morphs[modifier].CPOS == CONSTRUCT_POS && NOUN_POS[morphs[head].CPOS] {
+			pairs[modifier] = head
+		}
+	}
+	// the second part of 11-19 quantifies the same noun as the first
+	for numeral, noun := range pairs {
+		next := numeral + 1
+		if _, exists := pairs[next]; !exists && next < len(morphs) && next != noun &&
+			NUMERAL_POS[morphs[next].CPOS] && IsTeen(morphs[next].Form) {
+			if value, _, _, found := Lookup(morphs[numeral].Form); found && value < 10 {
+				pairs[next] = noun
+			}
+		}
+	}
+	return pairs
+}
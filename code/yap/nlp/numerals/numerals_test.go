@@ -0,0 +1,170 @@
+package numerals
+
+import (
+	"testing"
+
+	"yap/nlp/parser/dependency/transition"
+	"yap/nlp/parser/dependency/transition/morph"
+	nlp "yap/nlp/types"
+)
+
+func TestGender(t *testing.T) {
+	cases := map[string]string{
+		"gen=M|num=P":       MASCULINE,
+		"gen=F":             FEMININE,
+		"gen=F|gen=M|num=S": "",
+		"num=P":             "",
+		"_":                 "",
+	}
+	for feats, expected := range cases {
+		if gender := Gender(feats); gender != expected {
+			t.Errorf("Gender(%q): expected %q, got %q", feats, expected, gender)
+		}
+	}
+}
+
+func TestCardinal(t *testing.T) {
+	cases := []struct {
+		n         int64
+		gender    string
+		construct bool
+		expected  string
+	}{
+		{0, MASCULINE, false, "אפס"},
+		{1, MASCULINE, false, "אחד"},
+		{2, FEMININE, true, "שתי"},
+		{3, MASCULINE, true, "שלושת"},
+		{3, "", false, "שלוש"},
+		{11, FEMININE, false, "אחת עשרה"},
+		{12, MASCULINE, false, "שנים עשר"},
+		{15, MASCULINE, true, "חמישה עשר"},
+		{21, MASCULINE, false, "עשרים ואחד"},
+		{113, MASCULINE, false, "מאה ושלושה עשר"},
+		{1200, FEMININE, false, "אלף ומאתיים"},
+		{1234, MASCULINE, false, "אלף מאתיים שלושים וארבעה"},
+		{5000, FEMININE, false, "חמשת אלפים"},
+		{21000, FEMININE, false, "עשרים ואחד אלף"},
+		{2000005, FEMININE, false, "שני מיליון וחמש"},
+		{3000000000, MASCULINE, false, "שלושה מיליארד"},
+		{MAX_CARDINAL + 1, MASCULINE, false, ""},
+	}
+	for _, c := range cases {
+		if words := Cardinal(c.n, c.gender, c.construct); words != c.expected {
+			t.Errorf("Cardinal(%d, %q, %v): expected %q, got %q", c.n, c.gender, c.construct, c.expected, words)
+		}
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	if words := Ordinal(3, FEMININE); words != "שלישית" {
+		t.Errorf("Expected שלישית, got %q", words)
+	}
+	if words := Ordinal(11, FEMININE); words != "אחת עשרה" {
+		t.Errorf("Expected אחת עשרה, got %q", words)
+	}
+}
+
+func TestSpellOut(t *testing.T) {
+	cases := map[string]string{
+		"1,000.50": "אלף נקודה חמישים",
+		"3.5":      "שלוש נקודה חמש",
+		"0.05":     "אפס נקודה אפס חמש",
+		"-7":       "מינוס שבע",
+	}
+	for number, expected := range cases {
+		if words, ok := SpellOut(number, "", false); !ok || words != expected {
+			t.Errorf("SpellOut(%q): expected %q, got %q (%v)", number, expected, words, ok)
+		}
+	}
+	for _, number := range []string{"12:30", "1.", "abc", ""} {
+		if words, ok := SpellOut(number, "", false); ok {
+			t.Errorf("SpellOut(%q): expected failure, got %q", number, words)
+		}
+	}
+}
+
+// buildGraph builds a parsed sentence; heads are 0-based, -1 is the root
+func buildGraph(morphs []*nlp.EMorpheme, heads []int, rels []string) nlp.MorphDependencyGraph {
+	graph := &morph.BasicMorphGraph{}
+	for i, m := range morphs {
+		graph.Nodes = append(graph.Nodes, m)
+		graph.Arcs = append(graph.Arcs, &transition.BasicDepArc{Head: heads[i], Modifier: i, RawRelation: nlp.DepRel(rels[i])})
+	}
+	return graph
+}
+
+func TestSpellOutGraph(t *testing.T) {
+	morphs := []*nlp.EMorpheme{
+		{Morpheme: nlp.Morpheme{Form: "3", Lemma: "3", CPOS: "CD", POS: "CD", FeatureStr: "_", TokenID: 1}},
+		{Morpheme: nlp.Morpheme{Form: "ה", CPOS: "DEF", POS: "DEF", FeatureStr: "_", TokenID: 2}},
+		{Morpheme: nlp.Morpheme{Form: "ילדים", CPOS: "NN", POS: "NN", FeatureStr: "gen=M|num=P", TokenID: 2}},
+		{Morpheme: nlp.Morpheme{Form: "ו", CPOS: "CONJ", POS: "CONJ", FeatureStr: "_", TokenID: 3}},
+		{Morpheme: nlp.Morpheme{Form: "2", CPOS: "CD", POS: "CD", FeatureStr: "_", TokenID: 3}},
+		{Morpheme: nlp.Morpheme{Form: "ילדות", CPOS: "NN", POS: "NN", FeatureStr: "gen=F|num=P", TokenID: 4}},
+	}
+	graph := buildGraph(morphs, []int{2, 2, -1, 2, 5, 3}, []string{"num", "def", "ROOT", "conj", "num", "conj"})
+	if spelled := SpellOutGraph(graph); spelled != 2 {
+		t.Fatalf("Expected 2 numerals spelled out, got %d", spelled)
+	}
+	if morphs[0].Form != "שלושת" || morphs[0].Lemma != "שלושת" || morphs[0].FeatureStr != "gen=M" {
+		t.Errorf("Expected שלושת with gen=M, got %+v", morphs[0].Morpheme)
+	}
+	if morphs[4].Form != "שתי" || morphs[4].FeatureStr != "gen=F" {
+		t.Errorf("Expected שתי with gen=F, got %+v", morphs[4].Morpheme)
+	}
+}
+
+func TestValue(t *testing.T) {
+	cases := []struct {
+		words    []string
+		expected string
+	}{
+		{[]string{"שלושה", "עשר", "אלף", "ו", "מאתיים"}, "13200"},
+		{[]string{"אלף", "מאתיים", "שלושים", "וארבעה"}, "1234"},
+		{[]string{"חמשת", "אלפים"}, "5000"},
+		{[]string{"שני", "מיליון", "וחמש"}, "2000005"},
+		{[]string{"מאה", "אלף"}, "100000"},
+		{[]string{"שלוש", "מאות"}, "300"},
+		{[]string{"שלוש", "נקודה", "אפס", "חמש"}, "3.05"},
+		{[]string{"שלוש", "נקודה", "עשרים", "וחמש"}, "3.25"},
+		{[]string{"שלושה", "וחצי"}, "3.5"},
+		{[]string{"מינוס", "שבע"}, "-7"},
+		{[]string{"3", "מיליון"}, "3000000"},
+		{[]string{"1,000.50"}, "1000.5"},
+	}
+	for _, c := range cases {
+		if value, ok := Value(c.words); !ok || value != c.expected {
+			t.Errorf("Value(%q): expected %v, got %v (%v)", c.words, c.expected, value, ok)
+		}
+	}
+	for _, words := range [][]string{{"שלושה", "ארבעה"}, {"עשרים", "שלושים"}, {"אלף", "מיליון"}, {"ילד"}, {"נקודה"}} {
+		if value, ok := Value(words); ok {
+			t.Errorf("Value(%q): expected failure, got %v", words, value)
+		}
+	}
+}
+
+func TestAnnotateValues(t *testing.T) {
+	morphs := []*nlp.EMorpheme{
+		{Morpheme: nlp.Morpheme{Form: "שלושה", CPOS: "CD", FeatureStr: "gen=M", TokenID: 1}},
+		{Morpheme: nlp.Morpheme{Form: "עשר", CPOS: "CD", FeatureStr: "_", TokenID: 2}},
+		{Morpheme: nlp.Morpheme{Form: "אלף", CPOS: "CD", FeatureStr: "_", TokenID: 3}},
+		{Morpheme: nlp.Morpheme{Form: "ו", CPOS: "CONJ", FeatureStr: "_", TokenID: 4}},
+		{Morpheme: nlp.Morpheme{Form: "מאתיים", CPOS: "CD", FeatureStr: "_", TokenID: 4}},
+		{Morpheme: nlp.Morpheme{Form: "איש", CPOS: "NN", FeatureStr: "gen=M|num=S", TokenID: 5}},
+		{Morpheme: nlp.Morpheme{Form: "ו", CPOS: "CONJ", FeatureStr: "_", TokenID: 6}},
+		{Morpheme: nlp.Morpheme{Form: "12", CPOS: "CD", FeatureStr: "_", TokenID: 6}},
+	}
+	graph := buildGraph(morphs, []int{5, 0, 0, 5, 0, -1, 5, 5}, []string{"num", "dep", "dep", "conj", "dep", "ROOT", "conj", "conj"})
+	if valued := AnnotateValues(graph); valued != 2 {
+		t.Fatalf("Expected 2 numbers valued, got %d", valued)
+	}
+	for i, expected := range []string{"gen=M|NumValue=13200", "NumValue=13200", "NumValue=13200", "_", "NumValue=13200", "gen=M|num=S", "_", "NumValue=12"} {
+		if morphs[i].FeatureStr != expected {
+			t.Errorf("Morpheme %d (%v): expected features %v, got %v", i, morphs[i].Form, expected, morphs[i].FeatureStr)
+		}
+	}
+	if morphs[0].Features[NUM_VALUE_FEATURE] != "13200" {
+		t.Errorf("Expected feature map to hold the value, got %v", morphs[0].Features)
+	}
+}
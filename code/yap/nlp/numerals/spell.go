@@ -0,0 +1,224 @@
+package numerals
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	ZERO     = "אפס"
+	POINT    = "נקודה"
+	MINUS    = "מינוס"
+	AND      = "ו"
+	HUNDRED  = "מאה"
+	HUNDREDS = "מאות"
+	THOUSAND = "אלף"
+	MILLION  = "מיליון"
+	BILLION  = "מיליארד"
+
+	// MAX_CARDINAL is the largest number spelled out
+	MAX_CARDINAL int64 = 999999999999
+)
+
+var (
+	TENS = map[int64]string{
+		2: "עשרים", 3: "שלושים", 4: "ארבעים", 5: "חמישים",
+		6: "שישים", 7: "שבעים", 8: "שמונים", 9: "תשעים",
+	}
+
+	// two is spelled differently in 12 (שנים עשר, שתים עשרה)
+	TEEN_TWO = map[string]string{MASCULINE: "שנים", FEMININE: "שתים"}
+
+	TWO_HUNDRED    = "מאתיים"
+	TWO_THOUSAND   = "אלפיים"
+	THOUSANDS_WORD = "אלפים"
+
+	ORDINALS = map[int]map[string]string{
+		1:  {MASCULINE: "ראשון", FEMININE: "ראשונה"},
+		2:  {MASCULINE: "שני", FEMININE: "שנייה"},
+		3:  {MASCULINE: "שלישי", FEMININE: "שלישית"},
+		4:  {MASCULINE: "רביעי", FEMININE: "רביעית"},
+		5:  {MASCULINE: "חמישי", FEMININE: "חמישית"},
+		6:  {MASCULINE: "שישי", FEMININE: "שישית"},
+		7:  {MASCULINE: "שביעי", FEMININE: "שביעית"},
+		8:  {MASCULINE: "שמיני", FEMININE: "שמינית"},
+		9:  {MASCULINE: "תשיעי", FEMININE: "תשיעית"},
+		10: {MASCULINE: "עשירי", FEMININE: "עשירית"},
+	}
+)
+
+// agreeing returns gender, or the feminine (counting) gender if it is unknown
+func agreeing(gender string) string {
+	if gender == MASCULINE {
+		return MASCULINE
+	}
+	return FEMININE
+}
+
+// belowHundred spells out 1-99
+func belowHundred(n int64, gender string) []string {
+	switch {
+	case n <= 10:
+		return []string{Form(int(n), gender, false)}
+	case n == 12:
+		return []string{TEEN_TWO[gender] + " " + TeenForm(gender)}
+	case n < 20:
+		return []string{Form(int(n-10), gender, false) + " " + TeenForm(gender)}
+	}
+	words := []string{TENS[n/10]}
+	if n%10 > 0 {
+		words = append(words, Form(int(n%10), gender, false))
+	}
+	return words
+}
+
+// belowThousand spells out 1-999 as its components, the last of which
+// takes the conjunction
+func belowThousand(n int64, gender string) []string {
+	var words []string
+	switch hundreds := n / 100; {
+	case hundreds == 1:
+		words = append(words, HUNDRED)
+	case hundreds == 2:
+		words = append(words, TWO_HUNDRED)
+	case hundreds > 2:
+		words = append(words, Form(int(hundreds), FEMININE, false)+" "+HUNDREDS)
+	}
+	if n%100 > 0 {
+		words = append(words, belowHundred(n%100, gender)...)
+	}
+	return words
+}
+
+// scale spells out n (1-999) times a masculine scale word (מיליון, מיליארד)
+func scale(n int64, word string) string {
+	switch n {
+	case 1:
+		return word
+	case 2:
+		return Form(2, MASCULINE, true) + " " + word
+	}
+	return join(belowThousand(n, MASCULINE)) + " " + word
+}
+
+func thousands(n int64) string {
+	switch {
+	case n == 1:
+		return THOUSAND
+	case n == 2:
+		return TWO_THOUSAND
+	case n <= 10:
+		return Form(int(n), MASCULINE, true) + " " + THOUSANDS_WORD
+	}
+	return join(belowThousand(n, MASCULINE)) + " " + THOUSAND
+}
+
+// join joins the components of a number, prefixing the last with the
+// conjunction (מאה עשרים ושלושה, אלף ומאתיים)
+func join(words []string) string {
+	if len(words) > 1 {
+		words[len(words)-1] = AND + words[len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
+
+// Cardinal spells out 0 <= n <= MAX_CARDINAL agreeing with gender; an
+// unknown gender takes the feminine counting form. Construct state only
+// applies to 2-10 (שני ילדים, שלושת הילדים). Returns empty if n is out of range.
+func Cardinal(n int64, gender string, construct bool) string {
+	if n < 0 || n > MAX_CARDINAL {
+		return ""
+	}
+	gender = agreeing(gender)
+	if n == 0 {
+		return ZERO
+	}
+	if n >= 2 && n <= 10 && construct {
+		return Form(int(n), gender, true)
+	}
+	var words []string
+	if billions := n / 1000000000; billions > 0 {
+		words = append(words, scale(billions, BILLION))
+	}
+	if millions := n / 1000000 % 1000; millions > 0 {
+		words = append(words, scale(millions, MILLION))
+	}
+	if n/1000%1000 > 0 {
+		words = append(words, thousands(n/1000%1000))
+	}
+	if n%1000 > 0 {
+		words = append(words, belowThousand(n%1000, gender)...)
+	}
+	return join(words)
+}
+
+// Ordinal spells out the ordinal n >= 1 agreeing with gender; above ten
+// Hebrew uses the cardinal (היום העשרים, הפעם האחת עשרה)
+func Ordinal(n int, gender string) string {
+	if forms, exists := ORDINALS[n]; exists {
+		return forms[agreeing(gender)]
+	}
+	if n < 1 {
+		return ""
+	}
+	return Cardinal(int64(n), gender, false)
+}
+
+// SpellOut spells out a number written in digits, with optional thousands
+// separators, sign and decimal fraction (e.g. "-1,000.50"); the integer
+// part agrees with gender, the fraction is read as a feminine cardinal, or
+// digit by digit if it has leading zeros. Returns false if number is not a
+// number or is out of range.
+func SpellOut(number string, gender string, construct bool) (string, bool) {
+	var words []string
+	if strings.HasPrefix(number, "-") {
+		words = append(words, MINUS)
+		number = number[1:]
+	}
+	integer, fraction := number, ""
+	if point := strings.Index(number, "."); point >= 0 {
+		integer, fraction = number[:point], number[point+1:]
+		if len(fraction) == 0 || strings.ContainsAny(fraction, ".,") {
+			return "", false
+		}
+	}
+	integer = strings.Replace(integer, ",", "", -1)
+	value, ok := parseDigits(integer)
+	if !ok {
+		return "", false
+	}
+	words = append(words, Cardinal(value, gender, construct && len(fraction) == 0 && len(words) == 0))
+	if len(fraction) > 0 {
+		words = append(words, POINT)
+		if fraction[0] == '0' {
+			for _, digit := range fraction {
+				d, _ := parseDigits(string(digit))
+				words = append(words, Cardinal(d, FEMININE, false))
+			}
+		} else {
+			value, ok := parseDigits(fraction)
+			if !ok {
+				return "", false
+			}
+			words = append(words, Cardinal(value, FEMININE, false))
+		}
+	}
+	for _, word := range words {
+		if len(word) == 0 {
+			return "", false
+		}
+	}
+	return strings.Join(words, " "), true
+}
+
+// parseDigits parses a non-empty string of ASCII digits
+func parseDigits(digits string) (int64, bool) {
+	if len(digits) == 0 || strings.TrimLeft(digits, "0123456789") != "" {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || value > MAX_CARDINAL {
+		return 0, false
+	}
+	return value, true
+}
@@ -0,0 +1,244 @@
+package numerals
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	HALF = "חצי"
+
+	NUM_VALUE_FEATURE = "NumValue"
+)
+
+var (
+	// SCALES are the words multiplying the number before them
+	SCALES = map[string]int64{
+		THOUSAND:       1000,
+		THOUSANDS_WORD: 1000,
+		MILLION:        1000000,
+		BILLION:        1000000000,
+	}
+
+	// NUMBER_WORDS are the words of a numeral compound other than the cardinals
+	NUMBER_WORDS = map[string]bool{
+		ZERO: true, POINT: true, MINUS: true, HALF: true, HUNDRED: true, HUNDREDS: true,
+		TWO_HUNDRED: true, TWO_THOUSAND: true, THOUSAND: true, THOUSANDS_WORD: true,
+		MILLION: true, BILLION: true,
+	}
+
+	tensValues = make(map[string]int64)
+)
+
+func init() {
+	for value, form := range TENS {
+		tensValues[form] = value * 10
+		NUMBER_WORDS[form] = true
+	}
+}
+
+// IsNumberWord is true for the words that may be part of a numeral
+// compound: digits, cardinals, tens and scale words
+func IsNumberWord(word string) bool {
+	if _, _, _, found := Lookup(word); found || NUMBER_WORDS[word] {
+		return true
+	}
+	_, ok := parseNumber(word)
+	return ok
+}
+
+// compound accumulates the value of a numeral compound; every added part
+// must be smaller than the one before it, except for the second part of
+// 11-19 (שלושה עשר)
+type compound struct {
+	total, current, last int64
+	words                int
+	zero                 bool
+}
+
+func (c *compound) add(value int64, teen bool) bool {
+	if c.current > 0 && value >= c.last && !(teen && c.last < 10) {
+		return false
+	}
+	c.current += value
+	c.last = value
+	return true
+}
+
+// multiply applies a scale word; a scale word with nothing before it is one
+func (c *compound) multiply(scale int64) bool {
+	if c.current == 0 {
+		c.current = 1
+	}
+	if c.total > 0 && c.total%(scale*1000) != 0 {
+		return false
+	}
+	c.total += c.current * scale
+	c.current, c.last = 0, scale
+	return true
+}
+
+func (c *compound) value() int64 {
+	return c.total + c.current
+}
+
+// word adds a single word of the integer part of a compound
+func (c *compound) word(word string) bool {
+	defer func() { c.words++ }()
+	if c.zero {
+		return false
+	}
+	if value, _, _, found := Lookup(word); found {
+		return c.add(int64(value), IsTeen(word))
+	}
+	if value, exists := tensValues[word]; exists {
+		return c.add(value, false)
+	}
+	if scale, exists := SCALES[word]; exists {
+		if word == THOUSANDS_WORD && (c.current < 3 || c.current > 10) {
+			return false
+		}
+		return c.multiply(scale)
+	}
+	switch word {
+	case ZERO:
+		c.zero = true
+		return c.words == 0
+	case HUNDRED:
+		return c.add(100, false)
+	case TWO_HUNDRED:
+		return c.add(200, false)
+	case HUNDREDS:
+		if c.current < 3 || c.current > 9 || c.current != c.last {
+			return false
+		}
+		c.current *= 100
+		c.last = c.current
+		return true
+	case TWO_THOUSAND:
+		if c.current > 0 {
+			return false
+		}
+		c.current = 2
+		return c.multiply(1000)
+	}
+	if value, ok := parseDigits(strings.Replace(word, ",", "", -1)); ok {
+		return c.add(value, false)
+	}
+	return false
+}
+
+// Value returns the value of the words of a numeral compound
+// ("שלושה עשר אלף ומאתיים" is 13200, "שלוש נקודה אפס חמש" is 3.05,
+// "2.5" is 2.5); the conjunction may be attached to a word or given as a
+// separate word. Returns false if the words are not a single number.
+func Value(words []string) (string, bool) {
+	var parts []string
+	for _, word := range words {
+		if word == AND {
+			continue
+		}
+		if !IsNumberWord(word) && strings.HasPrefix(word, AND) && IsNumberWord(strings.TrimPrefix(word, AND)) {
+			word = strings.TrimPrefix(word, AND)
+		}
+		parts = append(parts, word)
+	}
+	if len(parts) == 0 {
+		return "", false
+	}
+	var sign string
+	if parts[0] == MINUS {
+		sign, parts = "-", parts[1:]
+	}
+	if len(parts) == 1 {
+		if value, ok := parseNumber(parts[0]); ok {
+			return sign + value, true
+		}
+	}
+	integer, fraction := parts, []string(nil)
+	for i, part := range parts {
+		if part == POINT {
+			integer, fraction = parts[:i], parts[i+1:]
+			if len(fraction) == 0 {
+				return "", false
+			}
+			break
+		}
+		if part == HALF && i == len(parts)-1 && i > 0 {
+			integer, fraction = parts[:i], []string{"5"}
+		}
+	}
+	c := &compound{}
+	for _, part := range integer {
+		if !c.word(part) {
+			return "", false
+		}
+	}
+	if c.words == 0 {
+		return "", false
+	}
+	digits, ok := fractionDigits(fraction)
+	if !ok {
+		return "", false
+	}
+	return sign + formatNumber(strconv.FormatInt(c.value(), 10), digits), true
+}
+
+// fractionDigits returns the digits of the fraction of a decimal, read
+// either as a number or digit by digit (אפס חמש)
+func fractionDigits(words []string) (string, bool) {
+	if len(words) == 0 {
+		return "", true
+	}
+	if len(words) == 1 && strings.TrimLeft(words[0], "0123456789") == "" {
+		return words[0], true
+	}
+	if words[0] == ZERO {
+		var digits []string
+		for _, word := range words {
+			value, _, _, found := Lookup(word)
+			switch {
+			case word == ZERO:
+				digits = append(digits, "0")
+			case found && value < 10:
+				digits = append(digits, strconv.Itoa(value))
+			default:
+				return "", false
+			}
+		}
+		return strings.Join(digits, ""), true
+	}
+	c := &compound{}
+	for _, word := range words {
+		if !c.word(word) {
+			return "", false
+		}
+	}
+	return strconv.FormatInt(c.value(), 10), true
+}
+
+// parseNumber parses a number written in digits with optional thousands
+// separators and decimal fraction, returning its normalized value
+func parseNumber(number string) (string, bool) {
+	integer, fraction := number, ""
+	if point := strings.Index(number, "."); point >= 0 {
+		integer, fraction = number[:point], number[point+1:]
+		if len(fraction) == 0 || strings.TrimLeft(fraction, "0123456789") != "" {
+			return "", false
+		}
+	}
+	value, ok := parseDigits(strings.Replace(integer, ",", "", -1))
+	if !ok {
+		return "", false
+	}
+	return formatNumber(strconv.FormatInt(value, 10), fraction), true
+}
+
+// formatNumber joins an integer and fraction, dropping trailing zeros
+func formatNumber(integer, fraction string) string {
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) == 0 {
+		return integer
+	}
+	return integer + "." + fraction
+}
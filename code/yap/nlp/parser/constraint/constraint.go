@@ -0,0 +1,264 @@
+package constraint
+
+// Package constraint restricts the parsers to analyses consistent with a
+// partial annotation of the sentences: the segmentation or part of speech
+// tags of some tokens, and arcs that must or must not be built
+
+import (
+	"yap/alg"
+	"yap/alg/transition"
+	dep "yap/nlp/parser/dependency/transition"
+	"yap/nlp/parser/disambig"
+	"yap/nlp/parser/joint"
+	nlp "yap/nlp/types"
+
+	"strings"
+)
+
+// SEPARATOR separates the morphemes of a token's segmentation and tags
+const SEPARATOR = ":"
+
+// Token fixes the analysis of a token, by its number in the lattices:
+// Seg the forms of its morphemes and POS their part of speech tags,
+// SEPARATOR separated; either may be empty
+type Token struct {
+	ID  int    `json:"token"`
+	Seg string `json:"seg,omitempty"`
+	POS string `json:"pos,omitempty"`
+}
+
+// Arc is an arc between morphemes, by their ids in the parser's CoNLL
+// output; Head 0 is the root and an empty Rel matches any label
+type Arc struct {
+	Dependent int    `json:"dependent"`
+	Head      int    `json:"head"`
+	Rel       string `json:"rel,omitempty"`
+}
+
+func (a Arc) matches(head int, rel string) bool {
+	return a.Head == head && (len(a.Rel) == 0 || a.Rel == rel)
+}
+
+// Sentence holds the constraints of a sentence: tokens with a fixed
+// analysis, arcs that must be built and arcs that must not be
+type Sentence struct {
+	Tokens    []Token `json:"tokens,omitempty"`
+	Arcs      []Arc   `json:"arcs,omitempty"`
+	Forbidden []Arc   `json:"forbidden_arcs,omitempty"`
+}
+
+func (s *Sentence) Empty() bool {
+	return s == nil || len(s.Tokens)+len(s.Arcs)+len(s.Forbidden) == 0
+}
+
+// Consistent tells whether a (partial) parse agrees with the constraints:
+// the morphemes chosen so far for each constrained token match its
+// analysis, and no arc built contradicts a fixed or forbidden arc
+func (s *Sentence) Consistent(conf transition.Configuration) bool {
+	return s.consistent(conf, false)
+}
+
+// consistent is Consistent, given whether nodes on the stack take their
+// head only from the queue (as in the arc eager system)
+func (s *Sentence) consistent(conf transition.Configuration, headsFromQueue bool) bool {
+	switch c := conf.(type) {
+	case *joint.JointConfig:
+		return s.consistentMappings(c.MDConfig.Mappings, completeMappings(&c.MDConfig)) && s.consistentArcs(c, headsFromQueue)
+	case *disambig.MDConfig:
+		return s.consistentMappings(c.Mappings, completeMappings(c))
+	case nlp.Labeled:
+		return s.consistentArcs(c, headsFromQueue)
+	}
+	return true
+}
+
+// completeMappings returns the number of mappings of a disambiguation whose
+// token is fully disambiguated; the last mapping is in progress while
+// lattices remain in the queue
+func completeMappings(c *disambig.MDConfig) int {
+	if c.LatticeQueue == nil || c.LatticeQueue.Size() == 0 || len(c.Mappings) == 0 {
+		return len(c.Mappings)
+	}
+	return len(c.Mappings) - 1
+}
+
+func (s *Sentence) consistentMappings(mappings nlp.Mappings, complete int) bool {
+	for _, token := range s.Tokens {
+		i := token.ID - 1
+		if i < 0 || i >= len(mappings) {
+			continue
+		}
+		spellout := mappings[i].Spellout
+		if !matchSpellout(spellout, token.Seg, i < complete, func(m *nlp.EMorpheme, form string) bool {
+			return m.Form == form
+		}) {
+			return false
+		}
+		if !matchSpellout(spellout, token.POS, i < complete, func(m *nlp.EMorpheme, pos string) bool {
+			return m.POS == pos || m.CPOS == pos
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchSpellout tells whether the morphemes of a spellout match the
+// SEPARATOR separated values, or their prefix if it isn't complete
+func matchSpellout(spellout nlp.Spellout, values string, complete bool, match func(*nlp.EMorpheme, string) bool) bool {
+	if len(values) == 0 {
+		return true
+	}
+	expected := strings.Split(values, SEPARATOR)
+	if len(spellout) > len(expected) || (complete && len(spellout) != len(expected)) {
+		return false
+	}
+	for i, morph := range spellout {
+		if morph == nil || !match(morph, expected[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// nodeAgenda is implemented by configurations keeping the nodes that may
+// still be attached on a stack and a queue
+type nodeAgenda interface {
+	Stack() alg.Stack
+	Queue() alg.Queue
+	NumberOfNodes() int
+}
+
+// done tells whether a node was already processed, i.e. it exists but is
+// neither on the stack nor on the queue, so no arc to or from it can be built
+func done(agenda nodeAgenda, node int) bool {
+	return exists(agenda, node) && !onStack(agenda, node) && !onQueue(agenda, node)
+}
+
+func exists(agenda nodeAgenda, node int) bool {
+	return node >= 0 && node < agenda.NumberOfNodes()
+}
+
+func onStack(agenda nodeAgenda, node int) bool {
+	return contains(agenda.Stack(), agenda.Stack().Size(), node)
+}
+
+func onQueue(agenda nodeAgenda, node int) bool {
+	return contains(agenda.Queue(), agenda.Queue().Size(), node)
+}
+
+func contains(index alg.Index, size, node int) bool {
+	for i := 0; i < size; i++ {
+		if atI, exists := index.Index(i); exists && atI == node {
+			return true
+		}
+	}
+	return false
+}
+
+// consistentArcs tells whether the arcs built agree with the fixed and
+// forbidden arcs. For configurations with a stack and a queue, a fixed arc
+// can no longer be built once its dependent or head was processed without
+// it, or, if heads of nodes on the stack come only from the queue, once its
+// dependent is on the stack and its head isn't on the queue.
+func (s *Sentence) consistentArcs(graph nlp.Labeled, headsFromQueue bool) bool {
+	agenda, hasAgenda := graph.(nodeAgenda)
+	for _, arc := range s.Arcs {
+		head, rel, attached := headOf(graph, arc.Dependent)
+		if attached && !arc.matches(head, rel) {
+			return false
+		}
+		if attached || !hasAgenda || arc.Head < 1 {
+			continue
+		}
+		dependent, arcHead := arc.Dependent-1, arc.Head-1
+		if done(agenda, dependent) || done(agenda, arcHead) {
+			return false
+		}
+		if headsFromQueue && onStack(agenda, dependent) && exists(agenda, arcHead) && !onQueue(agenda, arcHead) {
+			return false
+		}
+	}
+	for _, arc := range s.Forbidden {
+		if head, rel, attached := headOf(graph, arc.Dependent); attached && arc.matches(head, rel) {
+			return false
+		}
+	}
+	return true
+}
+
+// headOf returns the head (0 for the root) and label of a morpheme of a
+// parse, if it is attached
+func headOf(graph nlp.Labeled, dependent int) (int, string, bool) {
+	if dependent < 1 {
+		return 0, "", false
+	}
+	arc := graph.GetLabeledArc(dependent - 1)
+	if arc == nil || arc.GetModifier() != dependent-1 {
+		return 0, "", false
+	}
+	rel := string(arc.GetRelation())
+	if rel == nlp.ROOT_LABEL || arc.GetHead() < 0 {
+		return 0, rel, true
+	}
+	return arc.GetHead() + 1, rel, true
+}
+
+// Transitions restricts a transition system to the transitions leading to
+// configurations consistent with the constraints of the parsed sentence.
+// When none is consistent, e.g. when a fixed segmentation is missing from
+// the lattice, all of them are kept so the parse can complete.
+type Transitions struct {
+	transition.TransitionSystem
+	Constraints *Sentence
+
+	headsFromQueue bool
+}
+
+var _ transition.TransitionSystem = &Transitions{}
+
+// Constrain returns the transition system restricted by the constraints,
+// or the system itself if there are none
+func Constrain(system transition.TransitionSystem, constraints *Sentence) transition.TransitionSystem {
+	if constraints.Empty() {
+		return system
+	}
+	return &Transitions{TransitionSystem: system, Constraints: constraints, headsFromQueue: headsFromQueue(system)}
+}
+
+// headsFromQueue tells whether nodes on the stack of a transition system
+// take their head only from the queue
+func headsFromQueue(system transition.TransitionSystem) bool {
+	switch s := system.(type) {
+	case *dep.ArcEager:
+		return true
+	case *joint.JointTrans:
+		return headsFromQueue(s.ArcSys)
+	}
+	return false
+}
+
+func (t *Transitions) GetTransitions(conf transition.Configuration) (byte, []int) {
+	transType, transitions := t.TransitionSystem.GetTransitions(conf)
+	consistent := make([]int, 0, len(transitions))
+	for _, trans := range transitions {
+		next := t.TransitionSystem.Transition(conf, &transition.TypedTransition{T: transType, V: trans})
+		if t.Constraints.consistent(next, t.headsFromQueue) {
+			consistent = append(consistent, trans)
+		}
+	}
+	if len(consistent) == 0 {
+		return transType, transitions
+	}
+	return transType, consistent
+}
+
+func (t *Transitions) YieldTransitions(conf transition.Configuration) (byte, chan int) {
+	transType, consistent := t.GetTransitions(conf)
+	transitions := make(chan int, len(consistent))
+	for _, trans := range consistent {
+		transitions <- trans
+	}
+	close(transitions)
+	return transType, transitions
+}
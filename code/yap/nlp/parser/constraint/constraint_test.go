@@ -0,0 +1,163 @@
+package constraint
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"yap/alg"
+	"yap/nlp/parser/dependency/transition"
+	nlp "yap/nlp/types"
+)
+
+func TestRead(t *testing.T) {
+	input := "# first sentence\nseg\t3\tב:ה:בית\npos\t3\tPREPOSITION:DEF:NN\narc\t4\t2\tnum\nnoarc\t4\t5\n\n\npos\t1\tCD\n"
+	sentences, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	expected := []*Sentence{
+		{
+			Tokens:    []Token{{ID: 3, Seg: "ב:ה:בית", POS: "PREPOSITION:DEF:NN"}},
+			Arcs:      []Arc{{Dependent: 4, Head: 2, Rel: "num"}},
+			Forbidden: []Arc{{Dependent: 4, Head: 5}},
+		},
+		{},
+		{Tokens: []Token{{ID: 1, POS: "CD"}}},
+	}
+	if !reflect.DeepEqual(sentences, expected) {
+		t.Errorf("Got %+v, expected %+v", sentences, expected)
+	}
+	if !sentences[1].Empty() || sentences[2].Empty() {
+		t.Errorf("Wrong emptiness of %+v and %+v", sentences[1], sentences[2])
+	}
+	for _, bad := range []string{"seg\t0\tב\n", "arc\t2\tx\n", "fix\t1\t2\n", "pos\t1\n"} {
+		if _, err := Read(strings.NewReader(bad)); err == nil {
+			t.Errorf("Read of %q didn't fail", bad)
+		}
+	}
+}
+
+func testMappings(tokens ...[][2]string) nlp.Mappings {
+	mappings := make(nlp.Mappings, len(tokens))
+	for i, morphs := range tokens {
+		mappings[i] = &nlp.Mapping{Token: nlp.Token("t")}
+		for _, morph := range morphs {
+			mappings[i].Spellout = append(mappings[i].Spellout, &nlp.EMorpheme{Morpheme: nlp.Morpheme{Form: morph[0], CPOS: morph[1], POS: morph[1]}})
+		}
+	}
+	return mappings
+}
+
+func TestConsistentMappings(t *testing.T) {
+	s := &Sentence{Tokens: []Token{{ID: 2, Seg: "ב:ה:בית", POS: "PREPOSITION:DEF:NN"}, {ID: 3, POS: "CD"}}}
+	for _, test := range []struct {
+		mappings   nlp.Mappings
+		complete   int
+		consistent bool
+	}{
+		{testMappings([][2]string{{"הלכתי", "VB"}}), 0, true},
+		{testMappings([][2]string{{"הלכתי", "VB"}}, [][2]string{{"ב", "PREPOSITION"}}), 1, true},
+		{testMappings([][2]string{{"הלכתי", "VB"}}, [][2]string{{"ב", "PREPOSITION"}}), 2, false},
+		{testMappings([][2]string{{"הלכתי", "VB"}}, [][2]string{{"בבית", "NN"}}), 1, false},
+		{testMappings([][2]string{{"הלכתי", "VB"}}, [][2]string{{"ב", "PREPOSITION"}, {"ה", "DEF"}, {"בית", "NN"}}), 2, true},
+		{testMappings([][2]string{{"הלכתי", "VB"}}, [][2]string{{"ב", "PREPOSITION"}, {"ה", "DEF"}, {"בית", "NNT"}}), 2, false},
+		{testMappings([][2]string{{"הלכתי", "VB"}}, [][2]string{{"ב", "PREPOSITION"}, {"ה", "DEF"}, {"בית", "NN"}}, [][2]string{{"3", "NN"}}), 3, false},
+	} {
+		if got := s.consistentMappings(test.mappings, test.complete); got != test.consistent {
+			t.Errorf("Got consistency %v of %v (%d complete), expected %v", got, test.mappings, test.complete, test.consistent)
+		}
+	}
+}
+
+// testGraph maps a modifier node to its arc
+type testGraph map[int]*transition.BasicDepArc
+
+func (g testGraph) GetLabeledArc(node int) nlp.LabeledDepArc {
+	if arc, exists := g[node]; exists {
+		return arc
+	}
+	return nil
+}
+
+// arcs builds a graph of arcs given as 1-based dependent, head and label
+func arcs(arcs ...[3]interface{}) testGraph {
+	graph := make(testGraph)
+	for _, arc := range arcs {
+		dep, head, rel := arc[0].(int)-1, arc[1].(int)-1, arc[2].(string)
+		if rel == nlp.ROOT_LABEL {
+			head = 0
+		}
+		graph[dep] = &transition.BasicDepArc{Head: head, Modifier: dep, RawRelation: nlp.DepRel(rel)}
+	}
+	return graph
+}
+
+func TestConsistentArcs(t *testing.T) {
+	s := &Sentence{
+		Arcs:      []Arc{{Dependent: 3, Head: 4, Rel: "num"}, {Dependent: 1, Head: 0}},
+		Forbidden: []Arc{{Dependent: 2, Head: 1}},
+	}
+	for _, test := range []struct {
+		graph      testGraph
+		consistent bool
+	}{
+		{arcs(), true},
+		{arcs([3]interface{}{3, 4, "num"}, [3]interface{}{2, 4, "subj"}), true},
+		{arcs([3]interface{}{3, 4, "obj"}), false},
+		{arcs([3]interface{}{3, 1, "num"}), false},
+		{arcs([3]interface{}{1, 0, nlp.ROOT_LABEL}), true},
+		{arcs([3]interface{}{1, 2, "subj"}), false},
+		{arcs([3]interface{}{2, 1, "obj"}), false},
+	} {
+		if got := s.consistentArcs(test.graph, false); got != test.consistent {
+			t.Errorf("Got consistency %v of %v, expected %v", got, test.graph, test.consistent)
+		}
+	}
+}
+
+// agendaGraph is a testGraph of n nodes with a stack and a queue
+type agendaGraph struct {
+	testGraph
+	stack alg.Stack
+	queue alg.Queue
+	n     int
+}
+
+func (g *agendaGraph) Stack() alg.Stack   { return g.stack }
+func (g *agendaGraph) Queue() alg.Queue   { return g.queue }
+func (g *agendaGraph) NumberOfNodes() int { return g.n }
+
+// withAgenda adds a stack and a queue of 1-based node ids to a graph of n nodes
+func withAgenda(graph testGraph, n int, stack, queue []int) *agendaGraph {
+	g := &agendaGraph{graph, alg.NewStackArray(n), alg.NewQueueSlice(n), n}
+	for _, node := range stack {
+		g.stack.Push(node - 1)
+	}
+	for _, node := range queue {
+		g.queue.Enqueue(node - 1)
+	}
+	return g
+}
+
+func TestConsistentArcsProcessed(t *testing.T) {
+	s := &Sentence{Arcs: []Arc{{Dependent: 3, Head: 1}}}
+	for _, test := range []struct {
+		graph          *agendaGraph
+		headsFromQueue bool
+		consistent     bool
+	}{
+		{withAgenda(arcs(), 3, nil, []int{1, 2, 3}), false, true},
+		{withAgenda(arcs([3]interface{}{2, 1, "obj"}), 3, []int{1}, []int{3}), false, true},
+		{withAgenda(arcs([3]interface{}{1, 2, "subj"}), 3, []int{2}, []int{3}), false, false},
+		{withAgenda(arcs([3]interface{}{2, 1, "obj"}), 3, []int{1, 2}, nil), false, false},
+		{withAgenda(arcs([3]interface{}{3, 1, "obj"}), 3, []int{1}, nil), false, true},
+		{withAgenda(arcs(), 3, []int{1, 3}, nil), false, true},
+		{withAgenda(arcs(), 3, []int{1, 3}, nil), true, false},
+		{withAgenda(arcs(), 3, []int{3}, []int{1}), true, true},
+	} {
+		if got := s.consistentArcs(test.graph, test.headsFromQueue); got != test.consistent {
+			t.Errorf("Got consistency %v of %v, expected %v", got, test.graph.testGraph, test.consistent)
+		}
+	}
+}
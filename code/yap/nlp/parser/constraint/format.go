@@ -0,0 +1,113 @@
+package constraint
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Read reads the constraints of a corpus, one block of lines per sentence
+// in input order, each block ending with an empty line (a sentence without
+// constraints is just an empty line). Lines are tab separated:
+//
+//	seg	<token>	<forms>
+//	pos	<token>	<tags>
+//	arc	<dependent>	<head>	[<label>]
+//	noarc	<dependent>	<head>	[<label>]
+//
+// Tokens are numbered as in the lattices and morphemes by their ids in the
+// CoNLL output; forms and tags are SEPARATOR separated and head 0 is the
+// root. Lines starting with '#' are comments.
+func Read(reader io.Reader) ([]*Sentence, error) {
+	var (
+		sentences []*Sentence
+		current   = &Sentence{}
+		hasLines  bool
+		lineNum   int
+	)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) == 0 {
+			sentences = append(sentences, current)
+			current, hasLines = &Sentence{}, false
+			continue
+		}
+		if line[0] == '#' {
+			continue
+		}
+		if err := current.parseLine(line); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		hasLines = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if hasLines {
+		sentences = append(sentences, current)
+	}
+	return sentences, nil
+}
+
+func (s *Sentence) parseLine(line string) error {
+	fields := strings.Split(line, "\t")
+	if len(fields) < 3 {
+		return fmt.Errorf("expected at least 3 fields, got %d", len(fields))
+	}
+	id, err := strconv.Atoi(fields[1])
+	if err != nil || id < 1 {
+		return fmt.Errorf("bad token or morpheme id %q", fields[1])
+	}
+	switch fields[0] {
+	case "seg", "pos":
+		token := s.token(id)
+		if fields[0] == "seg" {
+			token.Seg = fields[2]
+		} else {
+			token.POS = fields[2]
+		}
+	case "arc", "noarc":
+		head, err := strconv.Atoi(fields[2])
+		if err != nil || head < 0 {
+			return fmt.Errorf("bad head %q", fields[2])
+		}
+		arc := Arc{Dependent: id, Head: head}
+		if len(fields) > 3 {
+			arc.Rel = fields[3]
+		}
+		if fields[0] == "arc" {
+			s.Arcs = append(s.Arcs, arc)
+		} else {
+			s.Forbidden = append(s.Forbidden, arc)
+		}
+	default:
+		return fmt.Errorf("unknown constraint %q", fields[0])
+	}
+	return nil
+}
+
+// token returns the constraint of a token, adding it if it doesn't exist
+func (s *Sentence) token(id int) *Token {
+	for i := range s.Tokens {
+		if s.Tokens[i].ID == id {
+			return &s.Tokens[i]
+		}
+	}
+	s.Tokens = append(s.Tokens, Token{ID: id})
+	return &s.Tokens[len(s.Tokens)-1]
+}
+
+// ReadFile reads the constraints file of a corpus, see Read
+func ReadFile(filename string) ([]*Sentence, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
@@ -0,0 +1,252 @@
+package transition
+
+import (
+	"fmt"
+	. "yap/alg/transition"
+	. "yap/nlp/types"
+)
+
+// node positions in a configuration, as seen by the dynamic oracle
+const (
+	inQueue   byte = iota // on the queue, or not yet added (joint parsing)
+	inStack               // on the stack
+	processed             // popped from the stack
+)
+
+// noGoldHead marks gold nodes without an arc
+const noGoldHead = -2
+
+// ArcEagerDynamicOracle is the dynamic oracle of the arc eager system
+// (goldberg & nivre coling '12): the cost of a transition is the number of
+// gold arcs reachable before it and no longer reachable after it, so it can
+// tell the best transitions of any configuration and not just of those on
+// the gold path. As the system doesn't shift after a reduce, a reduce also
+// costs the arc the front of the queue must then lose; the arcs lost to
+// attach the headless nodes left on the stack aren't counted. Transition
+// returns the cheapest transition, reducing before attaching to the right
+// and shifting on ties.
+type ArcEagerDynamicOracle struct {
+	ArcStandardOracle
+	System *ArcEager
+
+	heads []int
+	rels  []DepRel
+}
+
+var _ DynamicOracle = &ArcEagerDynamicOracle{}
+
+func (a *ArcEager) AddDynamicOracle() {
+	a.oracle = Oracle(&ArcEagerDynamicOracle{
+		ArcStandardOracle: ArcStandardOracle{Transitions: a.Transitions, LA: int(a.LEFT), RA: int(a.RIGHT)},
+		System:            a,
+	})
+}
+
+func (o *ArcEagerDynamicOracle) SetGold(g interface{}) {
+	o.ArcStandardOracle.SetGold(g)
+	numNodes := o.gold.NumberOfNodes()
+	o.heads, o.rels = make([]int, numNodes), make([]DepRel, numNodes)
+	for i := range o.heads {
+		o.heads[i] = noGoldHead
+	}
+	for _, arcID := range o.gold.GetEdges() {
+		arc := o.gold.GetLabeledArc(arcID)
+		if arc == nil || arc.GetModifier() < 0 || arc.GetModifier() >= numNodes {
+			continue
+		}
+		o.heads[arc.GetModifier()], o.rels[arc.GetModifier()] = arc.GetHead(), arc.GetRelation()
+	}
+}
+
+func (o *ArcEagerDynamicOracle) Transition(conf Configuration) Transition {
+	if o.gold == nil {
+		panic("Oracle needs gold reference, use SetGold")
+	}
+	transType, transitions, costs := o.Costs(conf)
+	best := -1
+	for i, transition := range transitions {
+		if best < 0 || costs[i] < costs[best] ||
+			(costs[i] == costs[best] && o.preference(transition) < o.preference(transitions[best])) {
+			best = i
+		}
+	}
+	if best < 0 {
+		panic(fmt.Sprintf("Oracle cannot take any action at %v", conf))
+	}
+	return &TypedTransition{transType, transitions[best]}
+}
+
+// preference orders the transitions of equal cost
+func (o *ArcEagerDynamicOracle) preference(transition int) int {
+	switch {
+	case transition == o.System.POPROOT:
+		return 0
+	case transition >= o.System.LEFT && transition < o.System.RIGHT:
+		return 1
+	case transition == o.System.REDUCE:
+		return 2
+	case transition >= o.System.RIGHT:
+		return 3
+	}
+	return 4
+}
+
+func (o *ArcEagerDynamicOracle) Costs(conf Configuration) (byte, []int, []int) {
+	c, ok := conf.(*SimpleConfiguration)
+	if !ok {
+		panic("Got wrong configuration type")
+	}
+	if o.gold == nil {
+		panic("Oracle needs gold reference, use SetGold")
+	}
+	transType, transitions := o.System.GetTransitions(c)
+	reachable := o.reachable(c)
+	costs := make([]int, len(transitions))
+	// all arcs of a kind (left or right) cost the same, but for the one
+	// with the gold label; wrongCost is the cost of the others by kind
+	wrongCost := make(map[int]int, 4)
+	for i, transition := range transitions {
+		kind, label, goldLabel := o.attachment(c, transition)
+		cost, computed := wrongCost[kind]
+		if !computed {
+			next := o.System.Transition(c, &TypedTransition{transType, transition}).(*SimpleConfiguration)
+			cost = reachable - o.reachable(next)
+			if transition == o.System.REDUCE {
+				cost += o.stuck(next)
+			}
+			if label == goldLabel {
+				cost++
+			}
+			wrongCost[kind] = cost
+		}
+		if label == goldLabel {
+			cost--
+		}
+		costs[i] = cost
+	}
+	return transType, transitions, costs
+}
+
+// attachment returns the kind of a transition (LEFT, RIGHT or the
+// transition itself), the label it attaches and the gold label of the arc it
+// attaches, -1 if it isn't a gold arc; transitions not attaching have label
+// -2
+func (o *ArcEagerDynamicOracle) attachment(c *SimpleConfiguration, transition int) (int, int, int) {
+	sTop, sExists := c.Stack().Peek()
+	bTop, bExists := c.Queue().Peek()
+	switch {
+	case transition >= o.System.LEFT && transition < o.System.RIGHT:
+		return o.System.LEFT, transition - o.System.LEFT, o.goldLabel(bTop, sTop, sExists && bExists)
+	case transition >= o.System.RIGHT:
+		return o.System.RIGHT, transition - o.System.RIGHT, o.goldLabel(sTop, bTop, sExists && bExists)
+	}
+	return transition, -2, -1
+}
+
+// goldLabel is the gold label of an arc, -1 if it isn't gold; the root is
+// correct when attached with the root label, whatever its head
+func (o *ArcEagerDynamicOracle) goldLabel(head, modifier int, exists bool) int {
+	if !exists || modifier >= len(o.heads) || o.heads[modifier] == noGoldHead {
+		return -1
+	}
+	rel := o.rels[modifier]
+	if o.heads[modifier] < 0 {
+		rel = DepRel(ROOT_LABEL)
+	} else if o.heads[modifier] != head {
+		return -1
+	}
+	label, found := o.System.Relations.IndexOf(rel)
+	if !found {
+		return -1
+	}
+	return label
+}
+
+// stuck is 1 if the queue front of c, after a reduce, can't leave the
+// queue without losing a gold arc, and 0 if it can. The system doesn't shift
+// after reducing, so the front must first attach to the stack top or from it,
+// possibly after reducing further.
+func (o *ArcEagerDynamicOracle) stuck(c *SimpleConfiguration) int {
+	front, exists := c.Queue().Peek()
+	if !exists || front >= len(o.heads) {
+		return 0
+	}
+	positions := o.positions(c)
+	for i := 0; i < c.Stack().Size(); i++ {
+		node, _ := c.Stack().Index(i)
+		if node >= len(o.heads) || o.heads[front] == node {
+			return 0
+		}
+		// the node leaves the stack, losing its gold modifiers on the queue
+		for modifier, head := range o.heads {
+			if head == node && positions[modifier] == inQueue {
+				return 1
+			}
+		}
+		if c.GetLabeledArc(node) == nil {
+			// attaching the node to the front loses nothing if its gold arc
+			// is to the front or already lost
+			head := o.heads[node]
+			if head == front || head < 0 || positions[head] != inQueue {
+				return 0
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// positions returns the positions of the nodes of c
+func (o *ArcEagerDynamicOracle) positions(c *SimpleConfiguration) []byte {
+	positions := make([]byte, len(o.heads))
+	for i := 0; i < len(positions) && i < len(c.Nodes); i++ {
+		positions[i] = processed
+	}
+	for i := 0; i < c.Stack().Size(); i++ {
+		if node, exists := c.Stack().Index(i); exists && node < len(positions) {
+			positions[node] = inStack
+		}
+	}
+	for i := 0; i < c.Queue().Size(); i++ {
+		if node, exists := c.Queue().Index(i); exists && node < len(positions) {
+			positions[node] = inQueue
+		}
+	}
+	return positions
+}
+
+// reachable counts the gold arcs built or still possible to build in c
+func (o *ArcEagerDynamicOracle) reachable(c *SimpleConfiguration) int {
+	positions := o.positions(c)
+	var count int
+	for modifier, head := range o.heads {
+		if head == noGoldHead {
+			continue
+		}
+		if modifier < len(c.Nodes) {
+			if arc := c.GetLabeledArc(modifier); arc != nil {
+				rel := arc.GetRelation()
+				if head < 0 && string(rel) == ROOT_LABEL ||
+					head >= 0 && arc.GetHead() == head && rel == o.rels[modifier] && string(rel) != ROOT_LABEL {
+					count++
+				}
+				continue
+			}
+		}
+		switch positions[modifier] {
+		case inQueue:
+			if head < 0 || positions[head] != processed {
+				count++
+			}
+		case inStack:
+			if head < 0 || positions[head] == inQueue {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func (o *ArcEagerDynamicOracle) Name() string {
+	return "Arc Eager Dynamic Oracle (goldberg & nivre coling '12)"
+}
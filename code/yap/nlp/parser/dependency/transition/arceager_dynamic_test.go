@@ -0,0 +1,277 @@
+package transition
+
+import (
+	"fmt"
+	"testing"
+
+	. "yap/alg/transition"
+	nlp "yap/nlp/types"
+	"yap/util"
+)
+
+// oracleRelations label the gold arcs of the oracle tests, by modifier
+var oracleRelations = []nlp.DepRel{"a", "b", "c"}
+
+// oracleArcStandard returns the enums of the oracle tests, as set up by the
+// app, and the REDUCE, POPROOT and SWAP transitions
+func oracleArcStandard() (ArcStandard, int, int, int) {
+	relations := util.NewEnumSet(len(oracleRelations) + 1)
+	relations.Add(nlp.DepRel(nlp.ROOT_LABEL))
+	for _, rel := range oracleRelations {
+		relations.Add(rel)
+	}
+	transitions := util.NewEnumSet(2*relations.Len() + 7)
+	transitions.Add("IDLE")
+	shift, _ := transitions.Add("SH")
+	reduce, _ := transitions.Add("RE")
+	transitions.Add("AL")
+	transitions.Add("AR")
+	popRoot, _ := transitions.Add("PR")
+	swap, _ := transitions.Add("SW")
+	left := transitions.Len()
+	for i := 0; i < relations.Len(); i++ {
+		transitions.Add("LA-" + string(relations.ValueOf(i).(nlp.DepRel)))
+	}
+	right := transitions.Len()
+	for i := 0; i < relations.Len(); i++ {
+		transitions.Add("RA-" + string(relations.ValueOf(i).(nlp.DepRel)))
+	}
+	return ArcStandard{SHIFT: shift, LEFT: left, RIGHT: right, Relations: relations, Transitions: transitions}, reduce, popRoot, swap
+}
+
+// oracleGold returns the gold graph of heads, -1 for the root, labeling its
+// arcs by oracleRelations
+func oracleGold(heads []int, relations *util.EnumSet) *BasicDepGraph {
+	g := &BasicDepGraph{make([]nlp.DepNode, len(heads)), make([]*BasicDepArc, len(heads))}
+	for i, head := range heads {
+		g.Nodes[i] = &TaggedDepNode{Id: i, RawToken: fmt.Sprint("w", i), RawPOS: "NN"}
+		rel := oracleRelations[i%len(oracleRelations)]
+		if head < 0 {
+			rel = nlp.DepRel(nlp.ROOT_LABEL)
+		}
+		relID, _ := relations.IndexOf(rel)
+		g.Arcs[i] = &BasicDepArc{head, relID, i, rel}
+	}
+	return g
+}
+
+// oracleConfiguration returns the initial configuration of the sentence of
+// gold
+func oracleConfiguration(gold *BasicDepGraph, terminalStack int) *SimpleConfiguration {
+	sent := make(nlp.BasicETaggedSentence, gold.NumberOfNodes())
+	for i := range sent {
+		sent[i].TaggedToken = nlp.TaggedToken{Token: fmt.Sprint("w", i), POS: "NN"}
+	}
+	c := &SimpleConfiguration{TerminalStack: terminalStack, TerminalQueue: 0}
+	c.Init(sent)
+	return c
+}
+
+// oracleTrees returns the heads of the single rooted trees of n nodes, the
+// projective ones or the non-projective ones
+func oracleTrees(n int, projective bool) [][]int {
+	var (
+		trees [][]int
+		heads = make([]int, n)
+	)
+	var add func(i int)
+	add = func(i int) {
+		if i == n {
+			if isTree(heads) && isProjective(heads) == projective {
+				trees = append(trees, append([]int(nil), heads...))
+			}
+			return
+		}
+		for head := -1; head < n; head++ {
+			if head != i {
+				heads[i] = head
+				add(i + 1)
+			}
+		}
+	}
+	add(0)
+	return trees
+}
+
+// isTree tells if heads have a single root and no cycles
+func isTree(heads []int) bool {
+	var roots int
+	for i, head := range heads {
+		if head < 0 {
+			roots++
+		}
+		for steps := 0; head >= 0; steps++ {
+			if head == i || steps > len(heads) {
+				return false
+			}
+			head = heads[head]
+		}
+	}
+	return roots == 1
+}
+
+// isProjective tells if the nodes between the head and the modifier of every
+// arc of a tree are dominated by its head
+func isProjective(heads []int) bool {
+	for modifier, head := range heads {
+		if head < 0 {
+			continue
+		}
+		from, to := head, modifier
+		if from > to {
+			from, to = to, from
+		}
+		for k := from + 1; k < to; k++ {
+			ancestor := heads[k]
+			for ancestor >= 0 && ancestor != head {
+				ancestor = heads[ancestor]
+			}
+			if ancestor != head {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// followOracle applies the oracle's transitions from c to a terminal
+// configuration, calling each before every transition; it fails on
+// transitions that aren't possible
+func followOracle(t *testing.T, system TransitionSystem, oracle Oracle, c *SimpleConfiguration, each func(*SimpleConfiguration, Transition)) (*SimpleConfiguration, int) {
+	var steps int
+	for !c.Terminal() {
+		if steps > 4*len(c.Nodes)*len(c.Nodes)+4 {
+			t.Fatalf("Oracle didn't reach a terminal configuration, at %v", c)
+		}
+		transition := oracle.Transition(c)
+		_, possible := system.GetTransitions(c)
+		legal := false
+		for _, p := range possible {
+			legal = legal || p == transition.Value()
+		}
+		if !legal {
+			t.Fatalf("Oracle transition %v isn't possible at %v (possible %v)", transition, c, possible)
+		}
+		if each != nil {
+			each(c, transition)
+		}
+		c = system.Transition(c, transition).(*SimpleConfiguration)
+		steps++
+	}
+	return c, steps
+}
+
+// checkArcs fails unless the arcs of c are the gold arcs; the root is
+// correct without an arc or with the root label, as it is written out
+func checkArcs(t *testing.T, c *SimpleConfiguration, gold *BasicDepGraph) {
+	for i, goldArc := range gold.Arcs {
+		arc := c.GetLabeledArc(i)
+		switch {
+		case goldArc.Head < 0:
+			if arc != nil && string(arc.GetRelation()) != nlp.ROOT_LABEL {
+				t.Errorf("Root %d of %v got arc %v", i, gold.Arcs, arc)
+			}
+		case arc == nil:
+			t.Errorf("Node %d of %v has no arc, expected %v", i, gold.Arcs, goldArc)
+		case arc.GetHead() != goldArc.Head || arc.GetRelation() != goldArc.RawRelation:
+			t.Errorf("Node %d of %v got arc %v, expected %v", i, gold.Arcs, arc, goldArc)
+		}
+	}
+}
+
+func newOracleEager() *ArcEager {
+	standard, reduce, popRoot, _ := oracleArcStandard()
+	return &ArcEager{ArcStandard: standard, REDUCE: reduce, POPROOT: popRoot}
+}
+
+func TestArcEagerDynamicOracleStaticPath(t *testing.T) {
+	eager := newOracleEager()
+	eager.AddDefaultOracle()
+	static := eager.Oracle()
+	eager.AddDynamicOracle()
+	dynamic := eager.Oracle().(*ArcEagerDynamicOracle)
+	for n := 1; n <= 5; n++ {
+		for _, heads := range oracleTrees(n, true) {
+			gold := oracleGold(heads, eager.Relations)
+			static.SetGold(gold)
+			dynamic.SetGold(gold)
+			c, _ := followOracle(t, eager, static, oracleConfiguration(gold, 0), func(c *SimpleConfiguration, transition Transition) {
+				_, transitions, costs := dynamic.Costs(c)
+				for i, next := range transitions {
+					if next == transition.Value() && costs[i] == 0 {
+						return
+					}
+				}
+				t.Errorf("Static oracle transition %v of %v costs %v at %v", transition, heads, costs, c)
+			})
+			checkArcs(t, c, gold)
+			c, _ = followOracle(t, eager, dynamic, oracleConfiguration(gold, 0), nil)
+			checkArcs(t, c, gold)
+		}
+	}
+}
+
+func TestArcEagerDynamicOracleCosts(t *testing.T) {
+	eager := newOracleEager()
+	eager.AddDynamicOracle()
+	oracle := eager.Oracle().(*ArcEagerDynamicOracle)
+	// 1 is the root, with 0 (a) and 2 (c); 2 has 4 (b), which has 3 (a)
+	gold := oracleGold([]int{1, -1, 1, 4, 2}, eager.Relations)
+	oracle.SetGold(gold)
+	transition := func(name string) Transition {
+		index, _ := eager.Transitions.IndexOf(name)
+		return &TypedTransition{TransitionType, index}
+	}
+	checkCosts := func(c *SimpleConfiguration, expected map[string]int) {
+		_, transitions, costs := oracle.Costs(c)
+		if len(transitions) != len(expected) {
+			t.Errorf("Got transitions %v at %v, expected %v", transitions, c, expected)
+		}
+		for i, next := range transitions {
+			name := eager.Transitions.ValueOf(next).(string)
+			if cost, exists := expected[name]; !exists || costs[i] != cost {
+				t.Errorf("Got cost %d for %s at %v, expected %v", costs[i], name, c, expected)
+			}
+		}
+	}
+	// shifting 0 loses its arc from 1: stack [0 1], queue [2 3 4]
+	c := oracleConfiguration(gold, 0)
+	c = eager.Transition(c, transition("SH")).(*SimpleConfiguration)
+	c = eager.Transition(c, transition("SH")).(*SimpleConfiguration)
+	checkCosts(c, map[string]int{
+		// 2 is left without its head 1
+		"SH": 1,
+		// 1 -c-> 2 is gold, other labels lose it
+		"RA-ROOT": 1, "RA-a": 1, "RA-b": 1, "RA-c": 0,
+		// 2 is left without its head 1, and but for the root label so is 1
+		"LA-ROOT": 1, "LA-a": 2, "LA-b": 2, "LA-c": 2,
+	})
+	// stack [0 1 2], queue [3 4]
+	c = eager.Transition(c, transition("RA-c")).(*SimpleConfiguration)
+	checkCosts(c, map[string]int{
+		// 3 waits on the stack for its head 4
+		"SH": 0,
+		// 4 is left without its head 2
+		"RE": 1,
+		// 3 isn't a modifier of 2
+		"RA-ROOT": 1, "RA-a": 1, "RA-b": 1, "RA-c": 1,
+	})
+	if next := oracle.Transition(c); eager.Transitions.ValueOf(next.Value()) != "SH" {
+		t.Errorf("Got oracle transition %v at %v, expected SH", eager.Transitions.ValueOf(next.Value()), c)
+	}
+	// 0 is the root, with 1 (b) and 3 (a); 3 has 2 (c)
+	gold = oracleGold([]int{-1, 0, 3, 0}, eager.Relations)
+	oracle.SetGold(gold)
+	// stack [0 1], queue [2 3]
+	c = oracleConfiguration(gold, 0)
+	c = eager.Transition(c, transition("SH")).(*SimpleConfiguration)
+	c = eager.Transition(c, transition("RA-b")).(*SimpleConfiguration)
+	checkCosts(c, map[string]int{
+		"SH": 0,
+		// the system doesn't shift after reducing, so 2 must then attach to
+		// 0, losing its head 3, or 0 to 2, losing its modifier 3
+		"RE": 1,
+		// 2 isn't a modifier of 1
+		"RA-ROOT": 1, "RA-a": 1, "RA-b": 1, "RA-c": 1,
+	})
+}
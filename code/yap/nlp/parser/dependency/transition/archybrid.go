@@ -0,0 +1,193 @@
+package transition
+
+import (
+	"fmt"
+	. "yap/alg/transition"
+	. "yap/nlp/types"
+)
+
+// ArcHybrid is the arc hybrid system (kuhlmann et al. acl '11): left arcs
+// are made as in arc eager, from the head of the queue to the top of the
+// stack, and right arcs as in arc standard, between the two top elements of
+// the stack. Every node is shifted and then reduced by an arc (or POPROOT for
+// the root), so all parses of a sentence take the same number of transitions.
+type ArcHybrid struct {
+	ArcStandard
+	POPROOT int
+}
+
+// Verify that ArcHybrid is a TransitionSystem
+var _ TransitionSystem = &ArcHybrid{}
+
+func (a *ArcHybrid) Transition(from Configuration, rawTransition Transition) Configuration {
+	transition := rawTransition.Value()
+	if transition != a.POPROOT && transition < a.RIGHT {
+		// LA and SH are those of arc standard
+		return a.ArcStandard.Transition(from, rawTransition)
+	}
+	conf, ok := from.Copy().(*SimpleConfiguration)
+	if !ok {
+		panic("Got wrong configuration type")
+	}
+	// Transition System:
+	// LA-r	(S|wi,		wj|B,	A) => (S   ,	wj|B,	A+{(wj,r,wi)})
+	// RA-r	(S|wi|wj,	   B,	A) => (S|wi,	   B,	A+{(wi,r,wj)})
+	// SH	(S   ,		wi|B, 	A) => (S|wi,	   B,	A)
+	// PR	([wi],		  [],	A) => ([]  ,	  [],	A+{(0,ROOT,wi)})
+	switch transition {
+	case a.POPROOT:
+		if _, wjExists := conf.Queue().Peek(); wjExists {
+			panic("Can't poproot, queue is not empty")
+		}
+		if conf.Stack().Size() != 1 {
+			panic("Can't poproot, stack has doesn't have just 1 value")
+		}
+		wi, _ := conf.Stack().Pop()
+		relID, _ := a.Relations.IndexOf(ROOT_LABEL)
+		newArc := &BasicDepArc{0, relID, wi, DepRel(ROOT_LABEL)}
+		conf.AddArc(newArc)
+		conf.Assign(uint16(conf.Nodes[wi].ID()))
+		conf.NumHeadStack--
+	default:
+		wj, wjExists := conf.Stack().Pop()
+		wi, wiExists := conf.Stack().Peek()
+		if !(wiExists && wjExists) {
+			panic(fmt.Sprintf("Can't RA, Stack has less than 2 elements: %v", conf))
+		}
+		rel := int(transition - a.RIGHT)
+		relValue := a.Relations.ValueOf(rel).(DepRel)
+		newArc := &BasicDepArc{wi, rel, wj, relValue}
+		conf.AddArc(newArc)
+		conf.Assign(uint16(conf.Nodes[newArc.Modifier].ID()))
+		// the modifier popped off the stack had no head
+		conf.NumHeadStack--
+	}
+	conf.SetLastTransition(rawTransition)
+	return conf
+}
+
+func (a *ArcHybrid) possibleTransitions(from Configuration, transitions chan int) {
+	conf, ok := from.(*SimpleConfiguration)
+	if !ok {
+		panic("Got wrong configuration type")
+	}
+	_, qExists := conf.Queue().Peek()
+	_, sExists := conf.Stack().Peek()
+	sSize := conf.Stack().Size()
+	if qExists {
+		transitions <- a.SHIFT
+	}
+	if sExists && qExists {
+		for rel, _ := range a.Relations.Index {
+			transitions <- a.LEFT + rel
+		}
+	}
+	if sSize > 1 {
+		for rel, _ := range a.Relations.Index {
+			transitions <- a.RIGHT + rel
+		}
+	}
+	if !qExists && sSize == 1 {
+		transitions <- a.POPROOT
+	}
+	close(transitions)
+}
+
+func (a *ArcHybrid) GetTransitions(from Configuration) (byte, []int) {
+	retval := make([]int, 0, 10)
+	tType, transitions := a.YieldTransitions(from)
+	for transition := range transitions {
+		retval = append(retval, transition)
+	}
+	return tType, retval
+}
+
+func (a *ArcHybrid) YieldTransitions(from Configuration) (byte, chan int) {
+	transitions := make(chan int)
+	go a.possibleTransitions(from, transitions)
+	return TransitionType, transitions
+}
+
+func (a *ArcHybrid) TransitionTypes() []string {
+	return append(a.ArcStandard.TransitionTypes(), "PR")
+}
+
+func (a *ArcHybrid) AddDefaultOracle() {
+	a.oracle = Oracle(&ArcHybridOracle{
+		ArcStandardOracle: ArcStandardOracle{Transitions: a.Transitions, LA: int(a.LEFT), RA: int(a.RIGHT)},
+		PR:                a.POPROOT,
+	})
+}
+
+func (a *ArcHybrid) Name() string {
+	return "Arc Hybrid"
+}
+
+// ArcHybridOracle is the static oracle of the arc hybrid system: it makes
+// every gold left arc as soon as it can, and a gold right arc once its
+// modifier has all its gold modifiers
+type ArcHybridOracle struct {
+	ArcStandardOracle
+	PR int
+}
+
+var _ Decision = &ArcHybridOracle{}
+
+func (o *ArcHybridOracle) Transition(conf Configuration) Transition {
+	c := conf.(*SimpleConfiguration)
+
+	if o.gold == nil {
+		panic("Oracle needs gold reference, use SetGold")
+	}
+	// Given Gd=(Vd,Ad) # gold dependencies
+	// o(c = (S,B,A)) =
+	// PR	if	S = [S0] and B is empty
+	// LA-r	if	(B[0],r,S[0]) in Ad
+	// RA-r	if	(S[1],r,S[0]) in Ad; and for all w,r', if (S[0],r',w) in Ad then (S[0],r',w) in A
+	// SH	otherwise
+	bTop, bExists := c.Queue().Peek()
+	sTop, sExists := c.Stack().Peek()
+	sSecond, sSecondExists := c.Stack().Index(1)
+	var index int
+	if !bExists {
+		if !sExists {
+			panic(fmt.Sprintf("Got empty configuration %v", c))
+		}
+		if !sSecondExists {
+			return &TypedTransition{TransitionType, o.PR}
+		}
+		// only right arcs are left; a non-projective gold tree can't be
+		// followed, so S[0] is attached with its gold label regardless
+		index, _ = o.Transitions.IndexOf("RA-" + string(o.goldRelation(sTop)))
+		return &TypedTransition{TransitionType, index}
+	}
+	if sExists {
+		arcs := o.arcSet.Get(&BasicDepArc{bTop, -1, sTop, DepRel("")})
+		if len(arcs) > 0 {
+			index, _ = o.Transitions.IndexOf("LA-" + string(arcs[0].GetRelation()))
+			return &TypedTransition{TransitionType, index}
+		}
+		if sSecondExists {
+			arcs = o.arcSet.Get(&BasicDepArc{sSecond, -1, sTop, DepRel("")})
+			if len(arcs) > 0 && o.complete(c, sTop) {
+				index, _ = o.Transitions.IndexOf("RA-" + string(arcs[0].GetRelation()))
+				return &TypedTransition{TransitionType, index}
+			}
+		}
+	}
+	index, _ = o.Transitions.IndexOf("SH")
+	return &TypedTransition{TransitionType, index}
+}
+
+// goldRelation returns the gold label of a node, ROOT for the gold root
+func (o *ArcHybridOracle) goldRelation(node int) DepRel {
+	arcs := o.arcSet.Get(&BasicDepArc{-1, -1, node, DepRel("")})
+	if len(arcs) == 0 || arcs[0].GetHead() < 0 {
+		return DepRel(ROOT_LABEL)
+	}
+	return arcs[0].GetRelation()
+}
+
+func (o *ArcHybridOracle) Name() string {
+	return "Arc Hybrid"
+}
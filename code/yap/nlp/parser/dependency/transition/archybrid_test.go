@@ -0,0 +1,24 @@
+package transition
+
+import (
+	"testing"
+)
+
+func TestArcHybridOracle(t *testing.T) {
+	standard, _, popRoot, _ := oracleArcStandard()
+	system := &ArcHybrid{ArcStandard: standard, POPROOT: popRoot}
+	system.AddDefaultOracle()
+	oracle := system.Oracle()
+	for n := 1; n <= 5; n++ {
+		for _, heads := range oracleTrees(n, true) {
+			gold := oracleGold(heads, system.Relations)
+			oracle.SetGold(gold)
+			c, steps := followOracle(t, system, oracle, oracleConfiguration(gold, 0), nil)
+			checkArcs(t, c, gold)
+			// every node is shifted and then reduced by an arc
+			if steps != 2*n {
+				t.Errorf("Oracle took %d transitions to parse %v, expected %d", steps, heads, 2*n)
+			}
+		}
+	}
+}
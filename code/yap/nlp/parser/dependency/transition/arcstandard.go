@@ -230,3 +230,13 @@ func (o *ArcStandardOracle) Transition(conf Configuration) Transition {
 func (o *ArcStandardOracle) Name() string {
 	return "Arc Standard"
 }
+
+// complete tells if all the gold modifiers of a node are attached to it
+func (o *ArcStandardOracle) complete(c *SimpleConfiguration, node int) bool {
+	for _, arc := range o.arcSet.Get(&BasicDepArc{node, -1, -1, DepRel("")}) {
+		if len(c.Arcs().Get(arc)) == 0 {
+			return false
+		}
+	}
+	return true
+}
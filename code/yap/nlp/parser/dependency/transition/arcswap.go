@@ -0,0 +1,234 @@
+package transition
+
+import (
+	"fmt"
+	"sort"
+	. "yap/alg/transition"
+	. "yap/nlp/types"
+)
+
+// ArcSwap is the arc standard system with an online reordering transition
+// (nivre acl '09): SWAP moves the top of the stack back to the queue, behind
+// the head of the queue, so that nodes which aren't adjacent in the sentence
+// can be attached, yielding non-projective trees
+type ArcSwap struct {
+	ArcStandard
+	SWAP int
+}
+
+// Verify that ArcSwap is a TransitionSystem
+var _ TransitionSystem = &ArcSwap{}
+
+func (a *ArcSwap) Transition(from Configuration, rawTransition Transition) Configuration {
+	transition := rawTransition.Value()
+	if transition != a.SWAP {
+		return a.ArcStandard.Transition(from, rawTransition)
+	}
+	conf, ok := from.Copy().(*SimpleConfiguration)
+	if !ok {
+		panic("Got wrong configuration type")
+	}
+	// Transition System:
+	// SW	(S|wi,	wj|B,	A) => (S   ,	wj|wi|B,	A)	if: i < j
+	wi, wiExists := conf.Stack().Pop()
+	wj, wjExists := conf.Queue().Pop()
+	if !(wiExists && wjExists) {
+		panic("Can't SW, Stack and/or Queue are/is empty")
+	}
+	if wi > wj {
+		panic(fmt.Sprintf("Can't SW %d and %d, they are already swapped", wi, wj))
+	}
+	conf.Queue().Push(wi)
+	conf.Queue().Push(wj)
+	conf.Assign(uint16(conf.Nodes[wi].ID()))
+	// the element swapped off the stack had no head
+	conf.NumHeadStack--
+	conf.SetLastTransition(rawTransition)
+	return conf
+}
+
+func (a *ArcSwap) possibleTransitions(from Configuration, transitions chan int) {
+	conf, ok := from.(*SimpleConfiguration)
+	if !ok {
+		panic("Got wrong configuration type")
+	}
+	qPeek, qExists := conf.Queue().Peek()
+	sPeek, sExists := conf.Stack().Peek()
+	// the last node of the queue is shifted only onto an empty stack, as the
+	// root; otherwise it has to be attached
+	if qExists && (!sExists || conf.Queue().Size() > 1) {
+		transitions <- a.SHIFT
+	}
+	if sExists && qExists {
+		for rel, _ := range a.Relations.Index {
+			transitions <- a.LEFT + rel
+		}
+		for rel, _ := range a.Relations.Index {
+			transitions <- a.RIGHT + rel
+		}
+		if sPeek < qPeek {
+			transitions <- a.SWAP
+		}
+	}
+	close(transitions)
+}
+
+func (a *ArcSwap) GetTransitions(from Configuration) (byte, []int) {
+	retval := make([]int, 0, 10)
+	tType, transitions := a.YieldTransitions(from)
+	for transition := range transitions {
+		retval = append(retval, transition)
+	}
+	return tType, retval
+}
+
+func (a *ArcSwap) YieldTransitions(from Configuration) (byte, chan int) {
+	transitions := make(chan int)
+	go a.possibleTransitions(from, transitions)
+	return TransitionType, transitions
+}
+
+func (a *ArcSwap) TransitionTypes() []string {
+	return append(a.ArcStandard.TransitionTypes(), "SW")
+}
+
+func (a *ArcSwap) Projective() bool {
+	return false
+}
+
+func (a *ArcSwap) AddDefaultOracle() {
+	a.oracle = Oracle(&ArcSwapOracle{
+		ArcStandardOracle: ArcStandardOracle{Transitions: a.Transitions, LA: int(a.LEFT), RA: int(a.RIGHT)},
+		SW:                a.SWAP,
+	})
+}
+
+func (a *ArcSwap) Name() string {
+	return "Arc Swap"
+}
+
+// ArcSwapOracle is the static oracle of the swap system (nivre acl '09): it
+// attaches nodes once they have all their modifiers, and swaps the top of the
+// stack whenever the head of the queue precedes it in the projective order of
+// the gold tree, the order of its inorder traversal
+type ArcSwapOracle struct {
+	ArcStandardOracle
+	SW int
+
+	order []int
+}
+
+var _ Decision = &ArcSwapOracle{}
+
+func (o *ArcSwapOracle) SetGold(g interface{}) {
+	o.ArcStandardOracle.SetGold(g)
+	o.order = ProjectiveOrder(o.gold)
+}
+
+func (o *ArcSwapOracle) Transition(conf Configuration) Transition {
+	c := conf.(*SimpleConfiguration)
+
+	if o.gold == nil {
+		panic("Oracle needs gold reference, use SetGold")
+	}
+	// Given Gd=(Vd,Ad) # gold dependencies
+	// o(c = (S,B,A)) =
+	// LA-r	if	(B[0],r,S[0]) in Ad; and for all w,r', if (S[0],r',w) in Ad then (S[0],r',w) in A
+	// RA-r	if	(S[0],r,B[0]) in Ad; and for all w,r', if (B[0],r',w) in Ad then (B[0],r',w) in A
+	// SW	if	B[0] precedes S[0] in the projective order of Gd
+	// SH	otherwise
+	bTop, bExists := c.Queue().Peek()
+	sTop, sExists := c.Stack().Peek()
+	if !bExists {
+		panic(fmt.Sprintf("Got empty configuration %v", c))
+	}
+	var index int
+	if sExists {
+		arcs := o.arcSet.Get(&BasicDepArc{bTop, -1, sTop, DepRel("")})
+		if len(arcs) > 0 && o.complete(c, sTop) {
+			index, _ = o.Transitions.IndexOf("LA-" + string(arcs[0].GetRelation()))
+			return &TypedTransition{TransitionType, index}
+		}
+		arcs = o.arcSet.Get(&BasicDepArc{sTop, -1, bTop, DepRel("")})
+		if len(arcs) > 0 && o.complete(c, bTop) {
+			index, _ = o.Transitions.IndexOf("RA-" + string(arcs[0].GetRelation()))
+			return &TypedTransition{TransitionType, index}
+		}
+		if bTop < len(o.order) && sTop < len(o.order) && o.order[bTop] < o.order[sTop] {
+			return &TypedTransition{TransitionType, o.SW}
+		}
+	}
+	index, _ = o.Transitions.IndexOf("SH")
+	return &TypedTransition{TransitionType, index}
+}
+
+func (o *ArcSwapOracle) Name() string {
+	return "Arc Swap (eager)"
+}
+
+// ProjectiveOrder returns the position of every node of a dependency graph in
+// its inorder traversal: each head between its left and right modifiers, the
+// roots in the order of the sentence. Ordering the nodes by their projective
+// order makes every tree projective.
+func ProjectiveOrder(graph LabeledDependencyGraph) []int {
+	numNodes := graph.NumberOfNodes()
+	heads := make([]int, numNodes)
+	for i := range heads {
+		heads[i] = -1
+	}
+	for _, arcID := range graph.GetEdges() {
+		arc := graph.GetLabeledArc(arcID)
+		if arc == nil || arc.GetModifier() < 0 || arc.GetModifier() >= numNodes {
+			continue
+		}
+		if head := arc.GetHead(); head >= 0 && head < numNodes {
+			heads[arc.GetModifier()] = head
+		}
+	}
+	modifiers := make([][]int, numNodes)
+	var roots []int
+	for node, head := range heads {
+		if head < 0 {
+			roots = append(roots, node)
+		} else {
+			modifiers[head] = append(modifiers[head], node)
+		}
+	}
+	order := make([]int, numNodes)
+	for i := range order {
+		order[i] = -1
+	}
+	var (
+		position int
+		visit    func(node int)
+	)
+	visit = func(node int) {
+		if order[node] >= 0 {
+			// cycles in the gold are broken arbitrarily
+			return
+		}
+		order[node] = numNodes
+		sort.Ints(modifiers[node])
+		for _, modifier := range modifiers[node] {
+			if modifier < node {
+				visit(modifier)
+			}
+		}
+		order[node] = position
+		position++
+		for _, modifier := range modifiers[node] {
+			if modifier > node {
+				visit(modifier)
+			}
+		}
+	}
+	for _, root := range roots {
+		visit(root)
+	}
+	for node := range order {
+		if order[node] < 0 {
+			visit(node)
+		}
+	}
+	return order
+}
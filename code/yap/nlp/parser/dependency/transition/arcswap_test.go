@@ -0,0 +1,70 @@
+package transition
+
+import (
+	"testing"
+
+	. "yap/alg/transition"
+)
+
+func TestProjectiveOrder(t *testing.T) {
+	standard, _, _, _ := oracleArcStandard()
+	for n := 1; n <= 5; n++ {
+		for _, projective := range []bool{true, false} {
+			for _, heads := range oracleTrees(n, projective) {
+				order := ProjectiveOrder(oracleGold(heads, standard.Relations))
+				// reordered by the projective order, the tree is projective
+				ordered, seen := make([]int, n), make([]bool, n)
+				for node, head := range heads {
+					if order[node] < 0 || order[node] >= n || seen[order[node]] {
+						t.Fatalf("Got order %v of %v, not a permutation", order, heads)
+					}
+					seen[order[node]] = true
+					ordered[order[node]] = -1
+					if head >= 0 {
+						ordered[order[node]] = order[head]
+					}
+				}
+				if !isProjective(ordered) {
+					t.Errorf("Got order %v of %v, reordered to the non-projective %v", order, heads, ordered)
+				}
+				if !projective {
+					continue
+				}
+				for node := range order {
+					if order[node] != node {
+						t.Errorf("Got order %v of the projective %v, expected the sentence order", order, heads)
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestArcSwapOracle(t *testing.T) {
+	standard, _, _, swap := oracleArcStandard()
+	system := &ArcSwap{ArcStandard: standard, SWAP: swap}
+	system.AddDefaultOracle()
+	oracle := system.Oracle()
+	var swapped bool
+	for n := 1; n <= 5; n++ {
+		for _, projective := range []bool{true, false} {
+			for _, heads := range oracleTrees(n, projective) {
+				gold := oracleGold(heads, system.Relations)
+				oracle.SetGold(gold)
+				c, _ := followOracle(t, system, oracle, oracleConfiguration(gold, 1), func(c *SimpleConfiguration, transition Transition) {
+					if transition.Value() == swap {
+						swapped = true
+						if projective {
+							t.Errorf("Oracle swapped at %v of the projective %v", c, heads)
+						}
+					}
+				})
+				checkArcs(t, c, gold)
+			}
+		}
+	}
+	if !swapped {
+		t.Error("Oracle never swapped")
+	}
+}
@@ -7,15 +7,15 @@ import (
 )
 
 var rawTestSent nlp.BasicETaggedSentence = nlp.BasicETaggedSentence{
-	{TaggedToken: nlp.TaggedToken{"Economic", "NN"}},
-	{TaggedToken: nlp.TaggedToken{"news", "NN"}},
-	{TaggedToken: nlp.TaggedToken{"had", "VB"}},
-	{TaggedToken: nlp.TaggedToken{"little", "ADJ"}},
-	{TaggedToken: nlp.TaggedToken{"effect", "NN"}},
-	{TaggedToken: nlp.TaggedToken{"on", "NN"}},
-	{TaggedToken: nlp.TaggedToken{"financial", "NN"}},
-	{TaggedToken: nlp.TaggedToken{"markets", "NN"}},
-	{TaggedToken: nlp.TaggedToken{".", "yyDOT"}}}
+	{TaggedToken: nlp.TaggedToken{"Economic", "", "NN"}},
+	{TaggedToken: nlp.TaggedToken{"news", "", "NN"}},
+	{TaggedToken: nlp.TaggedToken{"had", "", "VB"}},
+	{TaggedToken: nlp.TaggedToken{"little", "", "ADJ"}},
+	{TaggedToken: nlp.TaggedToken{"effect", "", "NN"}},
+	{TaggedToken: nlp.TaggedToken{"on", "", "NN"}},
+	{TaggedToken: nlp.TaggedToken{"financial", "", "NN"}},
+	{TaggedToken: nlp.TaggedToken{"markets", "", "NN"}},
+	{TaggedToken: nlp.TaggedToken{".", "", "yyDOT"}}}
 
 var TEST_SENT nlp.TaggedSentence
 
@@ -1,6 +1,7 @@
 package transition
 
 import (
+	. "yap/alg"
 	. "yap/nlp/types"
 	"testing"
 )
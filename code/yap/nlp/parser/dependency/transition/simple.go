@@ -62,6 +62,7 @@ func (c *SimpleConfiguration) Init(abstractSentence interface{}) {
 			enumToken.Token,
 			enumToken.Lemma,
 			enumToken.POS,
+			enumToken.Feats,
 		}
 		c.Nodes = append(c.Nodes, NewArcCachedDepNode(nlp.DepNode(node)))
 	}
@@ -401,7 +402,7 @@ func (c *SimpleConfiguration) TaggedSentence() nlp.TaggedSentence {
 		sent[i] = nlp.EnumTaggedToken{
 			nlp.TaggedToken{taggedNode.RawToken, taggedNode.RawLemma, taggedNode.RawPOS},
 			// TODO: add lemma enum
-			taggedNode.Token, 0, taggedNode.POS, taggedNode.TokenPOS, taggedNode.MHost, taggedNode.MSuffix}
+			taggedNode.Token, 0, taggedNode.POS, taggedNode.TokenPOS, taggedNode.MHost, taggedNode.MSuffix, taggedNode.RawFeats}
 	}
 	return sent
 }
@@ -125,6 +125,8 @@ func (c *SimpleConfiguration) Attribute(source byte, nodeID int, attribute []byt
 		return
 	case 'd':
 		return c.GetConfDistance()
+	case 'i':
+		return c.GetConfInversion()
 	case 'w':
 		node := c.GetRawNode(nodeID)
 		if len(attribute) > 1 && attribute[1] == 'p' {
@@ -211,6 +213,20 @@ func (c *SimpleConfiguration) GetConfDistance() (int, bool, bool) {
 	return 0, false, false
 }
 
+// GetConfInversion tells if the head of the queue precedes the top of the
+// stack in the sentence, as it can after a swap (1) or not (0)
+func (c *SimpleConfiguration) GetConfInversion() (int, bool, bool) {
+	stackTop, stackExists := c.Stack().Peek()
+	queueTop, queueExists := c.Queue().Peek()
+	if stackExists && queueExists {
+		if queueTop < stackTop {
+			return 1, true, false
+		}
+		return 0, true, false
+	}
+	return 0, false, false
+}
+
 func (c *SimpleConfiguration) GetSource(location byte) Index {
 	switch location {
 	case 'N':
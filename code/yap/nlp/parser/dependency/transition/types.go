@@ -42,6 +42,7 @@ type TaggedDepNode struct {
 	RawToken string
 	RawLemma string
 	RawPOS   string
+	RawFeats string
 }
 
 var _ nlp.DepNode = &TaggedDepNode{}
@@ -241,6 +242,7 @@ func (g *BasicDepGraph) TaggedSentence() nlp.TaggedSentence {
 			taggedNode.TokenPOS,
 			taggedNode.MHost,
 			taggedNode.MSuffix,
+			taggedNode.RawFeats,
 		}
 	}
 	return nlp.TaggedSentence(nlp.EnumTaggedSentence(sent))
@@ -7,7 +7,7 @@ import (
 )
 
 func TestTaggedDepNode(t *testing.T) {
-	node := &TaggedDepNode{0, 0, 0, 0, "token", "tag"}
+	node := &TaggedDepNode{Id: 0, Token: 0, POS: 0, TokenPOS: 0, RawToken: "token", RawPOS: "tag"}
 	if node.ID() != 0 {
 		t.Error("Got wrong ID")
 	}
@@ -24,7 +24,7 @@ func TestTaggedDepNode(t *testing.T) {
 	if !node.Equal(other) {
 		t.Error("Failed equality on equal pointers")
 	}
-	other = &TaggedDepNode{0, 0, 1, 1, "token", "tag2"}
+	other = &TaggedDepNode{Id: 0, Token: 0, POS: 1, TokenPOS: 1, RawToken: "token", RawPOS: "tag2"}
 	if node.Equal(other) {
 		t.Error("Returned equal on non-equal nodes")
 	}
@@ -83,9 +83,9 @@ func TestBasicDepGraph(t *testing.T) {
 		t.Error("Got non-nil edge/vertex/arc/node for empty graph")
 	}
 	g = &BasicDepGraph{
-		[]nlp.DepNode{&TaggedDepNode{0, 0, 0, 0, "v1", "tag1"},
-			&TaggedDepNode{1, 0, 1, 1, "v1", "tag2"}},
-		[]*BasicDepArc{&BasicDepArc{0, 1, 1, "a"}}}
+		[]nlp.DepNode{&TaggedDepNode{Id: 0, Token: 0, POS: 0, TokenPOS: 0, RawToken: "v1", RawPOS: "tag1"},
+			&TaggedDepNode{Id: 1, Token: 0, POS: 1, TokenPOS: 1, RawToken: "v1", RawPOS: "tag2"}},
+		[]*BasicDepArc{&BasicDepArc{1, 1, 0, "a"}}}
 	if g.NumberOfNodes() != 2 || g.NumberOfVertices() != 2 {
 		t.Error("Got wrong number of nodes/vertices")
 	}
@@ -108,6 +108,7 @@ func (c *MDConfig) CopyTo(target Configuration) {
 		lastMappingIdx := len(c.Mappings) - 1
 		newLastMapping := &nlp.Mapping{
 			Token: c.Mappings[lastMappingIdx].Token,
+			Range: c.Mappings[lastMappingIdx].Range,
 			Spellout: make(nlp.Spellout,
 				len(c.Mappings[lastMappingIdx].Spellout),
 				cap(c.Mappings[lastMappingIdx].Spellout))}
@@ -315,7 +316,7 @@ func (c *MDConfig) AddSpellout(spellout string, paramFunc nlp.MDParam) bool {
 		for _, s := range curLattice.Spellouts {
 			if nlp.ProjectSpellout(s, paramFunc) == spellout {
 				c.CurrentLatNode = curLattice.Top()
-				c.Mappings = append(c.Mappings, &nlp.Mapping{Token: curLattice.Token, Spellout: s})
+				c.Mappings = append(c.Mappings, &nlp.Mapping{Token: curLattice.Token, Spellout: s, Range: curLattice.Range})
 				// log.Println("\tPost mappings:", c.Mappings)
 				return true
 			}
@@ -367,7 +368,7 @@ func (c *MDConfig) AddMapping(m *nlp.EMorpheme) {
 
 	if len(c.Mappings) == 0 || len(c.Mappings) < currentLatIdx {
 		// log.Println("\tAdding new mapping because", len(c.Mappings), currentLatIdx)
-		c.Mappings = append(c.Mappings, &nlp.Mapping{Token: c.Lattices[currentLatIdx].Token, Spellout: make(nlp.Spellout, 0, 1)})
+		c.Mappings = append(c.Mappings, &nlp.Mapping{Token: c.Lattices[currentLatIdx].Token, Spellout: make(nlp.Spellout, 0, 1), Range: c.Lattices[currentLatIdx].Range})
 	}
 
 	currentMap := c.Mappings[len(c.Mappings)-1]
@@ -391,7 +392,7 @@ func (c *MDConfig) AddMapping(m *nlp.EMorpheme) {
 		val, exists := c.LatticeQueue.Peek()
 		// log.Println("\tNow at lattice (exists)", val, exists)
 		if exists {
-			c.Mappings = append(c.Mappings, &nlp.Mapping{Token: c.Lattices[val].Token, Spellout: make(nlp.Spellout, 0, 1)})
+			c.Mappings = append(c.Mappings, &nlp.Mapping{Token: c.Lattices[val].Token, Spellout: make(nlp.Spellout, 0, 1), Range: c.Lattices[val].Range})
 			// log.Println("\tSetting token to", c.Lattices[val].Token)
 		}
 	}
@@ -72,6 +72,7 @@ func (t *JointTrans) Transition(from Configuration, transition Transition) Confi
 				curMorpheme.Form,
 				curMorpheme.Lemma,
 				curMorpheme.POS,
+				curMorpheme.FeatureStr,
 			}
 
 			c.SimpleConfiguration.Nodes = append(c.SimpleConfiguration.Nodes,
@@ -177,6 +178,7 @@ type JointOracle struct {
 }
 
 var _ Decision = &JointOracle{}
+var _ DynamicOracle = &JointOracle{}
 
 func (o *JointOracle) SetGold(g interface{}) {
 	graph, ok := g.(*morph.BasicMorphGraph)
@@ -230,6 +232,34 @@ func (o *JointOracle) Transition(conf Configuration) Transition {
 	return ConstTransition(0)
 }
 
+// Costs scores the arc transitions with the arc system's oracle when it is a
+// DynamicOracle; the morphological disambiguation follows the oracle, its
+// transition the only one possible at no cost
+func (o *JointOracle) Costs(conf Configuration) (byte, []int, []int) {
+	c, ok := conf.(*JointConfig)
+	if !ok {
+		panic("Conf must be *JointConfig")
+	}
+	if dynamic, isDynamic := o.ArcSysOracle.(DynamicOracle); isDynamic && !o.disambiguates(c) {
+		return dynamic.Costs(&c.SimpleConfiguration)
+	}
+	transition := o.Transition(conf)
+	return transition.Type(), []int{transition.Value()}, []int{0}
+}
+
+// disambiguates tells whether the oracle strategy takes a morphological
+// disambiguation transition at c
+func (o *JointOracle) disambiguates(c *JointConfig) bool {
+	switch o.OracleStrategy {
+	case "MDFirst":
+		return !c.MDConfig.Terminal()
+	case "ArcGreedy":
+		return c.SimpleConfiguration.Queue().Size() < 3 && !c.MDConfig.Terminal()
+	default:
+		panic("Unknown oracle strategy: " + o.OracleStrategy)
+	}
+}
+
 func (o *JointOracle) Name() string {
 	return "Joint Morpho-Syntactic - Strategy: " + o.OracleStrategy
 }
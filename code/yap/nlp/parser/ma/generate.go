@@ -0,0 +1,121 @@
+package ma
+
+import (
+	. "yap/nlp/types"
+
+	"log"
+	"sort"
+	"strings"
+)
+
+// LexEntry is an analysis of a surface form of the lexicon
+type LexEntry struct {
+	Form     string
+	Analysis BasicMorphemes
+}
+
+// Generated is a surface form generated for a lemma; Morphemes holds the
+// morphemes of the prefix (if any) followed by those of the host
+type Generated struct {
+	Form      string
+	Prefix    string
+	Host      string
+	Morphemes BasicMorphemes
+
+	hostIndex int
+}
+
+// HostMorpheme is the morpheme carrying the lemma, pos and features
+func (g *Generated) HostMorpheme() *Morpheme {
+	return g.Morphemes[g.hostIndex]
+}
+
+// indexLemmas builds the lemma to surface form index used for generation
+func (l *BGULex) indexLemmas() {
+	l.Lemmas = make(map[string][]*LexEntry)
+	for form, analyses := range l.Lex {
+		for _, analysis := range analyses {
+			if len(analysis) == 0 {
+				continue
+			}
+			lemma := analysis[0].Lemma
+			l.Lemmas[lemma] = append(l.Lemmas[lemma], &LexEntry{form, analysis})
+		}
+	}
+	for _, entries := range l.Lemmas {
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].Form < entries[j].Form })
+	}
+	log.Println("Indexed", len(l.Lemmas), "lemmas for generation")
+}
+
+// featurePairs splits a feature string to its name=value pairs
+func featurePairs(featureStr string) map[string]bool {
+	pairs := make(map[string]bool)
+	for _, pair := range strings.Split(featureStr, "|") {
+		if len(pair) > 0 && pair != "_" {
+			pairs[pair] = true
+		}
+	}
+	return pairs
+}
+
+// matches is true if the host of the analysis has the pos (if given) and
+// every one of the feature pairs; multi valued features (gen=F|gen=M)
+// match either value
+func matches(analysis BasicMorphemes, pos string, feats map[string]bool) bool {
+	host := analysis[0]
+	if len(pos) > 0 && host.CPOS != pos && host.POS != pos {
+		return false
+	}
+	hostFeats := featurePairs(host.FeatureStr)
+	for pair := range feats {
+		if !hostFeats[pair] {
+			return false
+		}
+	}
+	return true
+}
+
+// GenerateHosts returns the unprefixed surface forms of lemma with the
+// given pos and features (e.g. "gen=M|num=P"); empty pos or feats match any
+func (l *BGULex) GenerateHosts(lemma, pos, feats string) []*Generated {
+	var generated []*Generated
+	targetFeats := featurePairs(feats)
+	for _, entry := range l.Lemmas[lemma] {
+		if matches(entry.Analysis, pos, targetFeats) {
+			generated = append(generated, &Generated{Form: entry.Form, Host: entry.Form, Morphemes: entry.Analysis})
+		}
+	}
+	return generated
+}
+
+// Generate returns the surface forms of lemma with the given pos and
+// features, followed by the forms combining them with every prefix
+func (l *BGULex) Generate(lemma, pos, feats string) []*Generated {
+	hosts := l.GenerateHosts(lemma, pos, feats)
+	prefixes := make([]string, 0, len(l.Prefixes))
+	for prefix := range l.Prefixes {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	generated := hosts
+	for _, prefix := range prefixes {
+		for _, prefixAnalysis := range l.Prefixes[prefix] {
+			if len(prefixAnalysis) == 0 {
+				continue
+			}
+			for _, host := range hosts {
+				morphs := make(BasicMorphemes, 0, len(prefixAnalysis)+len(host.Morphemes))
+				morphs = append(append(morphs, prefixAnalysis...), host.Morphemes...)
+				generated = append(generated, &Generated{
+					Form:      prefix + host.Host,
+					Prefix:    prefix,
+					Host:      host.Host,
+					Morphemes: morphs,
+					hostIndex: len(prefixAnalysis),
+				})
+			}
+		}
+	}
+	return generated
+}
@@ -0,0 +1,56 @@
+package ma
+
+import (
+	"testing"
+
+	. "yap/nlp/types"
+)
+
+func lexMorph(form, lemma, pos, feats string) *Morpheme {
+	return &Morpheme{Form: form, Lemma: lemma, CPOS: pos, POS: pos, FeatureStr: feats}
+}
+
+func TestGenerate(t *testing.T) {
+	l := &BGULex{
+		Prefixes: map[string][]BasicMorphemes{
+			"ו":  {{lexMorph("ו", "ו", "CONJ", "")}},
+			"וה": {{lexMorph("ו", "ו", "CONJ", ""), lexMorph("ה", "ה", "DEF", "")}},
+		},
+		Lex: map[string][]BasicMorphemes{
+			"ילד":   {{lexMorph("ילד", "ילד", "NN", "gen=M|num=S")}, {lexMorph("ילד", "ילד", "VB", "gen=M|num=S|per=3|tense=PAST")}},
+			"ילדים": {{lexMorph("ילדים", "ילד", "NN", "gen=M|num=P")}},
+			"ילדה":  {{lexMorph("ילדה", "ילדה", "NN", "gen=F|num=S")}, {lexMorph("ילדה", "ילד", "VB", "gen=F|num=S|per=3|tense=PAST")}},
+			"שני":   {{lexMorph("שני", "שניים", "CD", "gen=F|gen=M|num=P")}},
+		},
+	}
+	l.indexLemmas()
+
+	hosts := l.GenerateHosts("ילד", "NN", "num=P")
+	if len(hosts) != 1 || hosts[0].Form != "ילדים" {
+		t.Fatalf("Expected ילדים, got %v", hosts)
+	}
+	if verbs := l.GenerateHosts("ילד", "VB", ""); len(verbs) != 2 || verbs[0].Form != "ילד" || verbs[1].Form != "ילדה" {
+		t.Errorf("Expected verb forms ילד, ילדה, got %v", verbs)
+	}
+	if both := l.GenerateHosts("שניים", "", "gen=M"); len(both) != 1 {
+		t.Errorf("Expected multi valued gender to match, got %v", both)
+	}
+
+	generated := l.Generate("ילד", "NN", "gen=M|num=P")
+	forms := make([]string, len(generated))
+	for i, g := range generated {
+		forms[i] = g.Form
+	}
+	expected := []string{"ילדים", "וילדים", "והילדים"}
+	if len(forms) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, forms)
+	}
+	for i := range expected {
+		if forms[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, forms)
+		}
+	}
+	if host := generated[2].HostMorpheme(); host.Form != "ילדים" || len(generated[2].Morphemes) != 3 {
+		t.Errorf("Expected host ילדים after 2 prefix morphemes, got %v", generated[2].Morphemes)
+	}
+}
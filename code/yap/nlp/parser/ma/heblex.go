@@ -19,6 +19,8 @@ type BGULex struct {
 	Prefixes     map[string][]BasicMorphemes
 
 	Lex map[string][]BasicMorphemes
+	// lemma to surface forms, for generation
+	Lemmas map[string][]*LexEntry
 
 	Files []string
 	Stats *AnalyzeStats
@@ -101,6 +103,7 @@ func (l *BGULex) LoadLex(file string, nnpnofeats bool) {
 	lex.ADD_NNP_NO_FEATS = nnpnofeats
 	l.loadTokens(file, "lexicon")
 	log.Println("Loaded", len(l.Lex), "tokens from lexicon")
+	l.indexLemmas()
 }
 
 func makeMorphWithPOS(input, lemma, POS string) []BasicMorphemes {
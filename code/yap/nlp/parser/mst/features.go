@@ -0,0 +1,177 @@
+package mst
+
+import (
+	"yap/alg/featurevector"
+	nlp "yap/nlp/types"
+	"yap/util"
+
+	"fmt"
+)
+
+// Template is a feature template of the graph-based parser; the features
+// are arrays of word and part of speech enum values, and end with the
+// direction and distance of the arc (or, for siblings, its direction)
+type Template string
+
+func (t Template) Format(value interface{}) string {
+	return fmt.Sprintf("%v", value)
+}
+
+func (t Template) String() string {
+	return string(t)
+}
+
+// The feature templates of an arc h -> m (mcdonald et al. acl '05), over the
+// words (w) and part of speech tags (p) of the head and modifier, of the
+// tokens between them (b) and of those next to them (-1, +1)
+var ArcTemplates = []Template{
+	"hw|hp", "hw", "hp", "mw|mp", "mw", "mp",
+	"hw|hp|mw|mp", "hp|mw|mp", "hw|mw|mp", "hw|hp|mp", "hw|hp|mw", "hw|mw", "hp|mp",
+	"hp|bp|mp",
+	"hp|hp+1|mp-1|mp", "hp-1|hp|mp-1|mp", "hp|hp+1|mp|mp+1", "hp-1|hp|mp|mp+1",
+}
+
+// The feature templates of adjacent modifiers s and m of a head h
+// (mcdonald & pereira eacl '06)
+var SiblingTemplates = []Template{
+	"hp|sp|mp", "sp|mp", "sw|mw", "sw|mp", "sp|mw",
+}
+
+// NumFeatures is the number of feature templates, the arc templates
+// followed by the sibling templates
+var NumFeatures = len(ArcTemplates) + len(SiblingTemplates)
+
+// Formatters returns the feature templates as the formatters of a model
+func Formatters() []util.Format {
+	formatters := make([]util.Format, 0, NumFeatures)
+	for _, template := range ArcTemplates {
+		formatters = append(formatters, template)
+	}
+	for _, template := range SiblingTemplates {
+		formatters = append(formatters, template)
+	}
+	return formatters
+}
+
+const (
+	// ROOT is the word and part of speech of the root node
+	ROOT = -1
+	// NONE is the word and part of speech of the tokens out of the sentence,
+	// and of the missing sibling of the modifiers closest to their head
+	NONE = -2
+)
+
+// sentence holds the words and tags of a sentence by node, node 0 being
+// the root and the tokens 1..n
+type sentence struct {
+	words, pos []int
+}
+
+func newSentence(sent nlp.EnumTaggedSentence) *sentence {
+	tokens := sent.EnumTaggedTokens()
+	s := &sentence{make([]int, len(tokens)+1), make([]int, len(tokens)+1)}
+	s.words[0], s.pos[0] = ROOT, ROOT
+	for i, token := range tokens {
+		s.words[i+1], s.pos[i+1] = token.EToken, token.EPOS
+	}
+	return s
+}
+
+func (s *sentence) size() int {
+	return len(s.words)
+}
+
+func (s *sentence) posAt(node int) int {
+	if node < 0 || node >= len(s.pos) {
+		return NONE
+	}
+	return s.pos[node]
+}
+
+// distance is the direction and bucketed length of an arc, 0 for the arcs
+// of the root
+func distance(h, m int) int {
+	if h == 0 {
+		return 0
+	}
+	d := m - h
+	if d < 0 {
+		d = -d
+	}
+	switch {
+	case d > 10:
+		d = 7
+	case d > 5:
+		d = 6
+	}
+	if m < h {
+		return -d
+	}
+	return d
+}
+
+// arcFeatures returns the features of the arc h -> m
+func (s *sentence) arcFeatures(h, m int) []featurevector.Feature {
+	var (
+		features   = make([]featurevector.Feature, NumFeatures)
+		hw, hp     = s.words[h], s.pos[h]
+		mw, mp     = s.words[m], s.pos[m]
+		d          = distance(h, m)
+		from, to   = h, m
+		between    []interface{}
+		betweenPOS = make(map[int]bool)
+	)
+	features[0] = [3]int{hw, hp, d}
+	features[1] = [2]int{hw, d}
+	features[2] = [2]int{hp, d}
+	features[3] = [3]int{mw, mp, d}
+	features[4] = [2]int{mw, d}
+	features[5] = [2]int{mp, d}
+	features[6] = [5]int{hw, hp, mw, mp, d}
+	features[7] = [4]int{hp, mw, mp, d}
+	features[8] = [4]int{hw, mw, mp, d}
+	features[9] = [4]int{hw, hp, mp, d}
+	features[10] = [4]int{hw, hp, mw, d}
+	features[11] = [3]int{hw, mw, d}
+	features[12] = [3]int{hp, mp, d}
+	if from > to {
+		from, to = to, from
+	}
+	for node := from + 1; node < to; node++ {
+		if !betweenPOS[s.pos[node]] {
+			betweenPOS[s.pos[node]] = true
+			between = append(between, [4]int{hp, s.pos[node], mp, d})
+		}
+	}
+	if len(between) > 0 {
+		features[13] = between
+	}
+	features[14] = [5]int{hp, s.posAt(h + 1), s.posAt(m - 1), mp, d}
+	features[15] = [5]int{s.posAt(h - 1), hp, s.posAt(m - 1), mp, d}
+	features[16] = [5]int{hp, s.posAt(h + 1), mp, s.posAt(m + 1), d}
+	features[17] = [5]int{s.posAt(h - 1), hp, mp, s.posAt(m + 1), d}
+	return features
+}
+
+// siblingFeatures returns the features of m modifying h next after sibling,
+// which is h for the modifier closest to h
+func (s *sentence) siblingFeatures(h, sibling, m int) []featurevector.Feature {
+	var (
+		features = make([]featurevector.Feature, NumFeatures)
+		sw, sp   = NONE, NONE
+		dir      = 1
+	)
+	if sibling != h {
+		sw, sp = s.words[sibling], s.pos[sibling]
+	}
+	if m < h {
+		dir = -1
+	}
+	row := len(ArcTemplates)
+	features[row] = [4]int{s.pos[h], sp, s.pos[m], dir}
+	features[row+1] = [3]int{sp, s.pos[m], dir}
+	features[row+2] = [3]int{sw, s.words[m], dir}
+	features[row+3] = [3]int{sw, s.pos[m], dir}
+	features[row+4] = [3]int{sp, s.words[m], dir}
+	return features
+}
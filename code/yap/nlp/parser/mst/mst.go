@@ -0,0 +1,266 @@
+package mst
+
+// Package mst is a graph-based dependency parser: it scores every possible
+// arc of a sentence, and optionally every pair of adjacent siblings, and
+// decodes the maximum spanning tree (mcdonald et al. hlt/emnlp '05), with
+// Chu-Liu/Edmonds for non-projective trees or Eisner for projective ones.
+//
+// The arc-factored features are scored by a transitionmodel.AvgMatrixSparse,
+// the label of an arc taking the place of the transition, so that the parser
+// is trained by the perceptron.LinearPerceptron as the transition parsers are.
+// The features of a tree are a transition.FeaturesList of its arcs, each
+// labeled arc followed, in a second order parser, by its sibling features.
+
+import (
+	"yap/alg/featurevector"
+	"yap/alg/graph"
+	"yap/alg/perceptron"
+	"yap/alg/transition"
+	transitionmodel "yap/alg/transition/model"
+	dep "yap/nlp/parser/dependency/transition"
+	nlp "yap/nlp/types"
+	"yap/util"
+
+	"fmt"
+	"math"
+)
+
+// SIBLING is the transition scoring the sibling features, which aren't
+// labeled
+const SIBLING = transition.ConstTransition(0)
+
+type Parser struct {
+	Model *transitionmodel.AvgMatrixSparse
+	// ERel are the labels of the arcs; the arcs of the root are labeled
+	// ROOT and the others with the best scoring other label
+	ERel *util.EnumSet
+	// Projective decodes with Eisner instead of Chu-Liu/Edmonds
+	Projective bool
+	// SecondOrder also scores adjacent siblings; it requires Projective
+	SecondOrder bool
+}
+
+var _ perceptron.InstanceDecoder = &Parser{}
+var _ perceptron.EarlyUpdateInstanceDecoder = &Parser{}
+
+func (p *Parser) Name() string {
+	var algorithm string = "Chu-Liu/Edmonds"
+	if p.Projective {
+		algorithm = "Eisner"
+	}
+	if p.SecondOrder {
+		return algorithm + " (second order)"
+	}
+	return algorithm + " (first order)"
+}
+
+// Parse returns the best tree of a sentence
+func (p *Parser) Parse(sent nlp.EnumTaggedSentence) nlp.LabeledDependencyGraph {
+	heads, labels, _ := p.decode(newSentence(sent))
+	return p.graph(sent, heads, labels)
+}
+
+func (p *Parser) Decode(i perceptron.Instance, m perceptron.Model) (perceptron.DecodedInstance, interface{}) {
+	p.setModel(m)
+	sent := i.(nlp.EnumTaggedSentence)
+	s := newSentence(sent)
+	heads, labels, _ := p.decode(s)
+	return &perceptron.Decoded{InstanceVal: i, DecodedVal: p.graph(sent, heads, labels)}, p.features(s, heads, labels)
+}
+
+// DecodeGold returns the gold instance as it is, or nil if its tree has a
+// label the parser doesn't know
+func (p *Parser) DecodeGold(goldInstance perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}) {
+	p.setModel(m)
+	sent := goldInstance.Instance().(nlp.EnumTaggedSentence)
+	heads, labels, ok := p.goldTree(goldInstance.Decoded().(nlp.LabeledDependencyGraph))
+	if !ok {
+		return nil, nil
+	}
+	return goldInstance, p.features(newSentence(sent), heads, labels)
+}
+
+// DecodeEarlyUpdate decodes the best tree of the gold instance's sentence;
+// the update is always on the full trees, so it returns no early update
+func (p *Parser) DecodeEarlyUpdate(goldInstance perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}, interface{}, int, int, float64) {
+	if goldInstance == nil {
+		return nil, nil, nil, 0, 0, 0
+	}
+	p.setModel(m)
+	sent := goldInstance.Instance().(nlp.EnumTaggedSentence)
+	goldHeads, goldLabels, ok := p.goldTree(goldInstance.Decoded().(nlp.LabeledDependencyGraph))
+	if !ok {
+		return nil, nil, nil, 0, 0, 0
+	}
+	s := newSentence(sent)
+	heads, labels, score := p.decode(s)
+	decoded := &perceptron.Decoded{InstanceVal: goldInstance.Instance(), DecodedVal: p.graph(sent, heads, labels)}
+	return decoded, p.features(s, heads, labels), p.features(s, goldHeads, goldLabels), -1, s.size() - 1, score
+}
+
+func (p *Parser) setModel(m perceptron.Model) {
+	model, ok := m.(*transitionmodel.AvgMatrixSparse)
+	if !ok {
+		panic(fmt.Sprintf("Graph-based parser requires an AvgMatrixSparse model, got %T", m))
+	}
+	p.Model = model
+}
+
+func (p *Parser) rootLabel() int {
+	root, exists := p.ERel.IndexOf(nlp.DepRel(nlp.ROOT_LABEL))
+	if !exists {
+		panic("Labels have no " + nlp.ROOT_LABEL)
+	}
+	return root
+}
+
+// decode returns the heads and labels of the nodes of the best tree, and
+// its score
+func (p *Parser) decode(s *sentence) ([]int, []int, float64) {
+	if p.SecondOrder && !p.Projective {
+		panic("Second order graph-based parsing requires projective (Eisner) decoding")
+	}
+	var (
+		n      = s.size()
+		root   = p.rootLabel()
+		scores = make([][]float64, n)
+		labels = make([][]int, n)
+		store  = &featurevector.ArrayStore{}
+	)
+	store.SetTransitions(util.RangeInt(p.ERel.Len()))
+	for h := 0; h < n; h++ {
+		scores[h], labels[h] = make([]float64, n), make([]int, n)
+		for m := 1; m < n; m++ {
+			if h == m {
+				scores[h][m] = math.Inf(-1)
+				continue
+			}
+			store.Clear()
+			p.Model.SetTransitionScores(s.arcFeatures(h, m), store, false)
+			if h == 0 {
+				score, _ := store.Get(root)
+				scores[h][m], labels[h][m] = float64(score), root
+				continue
+			}
+			scores[h][m], labels[h][m] = math.Inf(-1), -1
+			for label := 0; label < p.ERel.Len(); label++ {
+				if score, _ := store.Get(label); label != root && float64(score) > scores[h][m] {
+					scores[h][m], labels[h][m] = float64(score), label
+				}
+			}
+		}
+	}
+	var heads []int
+	switch {
+	case p.SecondOrder:
+		heads = graph.EisnerSecondOrder(scores, func(h, sibling, m int) float64 {
+			return float64(p.Model.TransitionScore(SIBLING, s.siblingFeatures(h, sibling, m)))
+		})
+	case p.Projective:
+		heads = graph.Eisner(scores)
+	default:
+		heads = graph.ChuLiuEdmonds(scores)
+	}
+	treeLabels := make([]int, n)
+	treeLabels[0] = -1
+	var score float64
+	for m := 1; m < n; m++ {
+		treeLabels[m] = labels[heads[m]][m]
+		score += scores[heads[m]][m]
+		if p.SecondOrder {
+			score += float64(p.Model.TransitionScore(SIBLING, s.siblingFeatures(heads[m], previousSibling(heads, m), m)))
+		}
+	}
+	return heads, treeLabels, score
+}
+
+// goldTree returns the heads and labels of the nodes of a gold tree, the
+// arcs of the root labeled ROOT
+func (p *Parser) goldTree(gold nlp.LabeledDependencyGraph) ([]int, []int, bool) {
+	n := gold.NumberOfNodes() + 1
+	heads, labels := make([]int, n), make([]int, n)
+	heads[0], labels[0] = -1, -1
+	root := p.rootLabel()
+	for _, arcID := range gold.GetEdges() {
+		arc := gold.GetLabeledArc(arcID)
+		if arc == nil {
+			continue
+		}
+		m := arc.GetModifier() + 1
+		heads[m] = arc.GetHead() + 1
+		if heads[m] == 0 {
+			labels[m] = root
+			continue
+		}
+		label, exists := p.ERel.IndexOf(arc.GetRelation())
+		if !exists {
+			return nil, nil, false
+		}
+		labels[m] = label
+	}
+	return heads, labels, true
+}
+
+// features returns the features of a tree
+func (p *Parser) features(s *sentence, heads, labels []int) *transition.FeaturesList {
+	// every transition is scored by the features of the list before it
+	last := &transition.FeaturesList{}
+	add := func(features []featurevector.Feature, t transition.Transition) {
+		last.Features = features
+		last = &transition.FeaturesList{Transition: t, Previous: last}
+	}
+	for m := 1; m < len(heads); m++ {
+		add(s.arcFeatures(heads[m], m), transition.ConstTransition(labels[m]))
+		if p.SecondOrder {
+			add(s.siblingFeatures(heads[m], previousSibling(heads, m), m), SIBLING)
+		}
+	}
+	return last
+}
+
+// graph returns the tree as a graph of the nodes of the sentence
+func (p *Parser) graph(sent nlp.EnumTaggedSentence, heads, labels []int) nlp.LabeledDependencyGraph {
+	tokens := sent.EnumTaggedTokens()
+	g := &dep.BasicDepGraph{
+		Nodes: make([]nlp.DepNode, len(tokens)),
+		Arcs:  make([]*dep.BasicDepArc, len(tokens)),
+	}
+	for i, token := range tokens {
+		g.Nodes[i] = &dep.TaggedDepNode{
+			Id:       i,
+			Token:    token.EToken,
+			POS:      token.EPOS,
+			TokenPOS: token.ETPOS,
+			MHost:    token.EMHost,
+			MSuffix:  token.EMSuffix,
+			RawToken: token.Token,
+			RawLemma: token.Lemma,
+			RawPOS:   token.POS,
+			RawFeats: token.Feats,
+		}
+		label := labels[i+1]
+		g.Arcs[i] = &dep.BasicDepArc{
+			Head:        heads[i+1] - 1,
+			Relation:    label,
+			Modifier:    i,
+			RawRelation: p.ERel.ValueOf(label).(nlp.DepRel),
+		}
+	}
+	return g
+}
+
+// previousSibling returns the modifier of the head of m between them closest
+// to m, or the head if there is none
+func previousSibling(heads []int, m int) int {
+	h := heads[m]
+	step := 1
+	if m < h {
+		step = -1
+	}
+	for s := m - step; s != h; s -= step {
+		if heads[s] == h {
+			return s
+		}
+	}
+	return h
+}
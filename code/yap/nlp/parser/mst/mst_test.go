@@ -0,0 +1,108 @@
+package mst
+
+import (
+	"yap/alg/perceptron"
+	"yap/alg/transition"
+	transitionmodel "yap/alg/transition/model"
+	dep "yap/nlp/parser/dependency/transition"
+	nlp "yap/nlp/types"
+	"yap/util"
+
+	"testing"
+)
+
+type word struct {
+	form, pos string
+	head      int
+	label     string
+}
+
+// the training trees, heads numbered from 1 as in CoNLL; the last is
+// non-projective
+var trees = [][]word{
+	{{"the", "DT", 2, "det"}, {"dog", "NN", 3, "subj"}, {"barked", "VB", 0, "ROOT"}},
+	{{"a", "DT", 2, "det"}, {"cat", "NN", 3, "subj"}, {"saw", "VB", 0, "ROOT"}, {"the", "DT", 5, "det"}, {"dog", "NN", 3, "obj"}},
+	{{"dogs", "NN", 2, "subj"}, {"saw", "VB", 0, "ROOT"}, {"cats", "NN", 2, "obj"}, {"today", "RB", 2, "adv"}},
+	{{"a", "DT", 2, "det"}, {"hearing", "NN", 4, "subj"}, {"is", "VB", 0, "ROOT"}, {"scheduled", "VB", 3, "vg"}, {"on", "IN", 2, "nmod"}, {"today", "RB", 4, "adv"}},
+}
+
+func setup(trees [][]word) ([]perceptron.DecodedInstance, *util.EnumSet) {
+	var (
+		eWord, ePOS = util.NewEnumSet(10), util.NewEnumSet(10)
+		eRel        = util.NewEnumSet(10)
+		instances   = make([]perceptron.DecodedInstance, len(trees))
+	)
+	eRel.Add(nlp.DepRel(nlp.ROOT_LABEL))
+	for i, tree := range trees {
+		sent := make(nlp.BasicETaggedSentence, len(tree))
+		g := &dep.BasicDepGraph{Nodes: make([]nlp.DepNode, len(tree)), Arcs: make([]*dep.BasicDepArc, len(tree))}
+		for j, w := range tree {
+			eToken, _ := eWord.Add(w.form)
+			ePOSTag, _ := ePOS.Add(w.pos)
+			label, _ := eRel.Add(nlp.DepRel(w.label))
+			sent[j] = nlp.EnumTaggedToken{TaggedToken: nlp.TaggedToken{Token: w.form, POS: w.pos}, EToken: eToken, EPOS: ePOSTag}
+			g.Nodes[j] = &dep.TaggedDepNode{Id: j, Token: eToken, POS: ePOSTag, RawToken: w.form, RawPOS: w.pos}
+			g.Arcs[j] = &dep.BasicDepArc{Head: w.head - 1, Relation: label, Modifier: j, RawRelation: nlp.DepRel(w.label)}
+		}
+		instances[i] = &perceptron.Decoded{InstanceVal: sent, DecodedVal: g}
+	}
+	return instances, eRel
+}
+
+func train(parser *Parser, instances []perceptron.DecodedInstance) {
+	model := transitionmodel.NewAvgMatrixSparse(NumFeatures, Formatters(), true)
+	trainer := &perceptron.LinearPerceptron{
+		Decoder:     parser,
+		GoldDecoder: parser,
+		Updater:     new(transitionmodel.AveragedModelStrategy),
+		Iterations:  10,
+	}
+	trainer.Init(model)
+	trainer.Train(instances)
+	parser.Model = model
+}
+
+func TestParser(t *testing.T) {
+	for _, parser := range []*Parser{{}, {Projective: true}, {Projective: true, SecondOrder: true}} {
+		instances, eRel := setup(trees)
+		parser.ERel = eRel
+		train(parser, instances)
+		for i, instance := range instances {
+			if parser.Projective && i == len(trees)-1 {
+				continue
+			}
+			parsed := parser.Parse(instance.Instance().(nlp.EnumTaggedSentence))
+			if !parsed.Equal(instance.Decoded().(util.Equaler)) {
+				t.Errorf("%v: tree %d parsed as %v", parser.Name(), i, parsed.(*dep.BasicDepGraph).StringEdges())
+			}
+		}
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	instances, eRel := setup(trees[1:2])
+	parser := &Parser{ERel: eRel, Projective: true, SecondOrder: true}
+	parser.Model = transitionmodel.NewAvgMatrixSparse(NumFeatures, Formatters(), true)
+	_, features := parser.DecodeGold(instances[0], parser.Model)
+	var arcs, siblings int
+	for list := features.(*transition.FeaturesList); list.Previous != nil; list = list.Previous {
+		if list.Previous.Features[len(ArcTemplates)] != nil {
+			siblings++
+		} else {
+			arcs++
+		}
+	}
+	if arcs != 5 || siblings != 5 {
+		t.Errorf("Got %d arcs and %d siblings, expected 5 of each", arcs, siblings)
+	}
+}
+
+func TestPreviousSibling(t *testing.T) {
+	// node 3 heads 1, 2, 4 and 6
+	heads := []int{-1, 3, 3, 0, 3, 4, 3}
+	for m, expected := range map[int]int{1: 2, 2: 3, 4: 3, 6: 4, 5: 4} {
+		if sibling := previousSibling(heads, m); sibling != expected {
+			t.Errorf("Got previous sibling %d of %d, expected %d", sibling, m, expected)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package pseudoproj
+
+// Package pseudoproj makes non-projective trees projective for the parsers
+// building only projective trees, and restores them from their output
+// (nivre & nilsson acl '05): a non-projective arc is lifted to the head of its
+// head until it is projective, encoding the lift in the labels, and the
+// encoded labels of the parser's output tell where to lower the arcs back to.
+//
+// Trees are given by the heads and labels of their nodes, numbered from 1 as
+// in CoNLL: heads[i] and labels[i] are the head (0 for the root) and label of
+// node i+1.
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// UP separates the label of a lifted arc from the label of the head it
+	// was lifted from
+	UP = "↑"
+	// DOWN marks the arcs on the path a lifted arc was lifted along
+	DOWN = "↓"
+)
+
+// Encoding is the information encoded in the labels of the lifted arcs: the
+// label of their original head (Head), the arcs they were lifted along (Path),
+// or both (HeadPath)
+type Encoding byte
+
+const (
+	Head Encoding = 1 << iota
+	Path
+	HeadPath = Head | Path
+)
+
+var encodings = map[string]Encoding{
+	"head":      Head,
+	"path":      Path,
+	"head+path": HeadPath,
+}
+
+// ParseEncoding returns the encoding named head, path or head+path
+func ParseEncoding(name string) (Encoding, error) {
+	if encoding, exists := encodings[name]; exists {
+		return encoding, nil
+	}
+	return 0, fmt.Errorf("unknown pseudo-projective encoding %q, choose head, path or head+path", name)
+}
+
+func (e Encoding) String() string {
+	for name, encoding := range encodings {
+		if encoding == e {
+			return name
+		}
+	}
+	return fmt.Sprintf("Encoding(%d)", byte(e))
+}
+
+// Projectivize lifts the non-projective arcs of a tree, the shortest first,
+// until it is projective, and returns the number of arcs lifted
+func Projectivize(heads []int, labels []string, encoding Encoding) int {
+	original := make([]string, len(labels))
+	copy(original, labels)
+	lifted := make([]bool, len(heads))
+	var numLifted int
+	for {
+		dependent := smallestNonProjective(heads)
+		if dependent < 0 {
+			return numLifted
+		}
+		head := heads[dependent-1]
+		if !lifted[dependent-1] {
+			lifted[dependent-1] = true
+			numLifted++
+			labels[dependent-1] = Base(original[dependent-1]) + UP
+			if encoding&Head != 0 {
+				labels[dependent-1] += Base(original[head-1])
+			}
+		}
+		if encoding&Path != 0 && !strings.HasSuffix(labels[head-1], DOWN) {
+			labels[head-1] += DOWN
+		}
+		heads[dependent-1] = heads[head-1]
+	}
+}
+
+// Deprojectivize lowers the lifted arcs of a tree, top down, to the nodes
+// their labels tell, and restores all labels; arcs whose original head isn't
+// found stay attached to their current head
+func Deprojectivize(heads []int, labels []string, encoding Encoding) {
+	for _, dependent := range topDown(heads) {
+		base, headLabel, isLifted := split(labels[dependent-1])
+		if !isLifted {
+			continue
+		}
+		var target int
+		if encoding&Path != 0 {
+			// the original head is the deepest node of the path
+			target = search(heads, dependent, true, func(node int) bool {
+				return strings.HasSuffix(labels[node-1], DOWN)
+			}, func(node int) bool {
+				return encoding&Head == 0 || Base(labels[node-1]) == headLabel
+			})
+		}
+		if target == 0 && encoding&Head != 0 {
+			target = search(heads, dependent, false, func(int) bool { return true }, func(node int) bool {
+				return Base(labels[node-1]) == headLabel
+			})
+		}
+		if target > 0 {
+			heads[dependent-1] = target
+		}
+		labels[dependent-1] = base
+	}
+	for i, label := range labels {
+		labels[i] = Base(label)
+	}
+}
+
+// Base returns a label without its encoding
+func Base(label string) string {
+	if i := strings.Index(label, UP); i >= 0 {
+		label = label[:i]
+	}
+	return strings.TrimSuffix(label, DOWN)
+}
+
+// split returns the base label and the head label encoded in a label, and
+// whether it is the label of a lifted arc
+func split(label string) (string, string, bool) {
+	label = strings.TrimSuffix(label, DOWN)
+	i := strings.Index(label, UP)
+	if i < 0 {
+		return label, "", false
+	}
+	return label[:i], label[i+len(UP):], true
+}
+
+// Projective tells if a tree has no crossing arcs
+func Projective(heads []int) bool {
+	return smallestNonProjective(heads) < 0
+}
+
+// smallestNonProjective returns the dependent of the shortest non-projective
+// arc, the leftmost of the shortest, or -1 if the tree is projective
+func smallestNonProjective(heads []int) int {
+	smallest, smallestLen := -1, 0
+	for dependent := 1; dependent <= len(heads); dependent++ {
+		head := heads[dependent-1]
+		if head <= 0 || head > len(heads) {
+			continue
+		}
+		from, to := head, dependent
+		if from > to {
+			from, to = to, from
+		}
+		if smallest >= 0 && to-from >= smallestLen {
+			continue
+		}
+		for node := from + 1; node < to; node++ {
+			if !dominates(heads, head, node) {
+				smallest, smallestLen = dependent, to-from
+				break
+			}
+		}
+	}
+	return smallest
+}
+
+// dominates tells if node is in the subtree of head
+func dominates(heads []int, head, node int) bool {
+	for steps := 0; node > 0 && node <= len(heads) && steps <= len(heads); steps++ {
+		if node == head {
+			return true
+		}
+		node = heads[node-1]
+	}
+	return false
+}
+
+// children returns the dependents of a node, left to right
+func children(heads []int, node int) []int {
+	var result []int
+	for dependent, head := range heads {
+		if head == node {
+			result = append(result, dependent+1)
+		}
+	}
+	return result
+}
+
+// topDown returns the nodes breadth first from the root, left to right
+func topDown(heads []int) []int {
+	order := make([]int, 0, len(heads))
+	visited := make([]bool, len(heads)+1)
+	queue := []int{0}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, child := range children(heads, node) {
+			if !visited[child] {
+				visited[child] = true
+				order = append(order, child)
+				queue = append(queue, child)
+			}
+		}
+	}
+	return order
+}
+
+// search returns the first node, breadth first below the head of dependent
+// and out of its subtree, which is target, or the last one if deepest; it
+// only descends to the nodes which follow
+func search(heads []int, dependent int, deepest bool, follow, target func(int) bool) int {
+	head := heads[dependent-1]
+	queue := []int{head}
+	visited := make([]bool, len(heads)+1)
+	var found int
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, child := range children(heads, node) {
+			if child == dependent || visited[child] || !follow(child) {
+				continue
+			}
+			visited[child] = true
+			if target(child) {
+				if !deepest {
+					return child
+				}
+				found = child
+			}
+			queue = append(queue, child)
+		}
+	}
+	return found
+}
@@ -0,0 +1,134 @@
+package pseudoproj
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// A hearing is scheduled on the issue today: "on" modifies "hearing" across
+// "is scheduled"
+var (
+	hearingHeads  = []int{2, 3, 0, 3, 2, 7, 5, 3}
+	hearingLabels = []string{"det", "sbj", "root", "vg", "nmod", "det", "pc", "adv"}
+)
+
+func TestParseEncoding(t *testing.T) {
+	for name, expected := range map[string]Encoding{"head": Head, "path": Path, "head+path": HeadPath} {
+		encoding, err := ParseEncoding(name)
+		if err != nil || encoding != expected {
+			t.Errorf("ParseEncoding(%q) = %v, %v; expected %v", name, encoding, err, expected)
+		}
+		if encoding.String() != name {
+			t.Errorf("Got name %q for %q", encoding.String(), name)
+		}
+	}
+	if _, err := ParseEncoding("lift"); err == nil {
+		t.Error("ParseEncoding of an unknown encoding didn't fail")
+	}
+}
+
+func TestProjectivize(t *testing.T) {
+	expectedLabels := map[Encoding][]string{
+		Head:     {"det", "sbj", "root", "vg", "nmod↑sbj", "det", "pc", "adv"},
+		Path:     {"det", "sbj↓", "root", "vg", "nmod↑", "det", "pc", "adv"},
+		HeadPath: {"det", "sbj↓", "root", "vg", "nmod↑sbj", "det", "pc", "adv"},
+	}
+	for encoding, expected := range expectedLabels {
+		heads, labels := copyTree(hearingHeads, hearingLabels)
+		if Projective(heads) {
+			t.Fatal("Example tree is projective")
+		}
+		if lifted := Projectivize(heads, labels, encoding); lifted != 1 {
+			t.Errorf("%v: lifted %d arcs, expected 1", encoding, lifted)
+		}
+		if !Projective(heads) || heads[4] != 3 {
+			t.Errorf("%v: got heads %v", encoding, heads)
+		}
+		if !reflect.DeepEqual(labels, expected) {
+			t.Errorf("%v: got labels %v, expected %v", encoding, labels, expected)
+		}
+		Deprojectivize(heads, labels, encoding)
+		if !reflect.DeepEqual(heads, hearingHeads) || !reflect.DeepEqual(labels, hearingLabels) {
+			t.Errorf("%v: deprojectivized to %v %v", encoding, heads, labels)
+		}
+	}
+}
+
+func TestDeprojectivizeNotFound(t *testing.T) {
+	heads := []int{2, 0, 2}
+	labels := []string{"det", "root", "nmod↑sbj"}
+	Deprojectivize(heads, labels, Head)
+	if !reflect.DeepEqual(heads, []int{2, 0, 2}) || !reflect.DeepEqual(labels, []string{"det", "root", "nmod"}) {
+		t.Errorf("Got %v %v", heads, labels)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	names := []string{"a", "b", "c", "d", "e", "f"}
+	restored := make(map[Encoding]int)
+	var nonProjective int
+	for i := 0; i < 1000; i++ {
+		// a projective tree with an arc moved to a random head, as the
+		// non-projective trees of treebanks mostly have few crossing arcs
+		n := 3 + r.Intn(12)
+		heads, labels := make([]int, n), make([]string, n)
+		projectiveTree(r, heads, 1, n+1, 0)
+		for j := range labels {
+			labels[j] = names[r.Intn(len(names))]
+		}
+		dependent, head := 1+r.Intn(n), 1+r.Intn(n)
+		if dominates(heads, dependent, head) {
+			continue
+		}
+		heads[dependent-1] = head
+		if Projective(heads) {
+			continue
+		}
+		nonProjective++
+		for _, encoding := range []Encoding{Head, Path, HeadPath} {
+			liftedHeads, liftedLabels := copyTree(heads, labels)
+			Projectivize(liftedHeads, liftedLabels, encoding)
+			if !Projective(liftedHeads) {
+				t.Fatalf("%v: %v is not projective", encoding, liftedHeads)
+			}
+			Deprojectivize(liftedHeads, liftedLabels, encoding)
+			if !reflect.DeepEqual(liftedLabels, labels) {
+				t.Fatalf("%v: labels %v restored as %v", encoding, labels, liftedLabels)
+			}
+			if reflect.DeepEqual(liftedHeads, heads) {
+				restored[encoding]++
+			}
+		}
+	}
+	// the head encoding loses the heads with a label also found closer to the
+	// head the arc was lifted to, the path encoding those with other lifts
+	// branching off their path; both together lose the fewest
+	for encoding, minimum := range map[Encoding]int{Head: nonProjective * 2 / 3, Path: nonProjective * 4 / 5, HeadPath: nonProjective * 9 / 10} {
+		if restored[encoding] < minimum {
+			t.Errorf("%v restored %d of %d trees", encoding, restored[encoding], nonProjective)
+		}
+	}
+	if restored[HeadPath] < restored[Head] || restored[HeadPath] < restored[Path] {
+		t.Errorf("head+path restored fewer trees than head or path: %v", restored)
+	}
+}
+
+// projectiveTree attaches the nodes from..to-1 to parent
+func projectiveTree(r *rand.Rand, heads []int, from, to, parent int) {
+	if from >= to {
+		return
+	}
+	head := from + r.Intn(to-from)
+	heads[head-1] = parent
+	projectiveTree(r, heads, from, head, head)
+	projectiveTree(r, heads, head+1, to, head)
+}
+
+func copyTree(heads []int, labels []string) ([]int, []string) {
+	newHeads, newLabels := make([]int, len(heads)), make([]string, len(labels))
+	copy(newHeads, heads)
+	copy(newLabels, labels)
+	return newHeads, newLabels
+}
@@ -331,9 +331,25 @@ func (s Spellouts) Find(other Spellout) (int, bool) {
 	return 0, false
 }
 
+// TokenRange is the [Start, End) rune offsets of a token in the original
+// input text; the zero value means the offsets are unknown
+type TokenRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+func (r TokenRange) IsSet() bool {
+	return r.End > r.Start
+}
+
+func (r TokenRange) String() string {
+	return fmt.Sprintf("%d:%d", r.Start, r.End)
+}
+
 type Mapping struct {
 	Token    Token
 	Spellout Spellout
+	Range    TokenRange
 }
 
 func (m *Mapping) Equal(other *Mapping) bool {
@@ -383,6 +399,7 @@ type Lattice struct {
 	Spellouts       Spellouts
 	Next            map[int][]int
 	BottomId, TopId int
+	Range           TokenRange
 }
 
 func (l *Lattice) Signature() string {
@@ -816,12 +833,9 @@ func NewRootLattice() Lattice {
 	morphs := make(Morphemes, 1)
 	morphs[0] = NewRootMorpheme()
 	lat := &Lattice{
-		ROOT_TOKEN,
-		morphs,
-		nil,
-		make(map[int][]int),
-		0,
-		0,
+		Token:     ROOT_TOKEN,
+		Morphemes: morphs,
+		Next:      make(map[int][]int),
 	}
 	return *lat
 }
@@ -845,7 +859,7 @@ func (ls LatticeSentence) TaggedSentence() TaggedSentence {
 		for _, m := range sp {
 			res = append(res, EnumTaggedToken{
 				TaggedToken{m.Form, m.Lemma, m.POS},
-				m.EForm, 0, m.EPOS, m.EFCPOS, m.EMHost, m.EMSuffix})
+				m.EForm, 0, m.EPOS, m.EFCPOS, m.EMHost, m.EMSuffix, m.FeatureStr})
 		}
 	}
 	return res
@@ -859,6 +873,17 @@ func (ls LatticeSentence) Tokens() []string {
 	return res
 }
 
+// SetRanges sets the input text offsets of each token's lattice;
+// ranges not matching the sentence's tokens are ignored
+func (ls LatticeSentence) SetRanges(ranges []TokenRange) {
+	if len(ranges) != len(ls) {
+		return
+	}
+	for i := range ls {
+		ls[i].Range = ranges[i]
+	}
+}
+
 func (ls LatticeSentence) Equal(otherEq util.Equaler) bool {
 	otherSent := otherEq.(Sentence)
 	if len(otherSent.Tokens()) != len(ls) {
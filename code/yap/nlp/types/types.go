@@ -45,6 +45,8 @@ type TaggedToken struct {
 type EnumTaggedToken struct {
 	TaggedToken
 	EToken, ELemma, EPOS, ETPOS, EMHost, EMSuffix int
+	// Feats keeps the feature string if its host or suffix enum is unknown
+	Feats string
 }
 
 type Sentence interface {
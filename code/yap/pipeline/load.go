@@ -0,0 +1,399 @@
+package pipeline
+
+import (
+	"yap/alg/search"
+	"yap/alg/transition"
+	transitionmodel "yap/alg/transition/model"
+	"yap/app"
+	dep "yap/nlp/parser/dependency/transition"
+	"yap/nlp/parser/disambig"
+	"yap/nlp/parser/joint"
+	"yap/nlp/parser/ma"
+	nlp "yap/nlp/types"
+	"yap/util"
+	"yap/util/conf"
+
+	"fmt"
+	"log"
+)
+
+// Enums are the enumerations of a stage's model
+type Enums struct {
+	EWord, EPOS, EWPOS, EMHost, EMSuffix *util.EnumSet
+	EMorphProp, ETrans, ETokens, ERel    *util.EnumSet
+}
+
+// close stops the feature enumerations from growing; the transitions are
+// left open since their values identify the lattice paths chosen by the
+// disambiguator
+func (e *Enums) close() {
+	for _, enum := range []*util.EnumSet{e.EWord, e.EPOS, e.EMHost, e.EMSuffix, e.EMorphProp, e.ETokens} {
+		if enum != nil {
+			enum.Close(util.UNKNOWN)
+		}
+	}
+	if e.EWPOS != nil {
+		e.EWPOS.Close([2]string{util.UNKNOWN, util.UNKNOWN})
+	}
+}
+
+// transitions are the transition values set up by the app package's
+// Setup*Enum functions
+type transitions struct {
+	SH, RE, PR, LA, RA, POP, MD transition.Transition
+}
+
+func locate(file string, dirs []string) (string, error) {
+	location, found := util.LocateFile(file, dirs)
+	if !found {
+		return "", fmt.Errorf("file not found: %v", file)
+	}
+	return location, nil
+}
+
+// readModel reads a model written by app.WriteModel, refusing models trained
+// with other features, labels or MD param func
+func readModel(file, featuresFile, labelsFile, paramFunc string) (*transitionmodel.AvgMatrixSparse, *app.Serialization, error) {
+	location, err := locate(file, app.DEFAULT_MODEL_DIRS)
+	if err != nil {
+		return nil, nil, err
+	}
+	serialization, err := app.DecodeModel(location)
+	if err != nil {
+		return nil, nil, err
+	}
+	if featuresFile, err = locate(featuresFile, app.DEFAULT_CONF_DIRS); err != nil {
+		return nil, nil, err
+	}
+	if len(labelsFile) > 0 {
+		if labelsFile, err = locate(labelsFile, app.DEFAULT_CONF_DIRS); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := serialization.Header.Check(featuresFile, labelsFile, paramFunc); err != nil {
+		return nil, nil, fmt.Errorf("model %v: %v", location, err)
+	}
+	if header := serialization.Header; header != nil && len(header.Labels) > 0 {
+		return nil, nil, fmt.Errorf("model %v was trained with -pseudoproj, parse with dep or joint", location)
+	}
+	if header := serialization.Header; header != nil && header.Flags["decoder"] == "mst" {
+		return nil, nil, fmt.Errorf("model %v was trained with -decoder mst, parse with dep", location)
+	}
+	// the pipeline parses with the arc eager system
+	if header := serialization.Header; header != nil && len(header.Flags["a"]) > 0 && header.Flags["a"] != "eager" {
+		return nil, nil, fmt.Errorf("model %v was trained with -a %v, parse with dep or joint", location, header.Flags["a"])
+	}
+	model := &transitionmodel.AvgMatrixSparse{}
+	model.Deserialize(serialization.WeightModel)
+	log.Println("Loaded model", location)
+	return model, serialization, nil
+}
+
+func readFeatures(file string) (*transition.FeatureSetup, error) {
+	location, err := locate(file, app.DEFAULT_CONF_DIRS)
+	if err != nil {
+		return nil, err
+	}
+	return transition.LoadFeatureConfFile(location)
+}
+
+func readLabels(file string) ([]string, error) {
+	location, err := locate(file, app.DEFAULT_CONF_DIRS)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := conf.ReadFile(location)
+	if err != nil {
+		return nil, err
+	}
+	return labels.Values, nil
+}
+
+// relationEnum mirrors app.SetupRelationEnum
+func relationEnum(labels []string) *util.EnumSet {
+	eRel := util.NewEnumSet(len(labels) + 1)
+	eRel.Add(nlp.DepRel(nlp.ROOT_LABEL))
+	for _, label := range labels {
+		eRel.Add(nlp.DepRel(label))
+	}
+	eRel.Frozen = true
+	return eRel
+}
+
+// arcTransEnum mirrors app.SetupTransEnum (first is "IDLE") and
+// app.SetupMorphTransEnum (first is "NO", followed by the morphological
+// transitions)
+func arcTransEnum(labels []string, first string, morph bool) (*util.EnumSet, *transitions) {
+	eTrans := util.NewEnumSet((len(labels)+1)*2 + 2 + app.APPROX_MORPH_TRANSITIONS)
+	t := &transitions{}
+	eTrans.Add(first)
+	iSH, _ := eTrans.Add("SH")
+	iRE, _ := eTrans.Add("RE")
+	eTrans.Add("AL")
+	eTrans.Add("AR")
+	iPR, _ := eTrans.Add("PR")
+	t.SH, t.RE, t.PR = transition.ConstTransition(iSH), transition.ConstTransition(iRE), transition.ConstTransition(iPR)
+	t.LA = transition.ConstTransition(iPR + 1)
+	eTrans.Add("LA-" + string(nlp.ROOT_LABEL))
+	for _, label := range labels {
+		eTrans.Add("LA-" + label)
+	}
+	t.RA = transition.ConstTransition(eTrans.Len())
+	eTrans.Add("RA-" + string(nlp.ROOT_LABEL))
+	for _, label := range labels {
+		eTrans.Add("RA-" + label)
+	}
+	if morph {
+		iPOP, _ := eTrans.Add("POP")
+		t.POP = &transition.TypedTransition{T: 'P', V: iPOP}
+		t.MD = transition.ConstTransition(eTrans.Len())
+	}
+	return eTrans, t
+}
+
+// mdPOP is the POP transition of app.SetupMDEnum
+func mdPOP() transition.Transition {
+	eTrans := util.NewEnumSet(2)
+	eTrans.Add("IDLE")
+	iPOP, _ := eTrans.Add("POP")
+	return &transition.TypedTransition{T: 'P', V: iPOP}
+}
+
+func newExtractor(setup *transition.FeatureSetup, groups []byte, enums *Enums, pop transition.Transition) *transition.GenericExtractor {
+	extractor := &transition.GenericExtractor{
+		EFeatures:  util.NewEnumSet(setup.NumFeatures()),
+		EWord:      enums.EWord,
+		EPOS:       enums.EPOS,
+		EWPOS:      enums.EWPOS,
+		ERel:       enums.ERel,
+		EMHost:     enums.EMHost,
+		EMSuffix:   enums.EMSuffix,
+		EMorphProp: enums.EMorphProp,
+		EToken:     enums.ETokens,
+		POPTrans:   pop,
+	}
+	extractor.InitTypes(groups)
+	extractor.LoadFeatureSetup(setup)
+	return extractor
+}
+
+func loadLexicon(options *Options) (*ma.BGULex, error) {
+	prefixLocation, err := locate(options.PrefixFile, app.HEB_MA_DEFAULT_DATA_DIRS)
+	if err != nil {
+		return nil, err
+	}
+	lexiconLocation, err := locate(options.LexiconFile, app.HEB_MA_DEFAULT_DATA_DIRS)
+	if err != nil {
+		return nil, err
+	}
+	lex := &ma.BGULex{MAType: "spmrl"}
+	lex.LoadPrefixes(prefixLocation)
+	lex.LoadLex(lexiconLocation, options.NNPNoFeats)
+	lex.AlwaysNNP = options.AlwaysNNP
+	return lex, nil
+}
+
+func loadMD(options *Options) (*stage, error) {
+	paramFunc, exists := nlp.MDParams[options.MDParamFunc]
+	if !exists {
+		return nil, fmt.Errorf("MD param func %v doesn't exist", options.MDParamFunc)
+	}
+	featureSetup, err := readFeatures(options.MDFeaturesFile)
+	if err != nil {
+		return nil, err
+	}
+	model, serialization, err := readModel(options.MDModelFile, options.MDFeaturesFile, "", options.MDParamFunc)
+	if err != nil {
+		return nil, err
+	}
+	enums := &Enums{
+		EWord:      serialization.EWord,
+		EPOS:       serialization.EPOS,
+		EWPOS:      serialization.EWPOS,
+		EMHost:     serialization.EMHost,
+		EMSuffix:   serialization.EMSuffix,
+		EMorphProp: serialization.EMorphProp,
+		ETrans:     serialization.ETrans,
+		ETokens:    serialization.ETokens,
+	}
+	pop := mdPOP()
+	mdTrans := &disambig.MDTrans{
+		ParamFunc:   paramFunc,
+		UsePOP:      options.UsePOP,
+		POP:         pop,
+		Transitions: enums.ETrans,
+	}
+	mdTrans.AddDefaultOracle()
+	if options.FrozenEnums {
+		enums.close()
+	}
+	beam := &search.Beam{
+		TransFunc:     mdTrans,
+		FeatExtractor: newExtractor(featureSetup, []byte("MPL"), enums, pop),
+		Base: &disambig.MDConfig{
+			ETokens:     enums.ETokens,
+			POP:         pop,
+			Transitions: enums.ETrans,
+			ParamFunc:   paramFunc,
+		},
+		Model:                model,
+		Size:                 options.BeamSize,
+		ConcurrentExec:       options.ConcurrentBeam,
+		Transitions:          enums.ETrans,
+		EstimatedTransitions: 1000,
+		ShortTempAgenda:      true,
+	}
+	return &stage{enums, beam}, nil
+}
+
+func loadDep(options *Options) (*stage, error) {
+	featureSetup, err := readFeatures(options.DepFeaturesFile)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := readLabels(options.DepLabelsFile)
+	if err != nil {
+		return nil, err
+	}
+	model, serialization, err := readModel(options.DepModelFile, options.DepFeaturesFile, options.DepLabelsFile, "")
+	if err != nil {
+		return nil, err
+	}
+	eTrans, t := arcTransEnum(labels, "IDLE", false)
+	enums := &Enums{
+		EWord:      serialization.EWord,
+		EPOS:       serialization.EPOS,
+		EWPOS:      serialization.EWPOS,
+		EMHost:     serialization.EMHost,
+		EMSuffix:   serialization.EMSuffix,
+		EMorphProp: util.NewEnumSet(130),
+		ETrans:     eTrans,
+		ERel:       relationEnum(labels),
+	}
+	arcSystem := &dep.ArcEager{
+		ArcStandard: dep.ArcStandard{
+			SHIFT:       t.SH.Value(),
+			LEFT:        t.LA.Value(),
+			RIGHT:       t.RA.Value(),
+			Relations:   enums.ERel,
+			Transitions: enums.ETrans,
+		},
+		REDUCE:  t.RE.Value(),
+		POPROOT: t.PR.Value(),
+	}
+	arcSystem.AddDefaultOracle()
+	if options.FrozenEnums {
+		enums.close()
+	}
+	beam := &search.Beam{
+		TransFunc:     arcSystem,
+		FeatExtractor: newExtractor(featureSetup, []byte("A"), enums, t.POP),
+		Base: &dep.SimpleConfiguration{
+			EWord:    enums.EWord,
+			EPOS:     enums.EPOS,
+			EWPOS:    enums.EWPOS,
+			EMHost:   enums.EMHost,
+			EMSuffix: enums.EMSuffix,
+			ERel:     enums.ERel,
+			ETrans:   enums.ETrans,
+		},
+		Model:                model,
+		Size:                 options.BeamSize,
+		ConcurrentExec:       options.ConcurrentBeam,
+		ShortTempAgenda:      true,
+		EstimatedTransitions: enums.ERel.Len()*2 + 2,
+		ScoredStoreDense:     true,
+	}
+	return &stage{enums, beam}, nil
+}
+
+func loadJoint(options *Options) (*stage, error) {
+	paramFunc, exists := nlp.MDParams[options.MDParamFunc]
+	if !exists {
+		return nil, fmt.Errorf("MD param func %v doesn't exist", options.MDParamFunc)
+	}
+	featureSetup, err := readFeatures(options.JointFeaturesFile)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := readLabels(options.JointLabelsFile)
+	if err != nil {
+		return nil, err
+	}
+	model, serialization, err := readModel(options.JointModelFile, options.JointFeaturesFile, options.JointLabelsFile, options.MDParamFunc)
+	if err != nil {
+		return nil, err
+	}
+	_, t := arcTransEnum(labels, "NO", true)
+	enums := &Enums{
+		EWord:      serialization.EWord,
+		EPOS:       serialization.EPOS,
+		EWPOS:      serialization.EWPOS,
+		EMHost:     serialization.EMHost,
+		EMSuffix:   serialization.EMSuffix,
+		EMorphProp: serialization.EMorphProp,
+		ETrans:     serialization.ETrans,
+		ETokens:    serialization.ETokens,
+		ERel:       relationEnum(labels),
+	}
+	arcSystem := &dep.ArcEager{
+		ArcStandard: dep.ArcStandard{
+			SHIFT:       t.SH.Value(),
+			LEFT:        t.LA.Value(),
+			RIGHT:       t.RA.Value(),
+			Relations:   enums.ERel,
+			Transitions: enums.ETrans,
+		},
+		REDUCE:  t.RE.Value(),
+		POPROOT: t.PR.Value(),
+	}
+	arcSystem.AddDefaultOracle()
+	mdTrans := &disambig.MDTrans{
+		ParamFunc:   paramFunc,
+		UsePOP:      options.UsePOP,
+		POP:         t.POP,
+		Transitions: enums.ETrans,
+	}
+	mdTrans.AddDefaultOracle()
+	jointTrans := &joint.JointTrans{
+		MDTrans:       mdTrans,
+		ArcSys:        arcSystem,
+		Transitions:   enums.ETrans,
+		JointStrategy: options.JointStrategy,
+		MDTransition:  t.MD,
+	}
+	jointTrans.AddDefaultOracle()
+	jointTrans.Oracle().(*joint.JointOracle).OracleStrategy = options.OracleStrategy
+	if options.FrozenEnums {
+		enums.close()
+	}
+	beam := &search.Beam{
+		TransFunc:     jointTrans,
+		FeatExtractor: newExtractor(featureSetup, []byte("MPLA"), enums, t.POP),
+		Base: &joint.JointConfig{
+			SimpleConfiguration: dep.SimpleConfiguration{
+				EWord:    enums.EWord,
+				EPOS:     enums.EPOS,
+				EWPOS:    enums.EWPOS,
+				EMHost:   enums.EMHost,
+				EMSuffix: enums.EMSuffix,
+				ERel:     enums.ERel,
+				ETrans:   enums.ETrans,
+			},
+			MDConfig: disambig.MDConfig{
+				ETokens:     enums.ETokens,
+				POP:         t.POP,
+				Transitions: enums.ETrans,
+				ParamFunc:   paramFunc,
+			},
+			MDTrans: t.MD,
+		},
+		Model:                model,
+		Size:                 options.BeamSize,
+		ConcurrentExec:       options.ConcurrentBeam,
+		Transitions:          enums.ETrans,
+		EstimatedTransitions: 1000,
+		ShortTempAgenda:      true,
+	}
+	return &stage{enums, beam}, nil
+}
@@ -0,0 +1,275 @@
+package pipeline
+
+// Package pipeline embeds yap's Hebrew processing stages in Go programs.
+// Unlike the yap commands, which run on the app package's globals, every
+// Pipeline owns its lexicon, enumerations, models and feature extractors,
+// so several pipelines (e.g. with different models) can live in one process.
+
+import (
+	"yap/alg/search"
+	"yap/app"
+	"yap/nlp/format/conll"
+	"yap/nlp/format/lattice"
+	"yap/nlp/format/mapping"
+	"yap/nlp/format/tokenize"
+	"yap/nlp/parser/disambig"
+	"yap/nlp/parser/joint"
+	"yap/nlp/parser/ma"
+	nlp "yap/nlp/types"
+
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Options selects the stages of a Pipeline and their files; a stage whose
+// model file is empty isn't loaded. Relative file names are also looked
+// up in yap's data and conf directories.
+type Options struct {
+	// Morphological analysis
+	PrefixFile, LexiconFile string
+	AlwaysNNP, NNPNoFeats   bool
+
+	// Morphological disambiguation
+	MDModelFile, MDFeaturesFile string
+	MDParamFunc                 string
+
+	// Dependency parsing of disambiguated lattices
+	DepModelFile, DepFeaturesFile, DepLabelsFile string
+
+	// Joint morpho-syntactic parsing
+	JointModelFile, JointFeaturesFile, JointLabelsFile string
+	JointStrategy, OracleStrategy                      string
+
+	BeamSize       int
+	ConcurrentBeam bool
+	// FrozenEnums keeps the enumerations from growing with the input, see
+	// util.EnumSet.Close
+	FrozenEnums bool
+
+	// UsePOP and IgnoreLemma set disambig.UsePOP and lattice.IGNORE_LEMMA,
+	// which are process wide: every pipeline of a process must agree on
+	// them, and New refuses options that differ from the first pipeline's
+	UsePOP, IgnoreLemma bool
+}
+
+// DefaultOptions returns the options of the yap api server
+func DefaultOptions() *Options {
+	return &Options{
+		PrefixFile:        "bgupreflex_withdef.utf8.hr",
+		LexiconFile:       "bgulex.utf8.hr",
+		MDModelFile:       "md_model_temp_i9.b64",
+		MDFeaturesFile:    "standalone.md.yaml",
+		MDParamFunc:       "Funcs_Main_POS_Both_Prop",
+		DepModelFile:      "dep_zeager_model_temp_i18.b64",
+		DepFeaturesFile:   "zhangnivre2011.yaml",
+		DepLabelsFile:     "hebtb.labels.conf",
+		JointModelFile:    "joint_arc_zeager_model_temp_i33.b64",
+		JointFeaturesFile: "jointzeager.yaml",
+		JointLabelsFile:   "hebtb.labels.conf",
+		JointStrategy:     "ArcGreedy",
+		OracleStrategy:    "ArcGreedy",
+		BeamSize:          64,
+		ConcurrentBeam:    true,
+		FrozenEnums:       true,
+		UsePOP:            true,
+		IgnoreLemma:       true,
+	}
+}
+
+// Pipeline runs the stages loaded from its Options; its methods may be
+// called concurrently
+type Pipeline struct {
+	Options Options
+
+	lex             *ma.BGULex
+	md, dep, jointP *stage
+}
+
+var (
+	ErrNotLoaded          = errors.New("stage not loaded")
+	ErrConflictingOptions = errors.New("UsePOP and IgnoreLemma differ from those of the process' first pipeline")
+)
+
+// processOptions are the process wide options set by the first pipeline
+var (
+	processMutex   sync.Mutex
+	processOptions *Options
+)
+
+// setProcessOptions sets the process wide options, unless a pipeline has
+// set others; it tells if they were set by this call
+func setProcessOptions(options *Options) (bool, error) {
+	processMutex.Lock()
+	defer processMutex.Unlock()
+	if processOptions != nil {
+		if processOptions.UsePOP != options.UsePOP || processOptions.IgnoreLemma != options.IgnoreLemma {
+			return false, fmt.Errorf("%v: UsePOP %v IgnoreLemma %v", ErrConflictingOptions, processOptions.UsePOP, processOptions.IgnoreLemma)
+		}
+		return false, nil
+	}
+	processOptions = &Options{UsePOP: options.UsePOP, IgnoreLemma: options.IgnoreLemma}
+	disambig.UsePOP = options.UsePOP
+	disambig.SwitchFormLemma = !options.IgnoreLemma
+	disambig.LEMMAS = !options.IgnoreLemma
+	lattice.IGNORE_LEMMA = options.IgnoreLemma
+	return true, nil
+}
+
+// unsetProcessOptions lets the next pipeline set the process wide options,
+// when the first failed to load
+func unsetProcessOptions() {
+	processMutex.Lock()
+	defer processMutex.Unlock()
+	processOptions = nil
+}
+
+// New loads the stages selected by options
+func New(options *Options) (p *Pipeline, err error) {
+	set, err := setProcessOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil && set {
+			unsetProcessOptions()
+		}
+	}()
+	defer recoverError(&err)
+	p = &Pipeline{Options: *options}
+	nlp.InitOpenParamFamily("HEBTB")
+	if len(options.LexiconFile) > 0 {
+		if p.lex, err = loadLexicon(options); err != nil {
+			return nil, err
+		}
+	}
+	if len(options.MDModelFile) > 0 {
+		if p.md, err = loadMD(options); err != nil {
+			return nil, err
+		}
+	}
+	if len(options.DepModelFile) > 0 {
+		if p.dep, err = loadDep(options); err != nil {
+			return nil, err
+		}
+	}
+	if len(options.JointModelFile) > 0 {
+		if p.jointP, err = loadJoint(options); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		log.Println("Recovered panic in pipeline:", r)
+		if e, ok := r.(error); ok {
+			*err = e
+		} else {
+			*err = fmt.Errorf("%v", r)
+		}
+	}
+}
+
+// Analyze tokenizes running text and returns the ambiguous lattice of each
+// sentence, ready for Disambiguate and JointParse; every lattice keeps the
+// offsets of its token in text
+func (p *Pipeline) Analyze(text string) (lattices []lattice.Lattice, err error) {
+	if p.lex == nil {
+		return nil, fmt.Errorf("morphological analysis: %v", ErrNotLoaded)
+	}
+	defer recoverError(&err)
+	sents, ranges := tokenize.SentencesWithRanges(text)
+	// the analyzer keeps its statistics in the lexicon; analyze with a copy
+	lex := *p.lex
+	lex.Stats = new(ma.AnalyzeStats)
+	lex.Stats.Init()
+	analyzed := make([]nlp.LatticeSentence, len(sents))
+	for i, sent := range sents {
+		analyzed[i], _ = lex.Analyze(sent.Tokens())
+		analyzed[i].SetRanges(ranges[i])
+	}
+	return lattice.Reparse(lattice.Sentence2LatticeCorpus(analyzed, nil))
+}
+
+// Disambiguate returns the morphological disambiguation of ambiguous lattices
+func (p *Pipeline) Disambiguate(lattices []lattice.Lattice) (mappings []nlp.Mappings, err error) {
+	if p.md == nil {
+		return nil, fmt.Errorf("morphological disambiguation: %v", ErrNotLoaded)
+	}
+	defer recoverError(&err)
+	parsed := p.md.parse(p.md.instances(lattices))
+	mappings = make([]nlp.Mappings, len(parsed))
+	for i, config := range parsed {
+		mappings[i] = config.(*disambig.MDConfig).Mappings
+	}
+	return mappings, nil
+}
+
+// Parse returns the dependency trees of disambiguated lattices, such as
+// those of DisambiguatedLattices
+func (p *Pipeline) Parse(lattices []lattice.Lattice) (trees []conll.Sentence, err error) {
+	if p.dep == nil {
+		return nil, fmt.Errorf("dependency parsing: %v", ErrNotLoaded)
+	}
+	defer recoverError(&err)
+	sents := p.dep.instances(lattices)
+	for i, sent := range sents {
+		sents[i] = sent.(nlp.LatticeSentence).TaggedSentence()
+	}
+	graphs := conll.Graph2ConllCorpus(p.dep.parse(sents), p.dep.enums.EMHost, p.dep.enums.EMSuffix)
+	trees = make([]conll.Sentence, len(graphs))
+	for i, graph := range graphs {
+		trees[i] = graph.(conll.Sentence)
+	}
+	return trees, nil
+}
+
+// JointParse returns the joint morphological disambiguation and dependency
+// parse of ambiguous lattices; the disambiguation of a graph is its
+// GetMappings()
+func (p *Pipeline) JointParse(lattices []lattice.Lattice) (graphs []nlp.MorphDependencyGraph, err error) {
+	if p.jointP == nil {
+		return nil, fmt.Errorf("joint parsing: %v", ErrNotLoaded)
+	}
+	defer recoverError(&err)
+	parsed := p.jointP.parse(p.jointP.instances(lattices))
+	graphs = make([]nlp.MorphDependencyGraph, len(parsed))
+	for i, graph := range parsed {
+		graphs[i] = graph.(*joint.JointConfig)
+	}
+	return graphs, nil
+}
+
+// DisambiguatedLattices converts the output of Disambiguate to the input
+// of Parse
+func DisambiguatedLattices(mappings []nlp.Mappings) ([]lattice.Lattice, error) {
+	instances := make([]interface{}, len(mappings))
+	for i, m := range mappings {
+		instances[i] = &disambig.MDConfig{Mappings: m}
+	}
+	buf := new(bytes.Buffer)
+	mapping.Write(buf, instances)
+	return lattice.Read(buf, 0)
+}
+
+// stage holds the state a parsing stage shares between calls
+type stage struct {
+	enums *Enums
+	beam  *search.Beam
+}
+
+// instances converts lattices to parser input with the stage's enumerations
+func (s *stage) instances(lattices []lattice.Lattice) []interface{} {
+	e := s.enums
+	return lattice.Lattice2SentenceCorpus(lattices, e.EWord, e.EPOS, e.EWPOS, e.EMorphProp, e.EMHost, e.EMSuffix)
+}
+
+// parse parses with a copy of the stage's beam, which keeps per parse state
+func (s *stage) parse(instances []interface{}) []interface{} {
+	beam := *s.beam
+	return app.Parse(instances, &beam)
+}
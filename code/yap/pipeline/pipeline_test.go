@@ -0,0 +1,99 @@
+package pipeline
+
+import (
+	"yap/app"
+	"yap/nlp/format/lattice"
+	"yap/nlp/parser/disambig"
+
+	"testing"
+)
+
+var testLabels = []string{"subj", "obj", "prepmod"}
+
+func TestNotLoaded(t *testing.T) {
+	p, err := New(&Options{})
+	if err != nil {
+		t.Fatalf("New with no stages failed: %v", err)
+	}
+	if _, err := p.Analyze("שלום"); err == nil {
+		t.Error("Analyze without a lexicon didn't fail")
+	}
+	if _, err := p.Disambiguate(nil); err == nil {
+		t.Error("Disambiguate without a model didn't fail")
+	}
+	if _, err := p.Parse(nil); err == nil {
+		t.Error("Parse without a model didn't fail")
+	}
+	if _, err := p.JointParse(nil); err == nil {
+		t.Error("JointParse without a model didn't fail")
+	}
+}
+
+func TestMissingFile(t *testing.T) {
+	options := &Options{MDModelFile: "no_such_model.b64", MDFeaturesFile: "no_such_features.yaml", MDParamFunc: "Funcs_Main_POS_Both_Prop"}
+	if _, err := New(options); err == nil {
+		t.Error("New with missing files didn't fail")
+	}
+}
+
+func TestIndependentPipelines(t *testing.T) {
+	first, err := New(&Options{BeamSize: 4, UsePOP: true, IgnoreLemma: true})
+	if err != nil {
+		// an earlier test's pipeline set the process wide options
+		first, err = New(&Options{BeamSize: 4, UsePOP: processOptions.UsePOP, IgnoreLemma: processOptions.IgnoreLemma})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+	}
+	usePOP, ignoreLemma := first.Options.UsePOP, first.Options.IgnoreLemma
+	options := &Options{BeamSize: 8, FrozenEnums: true, UsePOP: usePOP, IgnoreLemma: ignoreLemma}
+	second, err := New(options)
+	if err != nil {
+		t.Fatalf("New with the same process wide options failed: %v", err)
+	}
+	options.BeamSize = 16
+	if first.Options.BeamSize != 4 || first.Options.FrozenEnums || second.Options.BeamSize != 8 || !second.Options.FrozenEnums {
+		t.Errorf("Got options %+v and %+v, expected beams 4 and 8, the second frozen", first.Options, second.Options)
+	}
+	for _, conflicting := range []*Options{
+		{UsePOP: !usePOP, IgnoreLemma: ignoreLemma},
+		{UsePOP: usePOP, IgnoreLemma: !ignoreLemma},
+	} {
+		if _, err := New(conflicting); err == nil {
+			t.Errorf("New with conflicting options %+v didn't fail", conflicting)
+		}
+	}
+	if disambig.UsePOP != usePOP || lattice.IGNORE_LEMMA != ignoreLemma || disambig.LEMMAS == ignoreLemma {
+		t.Errorf("Got process wide UsePOP %v IgnoreLemma %v, expected %v and %v", disambig.UsePOP, lattice.IGNORE_LEMMA, usePOP, ignoreLemma)
+	}
+}
+
+func TestArcTransEnum(t *testing.T) {
+	eTrans, trans := arcTransEnum(testLabels, "NO", true)
+	app.SetupMorphTransEnum(testLabels)
+	if eTrans.Len() != app.ETrans.Len() {
+		t.Fatalf("Got %v transitions, expected %v", eTrans.Len(), app.ETrans.Len())
+	}
+	for i := 0; i < eTrans.Len(); i++ {
+		if eTrans.ValueOf(i) != app.ETrans.ValueOf(i) {
+			t.Errorf("Got transition %v at %v, expected %v", eTrans.ValueOf(i), i, app.ETrans.ValueOf(i))
+		}
+	}
+	for name, pair := range map[string][2]int{
+		"SH":  {trans.SH.Value(), app.SH.Value()},
+		"RE":  {trans.RE.Value(), app.RE.Value()},
+		"PR":  {trans.PR.Value(), app.PR.Value()},
+		"LA":  {trans.LA.Value(), app.LA.Value()},
+		"RA":  {trans.RA.Value(), app.RA.Value()},
+		"POP": {trans.POP.Value(), app.POP.Value()},
+		"MD":  {trans.MD.Value(), app.MD.Value()},
+	} {
+		if pair[0] != pair[1] {
+			t.Errorf("Got %v = %v, expected %v", name, pair[0], pair[1])
+		}
+	}
+	eRel := relationEnum(testLabels)
+	if eRel.Len() != len(testLabels)+1 || !eRel.Frozen {
+		t.Errorf("Got relations %v, expected ROOT and %v frozen", eRel.Index, testLabels)
+	}
+}
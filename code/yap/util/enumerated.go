@@ -7,6 +7,10 @@ import (
 	"sync"
 )
 
+// UNKNOWN is the value reserved by Close for the values a closed set
+// hasn't seen
+const UNKNOWN = "_UNK_"
+
 func init() {
 	gob.Register([2]string{})
 }
@@ -16,6 +20,14 @@ type EnumSet struct {
 	Enum   map[interface{}]int
 	Index  []interface{}
 	Frozen bool
+
+	closed  bool
+	unknown int
+
+	// base is the set an overlay holds the values of, and offset the index
+	// of the first value added to the overlay
+	base   *EnumSet
+	offset int
 }
 
 func (e *EnumSet) RebuildIndex() {
@@ -23,27 +35,96 @@ func (e *EnumSet) RebuildIndex() {
 	defer e.mu.Unlock()
 	e.Index = make([]interface{}, len(e.Enum))
 	for k, v := range e.Enum {
-		e.Index[v] = k
+		e.Index[v-e.offset] = k
 	}
 }
 
+// Overlay returns a set holding the values of e, to which Add adds the
+// values e doesn't hold instead of adding them to e; Reset drops them. The
+// overlays of a set don't see each other's values, and e must not grow
+// while it has overlays.
+func (e *EnumSet) Overlay() *EnumSet {
+	return &EnumSet{
+		Enum:   make(map[interface{}]int),
+		base:   e,
+		offset: e.Len(),
+	}
+}
+
+// Reset drops the values added to an overlay, leaving those of its base
+func (e *EnumSet) Reset() {
+	if e.base == nil {
+		panic("Cannot reset an enum set that isn't an overlay")
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.Enum = make(map[interface{}]int, len(e.Enum))
+	e.Index = e.Index[:0]
+}
+
 func (e *EnumSet) Add(value interface{}) (int, bool) {
 	if e.Frozen {
 		panic("Cannot add value to frozen enum set")
 	}
+	if e.base != nil {
+		if enum, exists := e.base.IndexOf(value); exists {
+			return enum, false
+		}
+	}
+	if e.closed {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+		if enum, exists := e.Enum[value]; exists {
+			return enum, false
+		}
+		return e.unknown, false
+	}
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	enum, exists := e.Enum[value]
 	if exists {
 		return enum, false
 	}
-	enum = len(e.Index)
+	enum = e.offset + len(e.Index)
 	e.Enum[value] = enum
 	e.Index = append(e.Index, value)
 	return enum, true
 }
 
+// Close stops the set from growing, for parsing with a trained model:
+// values the set doesn't hold are no longer added by Add, which returns
+// the index of the reserved unknown value instead
+func (e *EnumSet) Close(unknown interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return
+	}
+	enum, exists := e.Enum[unknown]
+	if !exists {
+		enum = len(e.Index)
+		e.Enum[unknown] = enum
+		e.Index = append(e.Index, unknown)
+	}
+	e.unknown = enum
+	e.closed = true
+}
+
+func (e *EnumSet) Closed() bool {
+	return e.closed
+}
+
+// IsUnknown is true if index stands for the values a closed set hasn't seen
+func (e *EnumSet) IsUnknown(index int) bool {
+	return e.closed && index == e.unknown
+}
+
 func (e *EnumSet) IndexOf(value interface{}) (int, bool) {
+	if e.base != nil {
+		if enum, exists := e.base.IndexOf(value); exists {
+			return enum, true
+		}
+	}
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 	enum, exists := e.Enum[value]
@@ -56,34 +137,35 @@ func (e *EnumSet) ValueOf(index int) interface{} {
 	if index < 0 {
 		panic("Negative index requested")
 	}
+	if index < e.offset {
+		return e.base.ValueOf(index)
+	}
 	if len(e.Index) != len(e.Enum) {
 		log.Println("Rebuilding index!")
 		e.RebuildIndex()
 	}
-	if len(e.Index) <= index {
-		panic("Unknown index requested: " + fmt.Sprintf("%v of %v", index, len(e.Index)))
+	if e.offset+len(e.Index) <= index {
+		panic("Unknown index requested: " + fmt.Sprintf("%v of %v", index, e.offset+len(e.Index)))
 	}
-	return e.Index[index]
+	return e.Index[index-e.offset]
 }
 
 func (e *EnumSet) Len() int {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
-	return len(e.Index)
+	return e.offset + len(e.Index)
 }
 
 func (e *EnumSet) Print() {
 	for i, v := range e.Index {
-		fmt.Printf("%v: %v\n", i, v)
+		fmt.Printf("%v: %v\n", e.offset+i, v)
 	}
 }
 
 func NewEnumSet(capacity int) *EnumSet {
 	e := &EnumSet{
-		sync.RWMutex{},
-		make(map[interface{}]int, capacity),
-		make([]interface{}, 0, capacity),
-		false,
+		Enum:  make(map[interface{}]int, capacity),
+		Index: make([]interface{}, 0, capacity),
 	}
 	return e
 }
@@ -0,0 +1,62 @@
+package util
+
+import "testing"
+
+func TestEnumSetClose(t *testing.T) {
+	e := NewEnumSet(4)
+	known, _ := e.Add("a")
+	e.Add("b")
+	e.Close(UNKNOWN)
+	if e.Len() != 3 {
+		t.Fatalf("Expected the unknown value to be reserved, got %d values", e.Len())
+	}
+	if enum, isNew := e.Add("a"); enum != known || isNew {
+		t.Errorf("Expected known value at %d, got %d (new %v)", known, enum, isNew)
+	}
+	unknown, isNew := e.Add("c")
+	if isNew || !e.IsUnknown(unknown) || e.ValueOf(unknown) != UNKNOWN {
+		t.Errorf("Expected unseen value to map to the unknown index, got %d (%v)", unknown, e.ValueOf(unknown))
+	}
+	if other, _ := e.Add("d"); other != unknown || e.Len() != 3 {
+		t.Errorf("Expected closed set not to grow, got index %d and %d values", other, e.Len())
+	}
+	if e.IsUnknown(known) {
+		t.Errorf("Known value %d reported unknown", known)
+	}
+}
+
+func TestEnumSetOverlay(t *testing.T) {
+	e := NewEnumSet(4)
+	known, _ := e.Add("a")
+	e.Add("b")
+	overlay, other := e.Overlay(), e.Overlay()
+	if enum, isNew := overlay.Add("a"); enum != known || isNew {
+		t.Errorf("Expected known value at %d, got %d (new %v)", known, enum, isNew)
+	}
+	added, isNew := overlay.Add("c")
+	if !isNew || added != 2 || overlay.Len() != 3 || overlay.ValueOf(added) != "c" {
+		t.Errorf("Expected unseen value at 2, got %d (new %v) of %d values", added, isNew, overlay.Len())
+	}
+	if enum, exists := overlay.IndexOf("c"); !exists || enum != added {
+		t.Errorf("Expected added value at %d, got %d (exists %v)", added, enum, exists)
+	}
+	if overlay.ValueOf(known) != "a" {
+		t.Errorf("Expected value a at %d, got %v", known, overlay.ValueOf(known))
+	}
+	if e.Len() != 2 {
+		t.Errorf("Expected the overlay not to grow its base, got %d values", e.Len())
+	}
+	if enum, _ := other.Add("d"); enum != 2 {
+		t.Errorf("Expected overlays to add values independently, got index %d", enum)
+	}
+	if _, exists := other.IndexOf("c"); exists {
+		t.Error("Overlay holds the value of another overlay")
+	}
+	overlay.Reset()
+	if _, exists := overlay.IndexOf("c"); exists || overlay.Len() != 2 {
+		t.Errorf("Expected reset overlay to hold its base's values, got %d values", overlay.Len())
+	}
+	if enum, _ := overlay.Add("e"); enum != 2 || overlay.ValueOf(enum) != "e" {
+		t.Errorf("Expected reset overlay to add values after its base's, got index %d", enum)
+	}
+}
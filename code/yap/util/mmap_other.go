@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package util
+
+import (
+	"io/ioutil"
+)
+
+// MapFile reads a file into memory, on platforms without mmap
+func MapFile(fileName string) ([]byte, error) {
+	return ioutil.ReadFile(fileName)
+}
+
+// UnmapFile releases memory returned by MapFile
+func UnmapFile(data []byte) error {
+	return nil
+}
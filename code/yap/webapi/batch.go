@@ -0,0 +1,292 @@
+package webapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"yap/alg/search"
+	"yap/alg/transition"
+	"yap/app"
+	"yap/nlp/numerals"
+)
+
+const BATCH_MAX_LINE = 1 << 20
+
+var BatchWorkers int
+
+// BatchRecord is a single document of a batch request
+type BatchRecord struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Text   string          `json:"text"`
+	Format string          `json:"format,omitempty"`
+	// SpellNumerals spells out the numerals of the parse in Hebrew words
+	SpellNumerals bool `json:"spell_numerals,omitempty"`
+	// NumValues adds the value of numeral compounds as a NumValue feature
+	NumValues bool `json:"num_values,omitempty"`
+}
+
+// BatchResult is written as a single NDJSON line per BatchRecord, in input order
+type BatchResult struct {
+	ID json.RawMessage `json:"id"`
+	Data
+}
+
+// batchReader yields records from either a JSON array of records or a
+// stream of newline delimited records, without reading the whole body
+type batchReader struct {
+	reader  *bufio.Reader
+	decoder *json.Decoder
+	inArray bool
+}
+
+func newBatchReader(r io.Reader) *batchReader {
+	reader := bufio.NewReaderSize(r, BATCH_MAX_LINE)
+	return &batchReader{reader: reader, decoder: json.NewDecoder(reader)}
+}
+
+func (b *batchReader) Next() (*BatchRecord, error) {
+	if b.reader != nil {
+		isArray, err := b.startsWithArray()
+		b.reader = nil
+		if err != nil {
+			return nil, err
+		}
+		if isArray {
+			if _, err := b.decoder.Token(); err != nil {
+				return nil, err
+			}
+			b.inArray = true
+		}
+	}
+	if b.inArray && !b.decoder.More() {
+		return nil, io.EOF
+	}
+	record := &BatchRecord{}
+	if err := b.decoder.Decode(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (b *batchReader) startsWithArray() (bool, error) {
+	for {
+		c, err := b.reader.ReadByte()
+		if err != nil {
+			return false, err
+		}
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			continue
+		}
+		return c == '[', b.reader.UnreadByte()
+	}
+}
+
+// spoolFile is a temporary copy of a request body, removed when closed
+type spoolFile struct {
+	*os.File
+}
+
+func (f spoolFile) Close() error {
+	defer os.Remove(f.Name())
+	return f.File.Close()
+}
+
+// batchInput returns the body of a batch request to read the records from.
+// An HTTP/1.x handler can't read the request body once it has started
+// writing the response, so the body of HTTP/1.x requests is spooled to a
+// temporary file before the first result is written; only HTTP/2 requests
+// are parsed while they are still being read.
+func batchInput(req *http.Request) (io.ReadCloser, error) {
+	if req.ProtoMajor >= 2 {
+		return req.Body, nil
+	}
+	file, err := ioutil.TempFile("", "yap-batch")
+	if err != nil {
+		return nil, InternalError(STAGE_REQUEST, "failed spooling batch input: %v", err)
+	}
+	spool := spoolFile{file}
+	if _, err := io.Copy(spool, req.Body); err != nil {
+		spool.Close()
+		return nil, NewAPIError(http.StatusBadRequest, ERR_BAD_REQUEST, STAGE_REQUEST, "failed reading batch input: %v", err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		spool.Close()
+		return nil, InternalError(STAGE_REQUEST, "failed spooling batch input: %v", err)
+	}
+	return spool, nil
+}
+
+// recoveringParser keeps a parser panic from taking down the server
+// when parsing from a stream; a failed parse yields a nil result
+type recoveringParser struct {
+	parser app.Parser
+	err    interface{}
+}
+
+func (p *recoveringParser) Parse(problem search.Problem) (result transition.Configuration, _ interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("Recovered panic in batch parse:", r)
+			p.err = r
+			result = nil
+		}
+	}()
+	p.err = nil
+	return p.parser.Parse(problem)
+}
+
+type batchJob struct {
+	seq    int
+	record *BatchRecord
+}
+
+// runBatchWorker parses records with app.ParseStream, one sentence at a time
+func runBatchWorker(w *Worker, asJSON bool, records chan *batchJob, results chan *BatchResult) {
+	instances := make(chan interface{})
+	parsed := make(chan interface{})
+	parser := &recoveringParser{parser: w.jointBeam}
+	go app.ParseStream(instances, parsed, parser)
+	defer func() {
+		close(instances)
+		for _ = range parsed {
+		}
+		close(results)
+	}()
+	for job := range records {
+		results <- w.batchParse(job, asJSON, instances, parsed, parser)
+	}
+}
+
+func (w *Worker) batchParse(job *batchJob, asJSON bool, instances, parsed chan interface{}, parser *recoveringParser) (result *BatchResult) {
+	result = &BatchResult{ID: job.record.ID}
+	if len(result.ID) == 0 {
+		result.ID = json.RawMessage(strconv.Itoa(job.seq))
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("Recovered panic formatting batch record", string(result.ID), r)
+			result.Data = Data{Error: InternalError(STAGE_JOINT, "%v", r)}
+		}
+	}()
+	if len(strings.TrimSpace(job.record.Text)) == 0 {
+		result.Error = BadInput(STAGE_MA, "empty text")
+		return
+	}
+	maLattices, err := w.HebrewMorphAnalyzeText(job.record.Text)
+	if err != nil {
+		result.Error = asAPIError(STAGE_MA, err)
+		return
+	}
+	lAmb, err := parserLattices(maLattices)
+	if err != nil {
+		result.Error = asAPIError(STAGE_MA, err)
+		return
+	}
+	sents := jointInstances(lAmb)
+	parsedGraphs := make([]interface{}, len(sents))
+	for i, sent := range sents {
+		instances <- sent
+		parsedGraphs[i] = <-parsed
+		if parser.err != nil {
+			result.Error = InternalError(STAGE_JOINT, "sentence %d: %v", i+1, parser.err)
+			return
+		}
+	}
+	if job.record.NumValues {
+		numerals.AnnotateCorpus(parsedGraphs)
+	}
+	if job.record.SpellNumerals {
+		numerals.SpellOutCorpus(parsedGraphs)
+	}
+	result.Data = jointData(maLattices, parsedGraphs, asJSON || job.record.Format == FORMAT_JSON)
+	return
+}
+
+// HebrewJointBatchHandler runs the joint parser over a JSON array or NDJSON
+// stream of records, writing one NDJSON result per record in input order
+func HebrewJointBatchHandler(resp http.ResponseWriter, req *http.Request, w *Worker) {
+	input, err := batchInput(req)
+	if err != nil {
+		respondWithError(resp, STAGE_REQUEST, err)
+		return
+	}
+	defer input.Close()
+	workers := []*Worker{w}
+	maxWorkers := BatchWorkers
+	if maxWorkers <= 0 {
+		maxWorkers = pool.Size()
+	}
+	for len(workers) < maxWorkers {
+		extra := pool.TryAcquire()
+		if extra == nil {
+			break
+		}
+		workers = append(workers, extra)
+	}
+	defer func() {
+		for _, extra := range workers[1:] {
+			pool.Release(extra)
+		}
+	}()
+	log.Println("Running batch with", len(workers), "workers")
+
+	asJSON := req.URL.Query().Get("format") == FORMAT_JSON
+	records := make([]chan *batchJob, len(workers))
+	results := make([]chan *BatchResult, len(workers))
+	for i, worker := range workers {
+		records[i] = make(chan *batchJob, 1)
+		results[i] = make(chan *BatchResult, 1)
+		go runBatchWorker(worker, asJSON, records[i], results[i])
+	}
+
+	// dispatch records round-robin; results are collected in the same order
+	readErr := make(chan error, 1)
+	go func() {
+		defer func() {
+			for _, ch := range records {
+				close(ch)
+			}
+		}()
+		reader := newBatchReader(input)
+		for seq := 0; ; seq++ {
+			record, err := reader.Next()
+			if err == io.EOF {
+				readErr <- nil
+				return
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+			records[seq%len(records)] <- &batchJob{seq, record}
+		}
+	}()
+
+	// results are streamed back while the records are still being read
+	resp.Header().Set("Content-Type", "application/x-ndjson")
+	resp.WriteHeader(http.StatusOK)
+	flusher, _ := resp.(http.Flusher)
+	encoder := json.NewEncoder(resp)
+	for seq := 0; ; seq++ {
+		result, ok := <-results[seq%len(results)]
+		if !ok {
+			break
+		}
+		if err := encoder.Encode(result); err != nil {
+			log.Println("Failed writing batch result:", err)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	if err := <-readErr; err != nil {
+		encoder.Encode(Data{Error: NewAPIError(http.StatusBadRequest, ERR_BAD_REQUEST, STAGE_REQUEST, "invalid batch input: %v", err)})
+	}
+}
@@ -12,16 +12,17 @@ import (
 	"yap/nlp/format/lattice"
 	"strings"
 	"yap/app"
+	"yap/nlp/parser/constraint"
 	transitionmodel "yap/alg/transition/model"
 	. "yap/nlp/parser/dependency/transition"
 	nlp "yap/nlp/types"
 	"bytes"
-	"sync"
+	"io"
 )
 
 var (
 	depBeam *search.Beam
-	depLock sync.Mutex
+	depEnums *stageEnums
 )
 
 func DepParserInitialize(cmd *commander.Command, args []string) {
@@ -86,12 +87,14 @@ func DepParserInitialize(cmd *commander.Command, args []string) {
 
 	log.Println("Found model file", modelLocation, " ... loading model")
 	serialization := app.ReadModel(modelLocation)
+	app.CheckModel(modelLocation, serialization, featuresLocation, labelsLocation, "")
 	model.Deserialize(serialization.WeightModel)
 	app.EWord = serialization.EWord
 	app.EPOS = serialization.EPOS
 	app.EWPOS = serialization.EWPOS
 	app.EMHost = serialization.EMHost
 	app.EMSuffix = serialization.EMSuffix
+	depEnums = captureEnums()
 	log.Println("Loaded model")
 
 	conf := &SimpleConfiguration{
@@ -119,24 +122,82 @@ func DepParserInitialize(cmd *commander.Command, args []string) {
 	}
 }
 
-func DepParseDisambiguatedLattice(input string) string {
-	depLock.Lock()
+func (w *Worker) DepParseDisambiguatedLattice(input string) (string, error) {
+	_, graphAsConll, err := w.DepParse(input, nil)
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	conll.Write(buf, graphAsConll)
+	return buf.String(), nil
+}
+
+// DepParse returns the input lattices and their parses as conll.Sentence
+// values, restricted by the constraints of each sentence (if any)
+func (w *Worker) DepParse(input string, constraints []*constraint.Sentence) (lDisamb []lattice.Lattice, graphAsConll []interface{}, err error) {
+	lDisamb, sents, err := readDepInput(input)
+	if err != nil {
+		return
+	}
+	defer recoverStage(STAGE_DEP, &err)
+	parsedGraphs := app.ParseConstrained(sents, w.depBeam, constraints)
+	graphAsConll = conll.Graph2ConllCorpus(parsedGraphs, depEnums.EMHost, depEnums.EMSuffix)
+	return
+}
+
+// DepParseKBest returns the input lattices and up to k distinct parses of
+// each, best first; the parses of kbest are converted to conll.Sentence
+// values
+func (w *Worker) DepParseKBest(input string, k int, constraints []*constraint.Sentence) (lDisamb []lattice.Lattice, kbest *app.KBestParses, err error) {
+	lDisamb, sents, err := readDepInput(input)
+	if err != nil {
+		return
+	}
+	defer recoverStage(STAGE_DEP, &err)
+	kbest = app.ParseKBest(sents, w.depBeam, k, app.OutputKey(writeDepParse), constraints)
+	kbest.Parses = conll.Graph2ConllCorpus(kbest.Parses, depEnums.EMHost, depEnums.EMSuffix)
+	return
+}
+
+// DepParseConfidence returns the input lattices, their parses as
+// conll.Sentence values and the confidence of their arcs
+func (w *Worker) DepParseConfidence(input string, constraints []*constraint.Sentence) (lDisamb []lattice.Lattice, graphAsConll []interface{}, confs []*app.Confidence, err error) {
+	lDisamb, sents, err := readDepInput(input)
+	if err != nil {
+		return
+	}
+	defer recoverStage(STAGE_DEP, &err)
+	parsedGraphs, confs := app.ParseConfidence(sents, w.depBeam, constraints)
+	graphAsConll = conll.Graph2ConllCorpus(parsedGraphs, depEnums.EMHost, depEnums.EMSuffix)
+	return
+}
+
+func writeDepParse(writer io.Writer, parses []interface{}) {
+	conll.Write(writer, conll.Graph2ConllCorpus(parses, depEnums.EMHost, depEnums.EMSuffix))
+}
+
+// readDepInput reads the disambiguated lattices of a request as tagged
+// sentences
+func readDepInput(input string) (lDisamb []lattice.Lattice, sents []interface{}, err error) {
+	defer recoverStage(STAGE_DEP, &err)
 	log.Println("Reading disambiguated lattice")
 	log.Println("input:\n",input)
 	reader := strings.NewReader(input)
-	lDisamb, lDisambE := lattice.Read(reader, 0)
-	if lDisambE != nil {
-		panic(fmt.Sprintf("Failed reading raw input - %v", lDisamb))
+	err = readInput(STAGE_DEP, func() (readErr error) {
+		lDisamb, readErr = lattice.Read(reader, 0)
+		return
+	})
+	if err != nil {
+		return
 	}
-	internalSents := lattice.Lattice2SentenceCorpus(lDisamb, app.EWord, app.EPOS, app.EWPOS, app.EMorphProp, app.EMHost, app.EMSuffix)
-	sents := make([]interface{}, len(internalSents))
+	if len(lDisamb) == 0 {
+		err = BadInput(STAGE_DEP, "no lattices found in input")
+		return
+	}
+	internalSents := lattice.Lattice2SentenceCorpus(lDisamb, depEnums.EWord, depEnums.EPOS, depEnums.EWPOS, depEnums.EMorphProp, depEnums.EMHost, depEnums.EMSuffix)
+	sents = make([]interface{}, len(internalSents))
 	for i, instance := range internalSents {
 		sents[i] = instance.(nlp.LatticeSentence).TaggedSentence()
 	}
-	parsedGraphs := app.Parse(sents, depBeam)
-	graphAsConll := conll.Graph2ConllCorpus(parsedGraphs, app.EMHost, app.EMSuffix)
-	buf := new(bytes.Buffer)
-	conll.Write(buf, graphAsConll)
-	depLock.Unlock()
-	return buf.String()
+	return
 }
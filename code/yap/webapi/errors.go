@@ -0,0 +1,106 @@
+package webapi
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+)
+
+// Stage identifies the part of the processing chain that failed
+type Stage string
+
+const (
+	STAGE_REQUEST Stage = "request"
+	STAGE_MA      Stage = "MA"
+	STAGE_MD      Stage = "MD"
+	STAGE_DEP     Stage = "dep"
+	STAGE_JOINT   Stage = "joint"
+	STAGE_GEN     Stage = "generate"
+)
+
+const (
+	ERR_BAD_REQUEST = "bad_request"
+	ERR_BAD_INPUT   = "bad_input"
+	ERR_INTERNAL    = "internal_error"
+	ERR_SATURATED   = "saturated"
+)
+
+// APIError is the JSON error object returned to clients
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Stage   Stage  `json:"stage"`
+	Status  int    `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%v: %v (%v)", e.Stage, e.Message, e.Code)
+}
+
+func NewAPIError(status int, code string, stage Stage, format string, args ...interface{}) *APIError {
+	return &APIError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+		Stage:   stage,
+		Status:  status,
+	}
+}
+
+func BadInput(stage Stage, format string, args ...interface{}) *APIError {
+	return NewAPIError(http.StatusBadRequest, ERR_BAD_INPUT, stage, format, args...)
+}
+
+func InternalError(stage Stage, format string, args ...interface{}) *APIError {
+	return NewAPIError(http.StatusInternalServerError, ERR_INTERNAL, stage, format, args...)
+}
+
+// recoverStage converts a panic raised while running stage into an
+// *APIError stored in err; use as: defer recoverStage(STAGE_MA, &err)
+func recoverStage(stage Stage, err *error) {
+	if r := recover(); r != nil {
+		log.Printf("Recovered panic in stage %v: %v\n%s", stage, r, debug.Stack())
+		*err = InternalError(stage, "%v", r)
+	}
+}
+
+// readInput runs a reader function, treating a panic as malformed input
+func readInput(stage Stage, read func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Recovered panic reading input for stage %v: %v", stage, r)
+			err = BadInput(stage, "malformed input: %v", r)
+		}
+	}()
+	if readErr := read(); readErr != nil {
+		return BadInput(stage, "failed reading input: %v", readErr)
+	}
+	return nil
+}
+
+// asAPIError wraps any error returned by a stage into an *APIError
+func asAPIError(stage Stage, err error) *APIError {
+	if apiErr, ok := err.(*APIError); ok {
+		return apiErr
+	}
+	return InternalError(stage, "%v", err)
+}
+
+func respondWithError(resp http.ResponseWriter, stage Stage, err error) {
+	apiErr := asAPIError(stage, err)
+	respondWithJSON(resp, apiErr.Status, Data{Error: apiErr})
+}
+
+// withRecovery guards a handler so a panic in one request returns a JSON
+// error instead of dropping the connection
+func withRecovery(handler http.HandlerFunc) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Recovered panic serving %v: %v\n%s", req.URL.Path, r, debug.Stack())
+				respondWithJSON(resp, http.StatusInternalServerError, Data{Error: InternalError(STAGE_REQUEST, "%v", r)})
+			}
+		}()
+		handler(resp, req)
+	}
+}
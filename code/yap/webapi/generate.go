@@ -0,0 +1,56 @@
+package webapi
+
+import (
+	"net/http"
+	"strings"
+
+	"yap/nlp/parser/ma"
+)
+
+// GeneratedForm is a surface form generated for a lemma from the lexicon
+type GeneratedForm struct {
+	Form   string            `json:"form"`
+	Prefix string            `json:"prefix,omitempty"`
+	Host   string            `json:"host"`
+	Lemma  string            `json:"lemma"`
+	CPOS   string            `json:"cpos"`
+	POS    string            `json:"pos"`
+	Feats  map[string]string `json:"feats,omitempty"`
+}
+
+func generatedForms(generated []*ma.Generated) []GeneratedForm {
+	forms := make([]GeneratedForm, len(generated))
+	for i, g := range generated {
+		host := g.HostMorpheme()
+		forms[i] = GeneratedForm{
+			Form:   g.Form,
+			Prefix: g.Prefix,
+			Host:   g.Host,
+			Lemma:  host.Lemma,
+			CPOS:   host.CPOS,
+			POS:    host.POS,
+			Feats:  jsonFeats(host.FeatureStr),
+		}
+	}
+	return forms
+}
+
+// HebrewGenerateHandler lists the surface forms of a lemma with the
+// requested pos and features
+func HebrewGenerateHandler(resp http.ResponseWriter, req *http.Request, w *Worker) {
+	request, ok := decodeRequest(resp, req)
+	if !ok {
+		return
+	}
+	if len(strings.TrimSpace(request.Lemma)) == 0 {
+		respondWithError(resp, STAGE_GEN, BadInput(STAGE_GEN, "no lemma given"))
+		return
+	}
+	var generated []*ma.Generated
+	if request.NoPrefixes {
+		generated = w.maData.GenerateHosts(request.Lemma, request.POS, request.Feats)
+	} else {
+		generated = w.maData.Generate(request.Lemma, request.POS, request.Feats)
+	}
+	respondWithJSON(resp, http.StatusOK, Data{Forms: generatedForms(generated)})
+}
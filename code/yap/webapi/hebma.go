@@ -3,6 +3,7 @@ package webapi
 import (
 	"yap/nlp/format/lattice"
 	"yap/nlp/format/raw"
+	"yap/nlp/format/tokenize"
 	"log"
 	"fmt"
 	nlp "yap/nlp/types"
@@ -14,11 +15,9 @@ import (
 	"github.com/gonuts/commander"
 	"bytes"
 	"yap/nlp/parser/xliter8"
-	"sync"
 )
 
 var (
-	maLock sync.Mutex
 	maHebrew xliter8.Interface
 	maData *ma.BGULex
 )
@@ -47,34 +46,67 @@ func HebrewMorphAnalyazerInitialize(cmd *commander.Command, args []string) {
 
 }
 
-func HebrewMorphAnalyzeRawSentences(input string) string {
-	maLock.Lock()
+func (w *Worker) HebrewMorphAnalyzeRawSentences(input string) (string, error) {
+	output, err := w.HebrewMorphAnalyze(input)
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	err = lattice.Write(buf, output)
+	if err != nil {
+		return "", InternalError(STAGE_MA, "failed writing lattices: %v", err)
+	}
+	return buf.String(), nil
+}
+
+func (w *Worker) HebrewMorphAnalyze(input string) (output []lattice.Lattice, err error) {
+	defer recoverStage(STAGE_MA, &err)
 	var (
 		reader io.Reader
 		sents []nlp.BasicSentence
-		err error
+		ranges [][]nlp.TokenRange
 	)
 	reader = strings.NewReader(input)
-	sents, err = raw.Read(reader, 0)
+	err = readInput(STAGE_MA, func() (readErr error) {
+		sents, ranges, readErr = raw.ReadWithRanges(reader, 0)
+		return
+	})
 	if err != nil {
-		panic(fmt.Sprintf("Failed reading raw input - %v", err))
+		return nil, err
+	}
+	if len(sents) == 0 {
+		return nil, BadInput(STAGE_MA, "no complete sentence found, input must end with an empty line")
 	}
-	log.Println("Running Hebrew Morphological Analysis")
 	log.Println("input:\n",input)
+	return w.analyzeSentences(sents, ranges), nil
+}
+
+// HebrewMorphAnalyzeText tokenizes and analyzes running text
+func (w *Worker) HebrewMorphAnalyzeText(text string) (output []lattice.Lattice, err error) {
+	defer recoverStage(STAGE_MA, &err)
+	sents, ranges := tokenize.SentencesWithRanges(text)
+	if len(sents) == 0 {
+		return nil, BadInput(STAGE_MA, "no tokens found in text")
+	}
+	log.Println("input:\n",text)
+	return w.analyzeSentences(sents, ranges), nil
+}
+
+// analyzeSentences analyzes tokenized sentences; ranges holds the offsets
+// of each token in the input text
+func (w *Worker) analyzeSentences(sents []nlp.BasicSentence, ranges [][]nlp.TokenRange) []lattice.Lattice {
+	log.Println("Running Hebrew Morphological Analysis")
 	stats := new(ma.AnalyzeStats)
 	stats.Init()
-	maData.Stats = stats
+	w.maData.Stats = stats
 	//prefix := log.Prefix()
 	lattices := make([]nlp.LatticeSentence, len(sents))
 	oovInd := make([]interface{}, len(sents))
 	for i, sent := range sents {
 		//log.SetPrefix(fmt.Sprintf("%v graph# %v ", prefix, i))
-		lattices[i], oovInd[i] = maData.Analyze(sent.Tokens())
+		lattices[i], oovInd[i] = w.maData.Analyze(sent.Tokens())
+		lattices[i].SetRanges(ranges[i])
 	}
 	log.Println()
-	output := lattice.Sentence2LatticeCorpus(lattices, maHebrew)
-	buf := new(bytes.Buffer)
-	err = lattice.Write(buf, output)
-	maLock.Unlock()
-	return buf.String()
+	return lattice.Sentence2LatticeCorpus(lattices, maHebrew)
 }
@@ -12,27 +12,29 @@ import (
 	transitionmodel "yap/alg/transition/model"
 	. "yap/nlp/parser/dependency/transition"
 	"yap/app"
+	"yap/nlp/parser/constraint"
 	"yap/alg/transition"
 	"fmt"
 	"bytes"
 	"yap/nlp/format/mapping"
 	"yap/nlp/format/segmentation"
 	"yap/util"
-	"strings"
-	"sync"
+	"io"
 )
 
 var (
-	extractor *transition.GenericExtractor
-	arcSystem transition.TransitionSystem
-	transitionSystem transition.TransitionSystem
-	model *transitionmodel.AvgMatrixSparse
-	terminalStack int
-	paramFunc nlp.MDParam
-	jointLock sync.Mutex
+	jointBeam *search.Beam
+	jointEnums *stageEnums
 )
 
 func JointParserInitialize() {
+	var (
+		extractor *transition.GenericExtractor
+		arcSystem transition.TransitionSystem
+		transitionSystem transition.TransitionSystem
+		model *transitionmodel.AvgMatrixSparse
+		terminalStack int
+	)
 	paramFunc, exists := nlp.MDParams[app.MdParamFuncName]
 	if !exists {
 		log.Fatalln("Param Func", app.MdParamFuncName, "does not exist")
@@ -122,6 +124,7 @@ func JointParserInitialize() {
 
 	log.Println("Found model file", app.JointModelFile, " ... loading model")
 	serialization := app.ReadModel(app.JointModelFile)
+	app.CheckModel(app.JointModelFile, serialization, app.JointFeaturesFile, app.DepLabelsFile, app.MdParamFuncName)
 	model = &transitionmodel.AvgMatrixSparse{}
 	model.Deserialize(serialization.WeightModel)
 	app.EWord = serialization.EWord
@@ -157,18 +160,7 @@ func JointParserInitialize() {
 	jointTrans.MDTransition = app.MD
 	jointTrans.JointStrategy = app.JointStrategy
 	transitionSystem = transition.TransitionSystem(jointTrans)
-}
-
-func JointParseAmbiguousLattices(input string) (string, string, string) {
-	jointLock.Lock()
-	log.Println("Reading ambiguous lattices")
-	log.Println("input:\n",input)
-	reader := strings.NewReader(input)
-	lAmb, lAmbE := lattice.Read(reader, 0)
-	if lAmbE != nil {
-		panic(fmt.Sprintf("Failed reading raw input - %v", lAmbE))
-	}
-	predAmbLat := lattice.Lattice2SentenceCorpus(lAmb, app.EWord, app.EPOS, app.EWPOS, app.EMorphProp, app.EMHost, app.EMSuffix)
+	jointEnums = captureEnums()
 	conf := &joint.JointConfig{
 		SimpleConfiguration: SimpleConfiguration{
 			EWord: app.EWord,
@@ -189,7 +181,7 @@ func JointParseAmbiguousLattices(input string) (string, string, string) {
 		},
 		MDTrans: app.MD,
 	}
-	beam := &search.Beam{
+	jointBeam = &search.Beam{
 		TransFunc: transitionSystem,
 		FeatExtractor: extractor,
 		Base: conf,
@@ -198,19 +190,69 @@ func JointParseAmbiguousLattices(input string) (string, string, string) {
 		Transitions: app.ETrans,
 		EstimatedTransitions: 1000, // chosen by random dice roll
 	}
-	beam.Model = model
-	beam.ShortTempAgenda = true
-	parsedGraphs := app.Parse(predAmbLat, beam)
+	jointBeam.Model = model
+	jointBeam.ShortTempAgenda = true
+}
+
+func (w *Worker) JointParseAmbiguousLattices(input string) (conllDepOut, mappingMdOut, segmentationMdOut string, err error) {
+	parsedGraphs, err := w.JointParse(input)
+	if err != nil {
+		return
+	}
 	graphAsConll := conll.MorphGraph2ConllCorpus(parsedGraphs)
 	buf1 := new(bytes.Buffer)
 	conll.Write(buf1, graphAsConll)
-	conllDepOut := buf1.String()
+	conllDepOut = buf1.String()
 	buf2 := new(bytes.Buffer)
 	mapping.Write(buf2, app.GetInstances(parsedGraphs, app.GetJointMDConfig))
-	mappingMdOut := buf2.String()
+	mappingMdOut = buf2.String()
 	buf3 := new(bytes.Buffer)
 	segmentation.Write(buf3, parsedGraphs)
-	segmentationMdOut := buf3.String()
-	jointLock.Unlock()
-	return conllDepOut, mappingMdOut, segmentationMdOut
+	segmentationMdOut = buf3.String()
+	return
+}
+
+// JointParse returns the parsed *joint.JointConfig of each lattice
+func (w *Worker) JointParse(input string) (parsedGraphs []interface{}, err error) {
+	lAmb, err := readAmbLattices(STAGE_JOINT, input)
+	if err != nil {
+		return
+	}
+	return w.jointParse(lAmb, nil)
+}
+
+// jointParse parses already analyzed lattices, keeping their token ranges,
+// restricted by the constraints of each sentence (if any)
+func (w *Worker) jointParse(lAmb []lattice.Lattice, constraints []*constraint.Sentence) (parsedGraphs []interface{}, err error) {
+	defer recoverStage(STAGE_JOINT, &err)
+	parsedGraphs = app.ParseConstrained(jointInstances(lAmb), w.jointBeam, constraints)
+	return
+}
+
+// jointParseKBest parses already analyzed lattices keeping up to k distinct
+// parses of each, best first
+func (w *Worker) jointParseKBest(lAmb []lattice.Lattice, k int, constraints []*constraint.Sentence) (kbest *app.KBestParses, err error) {
+	defer recoverStage(STAGE_JOINT, &err)
+	kbest = app.ParseKBest(jointInstances(lAmb), w.jointBeam, k, app.OutputKey(writeJointParse), constraints)
+	return
+}
+
+// jointParseConfidence parses already analyzed lattices and estimates the
+// confidence of every token segmentation and arc
+func (w *Worker) jointParseConfidence(lAmb []lattice.Lattice, constraints []*constraint.Sentence) (parsedGraphs []interface{}, confs []*app.Confidence, err error) {
+	defer recoverStage(STAGE_JOINT, &err)
+	parsedGraphs, confs = app.ParseConfidence(jointInstances(lAmb), w.jointBeam, constraints)
+	return
+}
+
+// writeJointParse writes joint parses in CoNLL format, used to tell apart
+// distinct k-best parses
+func writeJointParse(writer io.Writer, parses []interface{}) {
+	conll.Write(writer, conll.MorphGraph2ConllCorpus(parses))
+}
+
+// jointInstances converts ambiguous lattices to parser input using the
+// joint model's enumerations
+func jointInstances(lAmb []lattice.Lattice) []interface{} {
+	return lattice.Lattice2SentenceCorpus(lAmb, jointEnums.EWord, jointEnums.EPOS, jointEnums.EWPOS, jointEnums.EMorphProp, jointEnums.EMHost, jointEnums.EMSuffix)
 }
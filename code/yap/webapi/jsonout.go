@@ -0,0 +1,242 @@
+package webapi
+
+import (
+	"net/http"
+	"sort"
+
+	"yap/app"
+	"yap/nlp/format/conll"
+	"yap/nlp/format/lattice"
+	"yap/nlp/parser/disambig"
+	nlp "yap/nlp/types"
+)
+
+const FORMAT_JSON = "json"
+
+// JSONToken is an input token and the IDs of the morphemes it maps to;
+// Range is the token's rune offsets in the request text, when known, and
+// Conf the confidence of its segmentation, when asked for
+type JSONToken struct {
+	ID        int             `json:"id"`
+	Form      string          `json:"form,omitempty"`
+	Range     *nlp.TokenRange `json:"range,omitempty"`
+	Morphemes []int           `json:"morphemes,omitempty"`
+	Conf      float64         `json:"conf,omitempty"`
+}
+
+// JSONMorpheme is a disambiguated morpheme; IDs match the dependency arcs
+type JSONMorpheme struct {
+	ID    int               `json:"id"`
+	Form  string            `json:"form"`
+	Lemma string            `json:"lemma,omitempty"`
+	CPOS  string            `json:"cpos"`
+	POS   string            `json:"pos"`
+	Feats map[string]string `json:"feats,omitempty"`
+	Token int               `json:"token"`
+}
+
+// JSONLatticeEdge is an edge of the ambiguous morphological analysis lattice
+type JSONLatticeEdge struct {
+	From  int               `json:"from"`
+	To    int               `json:"to"`
+	Form  string            `json:"form"`
+	Lemma string            `json:"lemma,omitempty"`
+	CPOS  string            `json:"cpos"`
+	POS   string            `json:"pos"`
+	Feats map[string]string `json:"feats,omitempty"`
+	Token int               `json:"token"`
+}
+
+// JSONArc is a dependency arc between morphemes; Head is 0 for the root.
+// Conf is the confidence of the arc and of its dependent's segmentation,
+// when asked for
+type JSONArc struct {
+	Head      int     `json:"head"`
+	Dependent int     `json:"dependent"`
+	Rel       string  `json:"rel"`
+	Conf      float64 `json:"conf,omitempty"`
+}
+
+type JSONSentence struct {
+	Tokens    []JSONToken       `json:"tokens"`
+	Lattice   []JSONLatticeEdge `json:"lattice,omitempty"`
+	Morphemes []JSONMorpheme    `json:"morphemes,omitempty"`
+	Arcs      []JSONArc         `json:"arcs,omitempty"`
+	KBest     []JSONParse       `json:"kbest,omitempty"`
+}
+
+// JSONParse is one of the k-best parses of a sentence with its model score
+type JSONParse struct {
+	Score float64 `json:"score"`
+	JSONSentence
+}
+
+func wantsJSON(req *http.Request, request *Request) bool {
+	return req.URL.Query().Get("format") == FORMAT_JSON || request.Format == FORMAT_JSON
+}
+
+func jsonFeats(featStr string) map[string]string {
+	if len(featStr) == 0 || featStr == "_" {
+		return nil
+	}
+	feats, err := lattice.ParseFeatures(featStr)
+	if err != nil || len(feats) == 0 {
+		return nil
+	}
+	return map[string]string(feats)
+}
+
+func jsonRange(r nlp.TokenRange) *nlp.TokenRange {
+	if !r.IsSet() {
+		return nil
+	}
+	return &r
+}
+
+func jsonLemma(lemma string) string {
+	if lemma == "_" {
+		return ""
+	}
+	return lemma
+}
+
+// sortedEdges returns the lattice edges ordered by start node
+func sortedEdges(lat lattice.Lattice) []lattice.Edge {
+	starts := make([]int, 0, len(lat))
+	for start := range lat {
+		starts = append(starts, start)
+	}
+	sort.Ints(starts)
+	edges := make([]lattice.Edge, 0, len(lat))
+	for _, start := range starts {
+		edges = append(edges, lat[start]...)
+	}
+	return edges
+}
+
+// JSONFromAmbLattice builds the tokens and edges of an ambiguous lattice
+func JSONFromAmbLattice(lat lattice.Lattice) JSONSentence {
+	sent := JSONSentence{}
+	seen := make(map[int]bool)
+	for _, edge := range sortedEdges(lat) {
+		sent.Lattice = append(sent.Lattice, JSONLatticeEdge{
+			From:  edge.Start,
+			To:    edge.End,
+			Form:  edge.Word,
+			Lemma: jsonLemma(edge.Lemma),
+			CPOS:  edge.CPosTag,
+			POS:   edge.PosTag,
+			Feats: jsonFeats(edge.FeatStr),
+			Token: edge.Token,
+		})
+		if !seen[edge.Token] {
+			seen[edge.Token] = true
+			sent.Tokens = append(sent.Tokens, JSONToken{ID: edge.Token, Form: edge.TokenStr, Range: jsonRange(edge.Range)})
+		}
+	}
+	sort.Slice(sent.Tokens, func(i, j int) bool { return sent.Tokens[i].ID < sent.Tokens[j].ID })
+	return sent
+}
+
+// JSONFromDisambLattice builds tokens and morphemes from a disambiguated
+// (single path) lattice; token forms are not part of the lattice format
+func JSONFromDisambLattice(lat lattice.Lattice) JSONSentence {
+	sent := JSONSentence{}
+	for i, edge := range sortedEdges(lat) {
+		id := i + 1
+		sent.Morphemes = append(sent.Morphemes, JSONMorpheme{
+			ID:    id,
+			Form:  edge.Word,
+			Lemma: jsonLemma(edge.Lemma),
+			CPOS:  edge.CPosTag,
+			POS:   edge.PosTag,
+			Feats: jsonFeats(edge.FeatStr),
+			Token: edge.Token,
+		})
+		if len(sent.Tokens) == 0 || sent.Tokens[len(sent.Tokens)-1].ID != edge.Token {
+			sent.Tokens = append(sent.Tokens, JSONToken{ID: edge.Token, Form: edge.TokenStr, Range: jsonRange(edge.Range)})
+		}
+		last := &sent.Tokens[len(sent.Tokens)-1]
+		last.Morphemes = append(last.Morphemes, id)
+	}
+	return sent
+}
+
+// JSONFromMappings builds tokens and morphemes from a token to morpheme mapping
+func JSONFromMappings(mappings nlp.Mappings) JSONSentence {
+	sent := JSONSentence{}
+	curMorph := 1
+	for i, m := range mappings {
+		if m.Token == nlp.ROOT_TOKEN {
+			continue
+		}
+		token := JSONToken{ID: i + 1, Form: string(m.Token), Range: jsonRange(m.Range)}
+		for _, morph := range m.Spellout {
+			if morph == nil {
+				continue
+			}
+			sent.Morphemes = append(sent.Morphemes, JSONMorpheme{
+				ID:    curMorph,
+				Form:  morph.Form,
+				Lemma: morph.Lemma,
+				CPOS:  morph.CPOS,
+				POS:   morph.POS,
+				Feats: jsonFeats(morph.FeatureStr),
+				Token: token.ID,
+			})
+			token.Morphemes = append(token.Morphemes, curMorph)
+			curMorph++
+		}
+		sent.Tokens = append(sent.Tokens, token)
+	}
+	return sent
+}
+
+// JSONFromMDConfig builds tokens and morphemes from a disambiguation result
+func JSONFromMDConfig(mdConfig interface{}) JSONSentence {
+	return JSONFromMappings(mdConfig.(*disambig.MDConfig).Mappings)
+}
+
+// JSONFromKBest builds one sentence per input sentence of the k-best parses;
+// a sentence holds its best parse and lists all of its parses, best first,
+// in KBest (without the shared input lattice). parse builds the JSON of the
+// i-th parse of kbest.
+func JSONFromKBest(kbest *app.KBestParses, parse func(i int) JSONSentence) []JSONSentence {
+	var sents []JSONSentence
+	for i := range kbest.Parses {
+		sent := parse(i)
+		if kbest.Ranks[i] == 0 {
+			sents = append(sents, sent)
+		}
+		last := &sents[len(sents)-1]
+		sent.Lattice = nil
+		last.KBest = append(last.KBest, JSONParse{Score: kbest.Scores[i], JSONSentence: sent})
+	}
+	return sents
+}
+
+// AddDepTree adds the arcs of a parsed sentence
+func (s *JSONSentence) AddDepTree(tree conll.Sentence) {
+	for i := 1; i <= len(tree); i++ {
+		row, exists := tree[i]
+		if !exists {
+			continue
+		}
+		s.Arcs = append(s.Arcs, JSONArc{Head: row.Head, Dependent: row.ID, Rel: row.DepRel})
+	}
+}
+
+// AddConfidence adds the confidence of the segmentation of every token and
+// of every arc
+func (s *JSONSentence) AddConfidence(conf *app.Confidence) {
+	for i, token := range s.Tokens {
+		if t := token.ID - 1; t >= 0 && t < len(conf.Tokens) {
+			s.Tokens[i].Conf = conf.Tokens[t]
+		}
+	}
+	for i, arc := range s.Arcs {
+		if m := arc.Dependent - 1; m >= 0 && m < len(conf.Morphemes) {
+			s.Arcs[i].Conf = conf.Morphemes[m]
+		}
+	}
+}
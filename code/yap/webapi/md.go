@@ -10,17 +10,17 @@ import (
 	"yap/alg/search"
 	"strings"
 	"yap/app"
+	"yap/nlp/parser/constraint"
 	"yap/alg/transition"
 	transitionmodel "yap/alg/transition/model"
 	nlp "yap/nlp/types"
 	"yap/nlp/format/mapping"
 	"bytes"
-	"sync"
 )
 
 var (
 	mdBeam *search.Beam
-	mdLock sync.Mutex
+	mdEnums *stageEnums
 )
 
 func MorphDisambiguatorInitialize(cmd *commander.Command, args []string) {
@@ -66,6 +66,7 @@ func MorphDisambiguatorInitialize(cmd *commander.Command, args []string) {
 	log.Println("Found MD model file", modelLocation, " ... loading model")
 
 	serialization := app.ReadModel(modelLocation)
+	app.CheckModel(modelLocation, serialization, featuresLocation, "", app.MdParamFuncName)
 	model.Deserialize(serialization.WeightModel)
 	app.EWord = serialization.EWord
 	app.EPOS = serialization.EPOS
@@ -75,6 +76,7 @@ func MorphDisambiguatorInitialize(cmd *commander.Command, args []string) {
 	app.EMorphProp = serialization.EMorphProp
 	app.ETrans = serialization.ETrans
 	app.ETokens = serialization.ETokens
+	mdEnums = captureEnums()
 
 	mdTrans = &disambig.MDTrans{
 		ParamFunc: paramFunc,
@@ -106,19 +108,75 @@ func MorphDisambiguatorInitialize(cmd *commander.Command, args []string) {
 	mdBeam.Model = model
 }
 
-func MorphDisambiguateLattices(input string) string {
-	mdLock.Lock()
+func (w *Worker) MorphDisambiguateLattices(input string) (string, error) {
+	mappings, err := w.MorphDisambiguate(input, nil)
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	mapping.Write(buf, mappings)
+	return buf.String(), nil
+}
+
+// MorphDisambiguate returns the disambiguated *disambig.MDConfig of each
+// lattice, restricted by the constraints of each sentence (if any)
+func (w *Worker) MorphDisambiguate(input string, constraints []*constraint.Sentence) (mappings []interface{}, err error) {
+	lAmb, err := readAmbLattices(STAGE_MD, input)
+	if err != nil {
+		return nil, err
+	}
+	return w.disambiguate(lAmb, constraints)
+}
+
+// MorphDisambiguateKBest returns up to k distinct disambiguations of each
+// lattice, best first
+func (w *Worker) MorphDisambiguateKBest(input string, k int, constraints []*constraint.Sentence) (kbest *app.KBestParses, err error) {
+	lAmb, err := readAmbLattices(STAGE_MD, input)
+	if err != nil {
+		return nil, err
+	}
+	defer recoverStage(STAGE_MD, &err)
+	predAmbLat := lattice.Lattice2SentenceCorpus(lAmb, mdEnums.EWord, mdEnums.EPOS, mdEnums.EWPOS, mdEnums.EMorphProp, mdEnums.EMHost, mdEnums.EMSuffix)
+	kbest = app.ParseKBest(predAmbLat, w.mdBeam, k, app.OutputKey(mapping.Write), constraints)
+	return kbest, nil
+}
+
+// MorphDisambiguateConfidence returns the disambiguated *disambig.MDConfig of
+// each lattice and the confidence of its segmentation
+func (w *Worker) MorphDisambiguateConfidence(input string, constraints []*constraint.Sentence) (mappings []interface{}, confs []*app.Confidence, err error) {
+	lAmb, err := readAmbLattices(STAGE_MD, input)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer recoverStage(STAGE_MD, &err)
+	predAmbLat := lattice.Lattice2SentenceCorpus(lAmb, mdEnums.EWord, mdEnums.EPOS, mdEnums.EWPOS, mdEnums.EMorphProp, mdEnums.EMHost, mdEnums.EMSuffix)
+	mappings, confs = app.ParseConfidence(predAmbLat, w.mdBeam, constraints)
+	return mappings, confs, nil
+}
+
+// readAmbLattices reads the ambiguous lattices of a request
+func readAmbLattices(stage Stage, input string) (lAmb []lattice.Lattice, err error) {
+	defer recoverStage(stage, &err)
 	log.Println("Reading ambiguous lattices")
 	log.Println("input:\n ",input)
 	reader := strings.NewReader(input)
-	lAmb, lAmbE := lattice.Read(reader, 0)
-	if lAmbE != nil {
-		panic(fmt.Sprintf("Failed reading raw input - %v", lAmbE))
+	err = readInput(stage, func() (readErr error) {
+		lAmb, readErr = lattice.Read(reader, 0)
+		return
+	})
+	if err != nil {
+		return nil, err
 	}
-	predAmbLat := lattice.Lattice2SentenceCorpus(lAmb, app.EWord, app.EPOS, app.EWPOS, app.EMorphProp, app.EMHost, app.EMSuffix)
-	mappings := app.Parse(predAmbLat, mdBeam)
-	buf := new(bytes.Buffer)
-	mapping.Write(buf, mappings)
-	mdLock.Unlock()
-	return buf.String()
+	if len(lAmb) == 0 {
+		return nil, BadInput(stage, "no lattices found in input")
+	}
+	return lAmb, nil
+}
+
+// disambiguate runs MD on already analyzed lattices, keeping their token ranges
+func (w *Worker) disambiguate(lAmb []lattice.Lattice, constraints []*constraint.Sentence) (mappings []interface{}, err error) {
+	defer recoverStage(STAGE_MD, &err)
+	predAmbLat := lattice.Lattice2SentenceCorpus(lAmb, mdEnums.EWord, mdEnums.EPOS, mdEnums.EWPOS, mdEnums.EMorphProp, mdEnums.EMHost, mdEnums.EMSuffix)
+	mappings = app.ParseConstrained(predAmbLat, w.mdBeam, constraints)
+	return mappings, nil
 }
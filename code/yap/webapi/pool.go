@@ -0,0 +1,217 @@
+package webapi
+
+import (
+	"log"
+	"net/http"
+	"runtime"
+
+	"yap/alg/search"
+	"yap/app"
+	"yap/nlp/parser/disambig"
+	"yap/nlp/parser/joint"
+	"yap/nlp/parser/ma"
+	"yap/util"
+)
+
+var (
+	Workers     int
+	QueueDepth  int
+	FrozenEnums bool
+
+	pool *WorkerPool
+)
+
+// stageEnums holds the enumerations a stage's model was trained with;
+// each initializer captures its own copy since app's globals are
+// overwritten by the next stage to load
+type stageEnums struct {
+	EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens *util.EnumSet
+}
+
+func captureEnums() *stageEnums {
+	return &stageEnums{
+		EWord:      app.EWord,
+		EPOS:       app.EPOS,
+		EWPOS:      app.EWPOS,
+		EMHost:     app.EMHost,
+		EMSuffix:   app.EMSuffix,
+		EMorphProp: app.EMorphProp,
+		ETrans:     app.ETrans,
+		ETokens:    app.ETokens,
+	}
+}
+
+// close stops a stage's feature enumerations from growing with every unseen
+// word, tag and feature string of the requests; unseen values map to a
+// reserved unknown value the model has no weights for. The transition
+// enumeration is left open since its values identify the lattice paths
+// chosen by the disambiguator; each worker adds those it hasn't seen to its
+// own overlay of it instead (see overlayTransitions).
+func (e *stageEnums) close() {
+	if e == nil {
+		return
+	}
+	for _, enum := range []*util.EnumSet{e.EWord, e.EPOS, e.EMHost, e.EMSuffix, e.EMorphProp, e.ETokens} {
+		if enum != nil {
+			enum.Close(util.UNKNOWN)
+		}
+	}
+	if e.EWPOS != nil {
+		e.EWPOS.Close([2]string{util.UNKNOWN, util.UNKNOWN})
+	}
+}
+
+// Worker holds the per-request mutable state of every stage; the lexicon,
+// model weights, feature extractors and transition systems are shared
+// (read-only) between all workers, but for the transition systems of the
+// stages with frozen enumerations, which use the worker's own transitions
+type Worker struct {
+	ID          int
+	maData      *ma.BGULex
+	mdBeam      *search.Beam
+	depBeam     *search.Beam
+	jointBeam   *search.Beam
+	transitions []*util.EnumSet
+}
+
+func NewWorker(id int) *Worker {
+	w := &Worker{ID: id}
+	if maData != nil {
+		lex := *maData
+		w.maData = &lex
+	}
+	w.mdBeam = copyBeam(mdBeam)
+	w.depBeam = copyBeam(depBeam)
+	w.jointBeam = copyBeam(jointBeam)
+	if FrozenEnums {
+		for _, b := range []*search.Beam{w.mdBeam, w.jointBeam} {
+			if overlay := overlayTransitions(b); overlay != nil {
+				w.transitions = append(w.transitions, overlay)
+			}
+		}
+	}
+	return w
+}
+
+func copyBeam(b *search.Beam) *search.Beam {
+	if b == nil {
+		return nil
+	}
+	beamCopy := *b
+	return &beamCopy
+}
+
+// overlayTransitions gives a worker's copy of an MD or joint beam its own
+// overlay of the stage's transition enumeration: the MD transition system
+// adds the morpheme projections it hasn't seen to the enumeration while
+// parsing, and the overlay keeps them out of the shared enumeration so they
+// can be dropped after every request
+func overlayTransitions(b *search.Beam) *util.EnumSet {
+	if b == nil {
+		return nil
+	}
+	var overlay *util.EnumSet
+	switch trans := b.TransFunc.(type) {
+	case *disambig.MDTrans:
+		conf := *b.Base.(*disambig.MDConfig)
+		overlay = conf.Transitions.Overlay()
+		conf.Transitions = overlay
+		mdTrans := *trans
+		mdTrans.Transitions = overlay
+		b.TransFunc, b.Base = &mdTrans, &conf
+	case *joint.JointTrans:
+		conf := *b.Base.(*joint.JointConfig)
+		overlay = conf.MDConfig.Transitions.Overlay()
+		conf.SimpleConfiguration.ETrans = overlay
+		conf.MDConfig.Transitions = overlay
+		mdTrans := *trans.MDTrans.(*disambig.MDTrans)
+		mdTrans.Transitions = overlay
+		jointTrans := *trans
+		jointTrans.MDTrans = &mdTrans
+		jointTrans.Transitions = overlay
+		b.TransFunc, b.Base = &jointTrans, &conf
+	default:
+		return nil
+	}
+	b.Transitions = overlay
+	return overlay
+}
+
+// WorkerPool hands out workers to requests; at most len(workers) requests
+// run concurrently, up to queueDepth more wait for a free worker and the
+// rest are rejected
+type WorkerPool struct {
+	workers chan *Worker
+	slots   chan struct{}
+}
+
+func NewWorkerPool(numWorkers, queueDepth int) *WorkerPool {
+	if numWorkers <= 0 {
+		numWorkers = runtime.NumCPU()
+	}
+	if queueDepth < 0 {
+		queueDepth = 0
+	}
+	p := &WorkerPool{
+		workers: make(chan *Worker, numWorkers),
+		slots:   make(chan struct{}, numWorkers+queueDepth),
+	}
+	for i := 0; i < numWorkers; i++ {
+		p.workers <- NewWorker(i)
+	}
+	log.Println("Started worker pool:", numWorkers, "workers, queue depth", queueDepth)
+	return p
+}
+
+func (p *WorkerPool) Size() int {
+	return cap(p.workers)
+}
+
+// Acquire waits for a free worker, failing immediately if the queue is full
+func (p *WorkerPool) Acquire() (*Worker, error) {
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		return nil, NewAPIError(http.StatusServiceUnavailable, ERR_SATURATED, STAGE_REQUEST, "server is saturated, retry later")
+	}
+	return <-p.workers, nil
+}
+
+// TryAcquire returns an idle worker, or nil if none is free
+func (p *WorkerPool) TryAcquire() *Worker {
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		return nil
+	}
+	select {
+	case w := <-p.workers:
+		return w
+	default:
+		<-p.slots
+		return nil
+	}
+}
+
+// Release returns a worker to the pool, dropping the transitions it added
+// while serving the request
+func (p *WorkerPool) Release(w *Worker) {
+	for _, overlay := range w.transitions {
+		overlay.Reset()
+	}
+	p.workers <- w
+	<-p.slots
+}
+
+// withWorker acquires a worker for the duration of a request
+func withWorker(handler func(http.ResponseWriter, *http.Request, *Worker)) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		w, err := pool.Acquire()
+		if err != nil {
+			respondWithError(resp, STAGE_REQUEST, err)
+			return
+		}
+		defer pool.Release(w)
+		handler(resp, req, w)
+	}
+}
@@ -1,6 +1,7 @@
 package webapi
 
 import (
+	"bytes"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -11,9 +12,14 @@ import (
 	"yap/nlp/types"
 	"strings"
 	"yap/app"
+	"yap/nlp/parser/constraint"
 	"yap/nlp/parser/joint"
 	"yap/nlp/format/lattice"
 	"yap/nlp/format/conll"
+	"yap/nlp/format/mapping"
+	"yap/nlp/grammar/agreement"
+	"yap/nlp/numerals"
+	"yap/util"
 )
 
 
@@ -22,103 +28,384 @@ var (
 )
 
 type Request struct {
-	Text string `json:text`
-	AmbLattice string `json:amb_lattice`
-	DisambLattice string `json:disamb_lattice`
+	Text string `json:"text"`
+	AmbLattice string `json:"amb_lattice"`
+	DisambLattice string `json:"disamb_lattice"`
+	Format string `json:"format"`
+	Lemma string `json:"lemma"`
+	POS string `json:"pos"`
+	Feats string `json:"feats"`
+	NoPrefixes bool `json:"no_prefixes"`
+	SpellNumerals bool `json:"spell_numerals"`
+	NumValues bool `json:"num_values"`
+	KBest int `json:"kbest"`
+	Confidence bool `json:"confidence"`
+	Constraints []*constraint.Sentence `json:"constraints"`
 }
 
 type Data struct {
 	MALattice string `json:"ma_lattice,omitempty"`
 	MDLattice string `json:"md_lattice,omitempty"`
 	DepTree string `json:"dep_tree,omitempty"`
-	Error error `json:"error,omitempty"`
+	Sentences []JSONSentence `json:"sentences,omitempty"`
+	Agreement []*agreement.Mismatch `json:"agreement,omitempty"`
+	Forms []GeneratedForm `json:"forms,omitempty"`
+	Error *APIError `json:"error,omitempty"`
 }
 
-func HebrewMorphAnalyzerHandler(resp http.ResponseWriter, req *http.Request) {
-	request := Request{}
-	err := json.NewDecoder(req.Body).Decode(&request)
+func decodeRequest(resp http.ResponseWriter, req *http.Request) (*Request, bool) {
+	request := &Request{}
+	err := json.NewDecoder(req.Body).Decode(request)
 	if err != nil {
-		data := Data { Error: err }
-		respondWithJSON(resp, http.StatusBadRequest, data)
+		respondWithError(resp, STAGE_REQUEST, NewAPIError(http.StatusBadRequest, ERR_BAD_REQUEST, STAGE_REQUEST, "invalid JSON request: %v", err))
+		return nil, false
+	}
+	return request, true
+}
+
+func unescapeLattice(input string) string {
+	output := strings.Replace(input, "\\t", "\t", -1)
+	return strings.Replace(output, "\\n", "\n", -1)
+}
+
+func writeLattices(lattices []lattice.Lattice) string {
+	buf := new(bytes.Buffer)
+	lattice.Write(buf, lattices)
+	return buf.String()
+}
+
+// parserLattices converts analyzed lattices to parser input lattices,
+// keeping their token ranges
+func parserLattices(maLattices []lattice.Lattice) ([]lattice.Lattice, error) {
+	lAmb, err := lattice.Reparse(maLattices)
+	if err != nil {
+		return nil, InternalError(STAGE_MA, "failed converting analyzed lattices: %v", err)
+	}
+	return lAmb, nil
+}
+
+func HebrewMorphAnalyzerHandler(resp http.ResponseWriter, req *http.Request, w *Worker) {
+	request, ok := decodeRequest(resp, req)
+	if !ok {
 		return
 	}
-	rawText := strings.Replace(request.Text, " ", "\n", -1)
-	maLattice := HebrewMorphAnalyzeRawSentences(rawText)
-	data := Data{ MALattice: maLattice }
+	maLattices, err := w.HebrewMorphAnalyzeText(request.Text)
+	if err != nil {
+		respondWithError(resp, STAGE_MA, err)
+		return
+	}
+	data := Data{}
+	if wantsJSON(req, request) {
+		data.Sentences = make([]JSONSentence, len(maLattices))
+		for i, lat := range maLattices {
+			data.Sentences[i] = JSONFromAmbLattice(lat)
+		}
+	} else {
+		data.MALattice = writeLattices(maLattices)
+	}
 	respondWithJSON(resp, http.StatusOK, data)
 }
 
-func MorphDisambiguatorHandler(resp http.ResponseWriter, req *http.Request) {
-	request := Request{}
-	err := json.NewDecoder(req.Body).Decode(&request)
+func MorphDisambiguatorHandler(resp http.ResponseWriter, req *http.Request, w *Worker) {
+	request, ok := decodeRequest(resp, req)
+	if !ok {
+		return
+	}
+	ambLattice := unescapeLattice(request.AmbLattice)
+	if request.KBest > 1 {
+		kbest, err := w.MorphDisambiguateKBest(ambLattice, request.KBest, request.Constraints)
+		if err != nil {
+			respondWithError(resp, STAGE_MD, err)
+			return
+		}
+		data := Data{}
+		if wantsJSON(req, request) {
+			data.Sentences = JSONFromKBest(kbest, func(i int) JSONSentence {
+				return JSONFromMDConfig(kbest.Parses[i])
+			})
+		} else {
+			buf := new(bytes.Buffer)
+			kbest.Write(buf, kbest.Parses, mapping.Write)
+			data.MDLattice = buf.String()
+		}
+		respondWithJSON(resp, http.StatusOK, data)
+		return
+	}
+	var (
+		mappings []interface{}
+		confs    []*app.Confidence
+		err      error
+	)
+	if request.Confidence {
+		mappings, confs, err = w.MorphDisambiguateConfidence(ambLattice, request.Constraints)
+	} else {
+		mappings, err = w.MorphDisambiguate(ambLattice, request.Constraints)
+	}
 	if err != nil {
-		data := Data { Error: err }
-		respondWithJSON(resp, http.StatusBadRequest, data)
+		respondWithError(resp, STAGE_MD, err)
 		return
 	}
-	ambLattice := strings.Replace(request.AmbLattice, "\\t", "\t", -1)
-	ambLattice = strings.Replace(ambLattice, "\\n", "\n", -1)
-	mdLattice := MorphDisambiguateLattices(ambLattice)
-	data := Data { MDLattice: mdLattice }
+	data := Data{}
+	if wantsJSON(req, request) {
+		data.Sentences = make([]JSONSentence, len(mappings))
+		for i, mdConfig := range mappings {
+			data.Sentences[i] = JSONFromMDConfig(mdConfig)
+			if confs != nil {
+				data.Sentences[i].AddConfidence(confs[i])
+			}
+		}
+	} else {
+		buf := new(bytes.Buffer)
+		mapping.Write(buf, mappings)
+		data.MDLattice = buf.String()
+	}
 	respondWithJSON(resp, http.StatusOK, data)
 }
 
-func DepParserHandler(resp http.ResponseWriter, req *http.Request) {
-	request := Request{}
-	err := json.NewDecoder(req.Body).Decode(&request)
+func DepParserHandler(resp http.ResponseWriter, req *http.Request, w *Worker) {
+	request, ok := decodeRequest(resp, req)
+	if !ok {
+		return
+	}
+	disambLattice := unescapeLattice(request.DisambLattice)
+	if request.KBest > 1 {
+		lattices, kbest, err := w.DepParseKBest(disambLattice, request.KBest, request.Constraints)
+		if err != nil {
+			respondWithError(resp, STAGE_DEP, err)
+			return
+		}
+		data := Data{}
+		if wantsJSON(req, request) {
+			data.Sentences = JSONFromKBest(kbest, func(i int) JSONSentence {
+				sent := JSONFromDisambLattice(lattices[kbest.Sentences[i]])
+				sent.AddDepTree(kbest.Parses[i].(conll.Sentence))
+				return sent
+			})
+		} else {
+			buf := new(bytes.Buffer)
+			kbest.Write(buf, kbest.Parses, conll.Write)
+			data.DepTree = buf.String()
+		}
+		respondWithJSON(resp, http.StatusOK, data)
+		return
+	}
+	var (
+		lattices []lattice.Lattice
+		trees    []interface{}
+		confs    []*app.Confidence
+		err      error
+	)
+	if request.Confidence {
+		lattices, trees, confs, err = w.DepParseConfidence(disambLattice, request.Constraints)
+	} else {
+		lattices, trees, err = w.DepParse(disambLattice, request.Constraints)
+	}
+	if err != nil {
+		respondWithError(resp, STAGE_DEP, err)
+		return
+	}
+	data := Data{}
+	if wantsJSON(req, request) {
+		data.Sentences = make([]JSONSentence, len(trees))
+		for i, tree := range trees {
+			data.Sentences[i] = JSONFromDisambLattice(lattices[i])
+			data.Sentences[i].AddDepTree(tree.(conll.Sentence))
+			if confs != nil {
+				data.Sentences[i].AddConfidence(confs[i])
+			}
+		}
+	} else {
+		buf := new(bytes.Buffer)
+		conll.Write(buf, trees)
+		data.DepTree = buf.String()
+	}
+	respondWithJSON(resp, http.StatusOK, data)
+}
+
+func HebrewPipelineHandler(resp http.ResponseWriter, req *http.Request, w *Worker) {
+	request, ok := decodeRequest(resp, req)
+	if !ok {
+		return
+	}
+	maLattices, err := w.HebrewMorphAnalyzeText(request.Text)
+	if err != nil {
+		respondWithError(resp, STAGE_MA, err)
+		return
+	}
+	lAmb, err := parserLattices(maLattices)
 	if err != nil {
-		result := Data { Error: err }
-		respondWithJSON(resp, http.StatusBadRequest, result)
+		respondWithError(resp, STAGE_MA, err)
 		return
 	}
-	disambLattice := strings.Replace(request.DisambLattice, "\\t", "\t", -1)
-	disambLattice = strings.Replace(disambLattice, "\\n", "\n", -1)
-	depTree := DepParseDisambiguatedLattice(disambLattice)
-	data := Data { DepTree: depTree }
+	mappings, err := w.disambiguate(lAmb, nil)
+	if err != nil {
+		respondWithError(resp, STAGE_MD, err)
+		return
+	}
+	mdBuf := new(bytes.Buffer)
+	mapping.Write(mdBuf, mappings)
+	mdLattice := mdBuf.String()
+	_, trees, err := w.DepParse(mdLattice, nil)
+	if err != nil {
+		respondWithError(resp, STAGE_DEP, err)
+		return
+	}
+	data := Data{}
+	if wantsJSON(req, request) {
+		data.Sentences = make([]JSONSentence, len(mappings))
+		for i, mdConfig := range mappings {
+			data.Sentences[i] = JSONFromMDConfig(mdConfig)
+			data.Sentences[i].Lattice = JSONFromAmbLattice(maLattices[i]).Lattice
+			data.Sentences[i].AddDepTree(trees[i].(conll.Sentence))
+		}
+	} else {
+		depBuf := new(bytes.Buffer)
+		conll.Write(depBuf, trees)
+		data.MALattice, data.MDLattice, data.DepTree = writeLattices(maLattices), mdLattice, depBuf.String()
+	}
 	respondWithJSON(resp, http.StatusOK, data)
 }
 
-func HebrewPipelineHandler(resp http.ResponseWriter, req *http.Request) {
-	request := Request{}
-	err := json.NewDecoder(req.Body).Decode(&request)
+func HebrewJointHandler(resp http.ResponseWriter, req *http.Request, w *Worker) {
+	request, ok := decodeRequest(resp, req)
+	if !ok {
+		return
+	}
+	maLattices, err := w.HebrewMorphAnalyzeText(request.Text)
+	if err != nil {
+		respondWithError(resp, STAGE_MA, err)
+		return
+	}
+	lAmb, err := parserLattices(maLattices)
 	if err != nil {
-		data := Data { Error: err }
-		respondWithJSON(resp, http.StatusBadRequest, data)
+		respondWithError(resp, STAGE_MA, err)
+		return
+	}
+	if request.KBest > 1 {
+		kbest, err := w.jointParseKBest(lAmb, request.KBest, request.Constraints)
+		if err != nil {
+			respondWithError(resp, STAGE_JOINT, err)
+			return
+		}
+		if request.NumValues {
+			numerals.AnnotateCorpus(kbest.Parses)
+		}
+		if request.SpellNumerals {
+			numerals.SpellOutCorpus(kbest.Parses)
+		}
+		respondWithJSON(resp, http.StatusOK, jointKBestData(maLattices, kbest, wantsJSON(req, request)))
 		return
 	}
-	rawText := strings.Replace(request.Text, " ", "\n", -1)
-	maLattice := HebrewMorphAnalyzeRawSentences(rawText)
-	mdLattice := MorphDisambiguateLattices(maLattice)
-	depTree := DepParseDisambiguatedLattice(mdLattice)
-	data := Data { MALattice: maLattice, MDLattice: mdLattice, DepTree: depTree }
+	var (
+		parsedGraphs []interface{}
+		confs        []*app.Confidence
+	)
+	if request.Confidence {
+		parsedGraphs, confs, err = w.jointParseConfidence(lAmb, request.Constraints)
+	} else {
+		parsedGraphs, err = w.jointParse(lAmb, request.Constraints)
+	}
+	if err != nil {
+		respondWithError(resp, STAGE_JOINT, err)
+		return
+	}
+	if request.NumValues {
+		numerals.AnnotateCorpus(parsedGraphs)
+	}
+	if request.SpellNumerals {
+		numerals.SpellOutCorpus(parsedGraphs)
+	}
+	data := jointData(maLattices, parsedGraphs, wantsJSON(req, request))
+	if confs != nil {
+		for i := range data.Sentences {
+			data.Sentences[i].AddConfidence(confs[i])
+		}
+	}
 	respondWithJSON(resp, http.StatusOK, data)
 }
 
-func HebrewJointHandler(resp http.ResponseWriter, req *http.Request) {
-	request := Request{}
-	err := json.NewDecoder(req.Body).Decode(&request)
+// HebrewAgreementHandler joint parses the text and reports the numerals
+// that don't agree in gender with the noun they quantify
+func HebrewAgreementHandler(resp http.ResponseWriter, req *http.Request, w *Worker) {
+	request, ok := decodeRequest(resp, req)
+	if !ok {
+		return
+	}
+	maLattices, err := w.HebrewMorphAnalyzeText(request.Text)
+	if err != nil {
+		respondWithError(resp, STAGE_MA, err)
+		return
+	}
+	lAmb, err := parserLattices(maLattices)
 	if err != nil {
-		data := Data { Error: err }
-		respondWithJSON(resp, http.StatusBadRequest, data)
+		respondWithError(resp, STAGE_MA, err)
 		return
 	}
-	rawText := strings.Replace(request.Text, " ", "\n", -1)
-	maLattice := HebrewMorphAnalyzeRawSentences(rawText)
-	depTree, mdLattice, _ := JointParseAmbiguousLattices(maLattice)
-	data := Data { MALattice: maLattice, MDLattice: mdLattice, DepTree: depTree }
+	parsedGraphs, err := w.jointParse(lAmb, nil)
+	if err != nil {
+		respondWithError(resp, STAGE_JOINT, err)
+		return
+	}
+	data := jointData(maLattices, parsedGraphs, wantsJSON(req, request))
+	data.Agreement = agreement.CheckCorpus(parsedGraphs)
 	respondWithJSON(resp, http.StatusOK, data)
 }
 
+// jointData formats the joint parses of the given ambiguous lattices
+func jointData(maLattices []lattice.Lattice, parsedGraphs []interface{}, asJSON bool) Data {
+	mappings := app.GetInstances(parsedGraphs, app.GetJointMDConfig)
+	trees := conll.MorphGraph2ConllCorpus(parsedGraphs)
+	data := Data{}
+	if asJSON {
+		data.Sentences = make([]JSONSentence, len(mappings))
+		for i, mdConfig := range mappings {
+			data.Sentences[i] = JSONFromMDConfig(mdConfig)
+			data.Sentences[i].Lattice = JSONFromAmbLattice(maLattices[i]).Lattice
+			data.Sentences[i].AddDepTree(trees[i].(conll.Sentence))
+		}
+	} else {
+		mdBuf := new(bytes.Buffer)
+		mapping.Write(mdBuf, mappings)
+		depBuf := new(bytes.Buffer)
+		conll.Write(depBuf, trees)
+		data.MALattice, data.MDLattice, data.DepTree = writeLattices(maLattices), mdBuf.String(), depBuf.String()
+	}
+	return data
+}
+
+// jointKBestData formats the k-best joint parses of the given ambiguous
+// lattices
+func jointKBestData(maLattices []lattice.Lattice, kbest *app.KBestParses, asJSON bool) Data {
+	mappings := app.GetInstances(kbest.Parses, app.GetJointMDConfig)
+	trees := conll.MorphGraph2ConllCorpus(kbest.Parses)
+	data := Data{}
+	if asJSON {
+		data.Sentences = JSONFromKBest(kbest, func(i int) JSONSentence {
+			sent := JSONFromMDConfig(mappings[i])
+			sent.Lattice = JSONFromAmbLattice(maLattices[kbest.Sentences[i]]).Lattice
+			sent.AddDepTree(trees[i].(conll.Sentence))
+			return sent
+		})
+	} else {
+		mdBuf := new(bytes.Buffer)
+		kbest.Write(mdBuf, mappings, mapping.Write)
+		depBuf := new(bytes.Buffer)
+		kbest.Write(depBuf, trees, conll.Write)
+		data.MALattice, data.MDLattice, data.DepTree = writeLattices(maLattices), mdBuf.String(), depBuf.String()
+	}
+	return data
+}
+
 func respondWithJSON(resp http.ResponseWriter, code int, payload Data) {
 	resp.Header().Set("Content-Type", "application/json")
-	resp.WriteHeader(code)
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
-		errJson, _  := json.Marshal(err)
-		resp.Write(errJson)
-	} else {
-		resp.Write(jsonPayload)
+		log.Println("Failed marshaling response:", err)
+		code = http.StatusInternalServerError
+		jsonPayload, _ = json.Marshal(Data{Error: InternalError(STAGE_REQUEST, "failed encoding response: %v", err)})
 	}
+	resp.WriteHeader(code)
+	resp.Write(jsonPayload)
 }
 
 
@@ -142,6 +429,10 @@ listen to api requests
 	cmd.Flag.BoolVar(&app.HebMaShowoov, "ma_show_oov", false, "Output OOV tokens")
 	cmd.Flag.BoolVar(&lex.LOG_FAILURES, "ma_show_lex_error", false, "Log errors encountered when loading the lexicon")
 	cmd.Flag.IntVar(&app.BeamSize, "beam", 64, "Beam size")
+	cmd.Flag.IntVar(&Workers, "workers", 0, "Number of parser workers serving requests concurrently; 0 = number of CPUs")
+	cmd.Flag.IntVar(&QueueDepth, "queue_depth", 32, "Max requests waiting for a free worker before responding 503")
+	cmd.Flag.IntVar(&BatchWorkers, "batch_workers", 0, "Max idle workers a batch request may take over; 0 = all")
+	cmd.Flag.BoolVar(&FrozenEnums, "frozen_enums", true, "Map words, tags and features unseen in training to a reserved unknown value instead of growing the enumerations")
 	cmd.Flag.BoolVar(&app.UsePOP, "use_end_token", true, "Use end token (pop)")
 	cmd.Flag.BoolVar(&lattice.IGNORE_LEMMA, "nolemma", true, "Ignore lemmas")
 	//cmd.Flag.BoolVar(&conll.IGNORE_LEMMA, "conll_nolemma", true, "Ignore lemmas")
@@ -163,12 +454,22 @@ func StartAPIServer(cmd *commander.Command, args []string) error {
 	MorphDisambiguatorInitialize(cmd, args)
 	DepParserInitialize(cmd, args)
 	JointParserInitialize()
+	if FrozenEnums {
+		for _, enums := range []*stageEnums{mdEnums, depEnums, jointEnums} {
+			enums.close()
+		}
+		log.Println("Closed feature enumerations, unseen values map to", util.UNKNOWN)
+	}
+	pool = NewWorkerPool(Workers, QueueDepth)
 	router = mux.NewRouter()
-	router.HandleFunc("/yap/heb/ma", HebrewMorphAnalyzerHandler)
-	router.HandleFunc("/yap/heb/md", MorphDisambiguatorHandler)
-	router.HandleFunc("/yap/heb/dep", DepParserHandler)
-	router.HandleFunc("/yap/heb/pipeline", HebrewPipelineHandler)
-	router.HandleFunc("/yap/heb/joint", HebrewJointHandler)
+	router.HandleFunc("/yap/heb/ma", withRecovery(withWorker(HebrewMorphAnalyzerHandler)))
+	router.HandleFunc("/yap/heb/md", withRecovery(withWorker(MorphDisambiguatorHandler)))
+	router.HandleFunc("/yap/heb/dep", withRecovery(withWorker(DepParserHandler)))
+	router.HandleFunc("/yap/heb/pipeline", withRecovery(withWorker(HebrewPipelineHandler)))
+	router.HandleFunc("/yap/heb/joint", withRecovery(withWorker(HebrewJointHandler)))
+	router.HandleFunc("/yap/heb/joint/batch", withRecovery(withWorker(HebrewJointBatchHandler)))
+	router.HandleFunc("/yap/heb/agree", withRecovery(withWorker(HebrewAgreementHandler)))
+	router.HandleFunc("/yap/heb/generate", withRecovery(withWorker(HebrewGenerateHandler)))
 	log.Fatal(http.ListenAndServe(":8000", router))
 	return nil
 }